@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"bookbox-backend/internal/metrics"
 	"sync"
 	"time"
 
@@ -39,7 +40,10 @@ func NewCache() *Cache {
 func (c *Cache) Get(key string) (interface{}, bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	return c.cache.Get(key)
+
+	value, found := c.cache.Get(key)
+	metrics.CacheLookup(found)
+	return value, found
 }
 
 // Set sets a value in the cache.
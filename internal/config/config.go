@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	EnvironmentDevelopment = "development"
+	EnvironmentProduction  = "production"
+)
+
+// Config is every setting of the service. It is loaded once on startup from the defaults, the
+// optional CONFIG_FILE (YAML or TOML, keys as in the key tags) and the environment, in that order,
+// and passed to the packages which need it. Secrets can also come from a file, see readSecret,
+// and are reloaded into Live when they are rotated.
+//
+// Tags: env is the variable, key the path in the file, default the value used when neither sets
+// it, required is "true" or "production", secret redacts the value in Dump, oneof lists the allowed
+// values, min is the lowest allowed number and unit scales plain numbers given for durations.
+type Config struct {
+	Environment string `env:"ENVIRONMENT" key:"environment" default:"development" oneof:"development,staging,production"`
+
+	Server    ServerConfig    `key:"server"`
+	Database  DatabaseConfig  `key:"database"`
+	JWT       JWTSettings     `key:"jwt"`
+	SendGrid  SendGridConfig  `key:"sendgrid"`
+	Xentral   XentralConfig   `key:"xentral"`
+	Catalog   CatalogConfig   `key:"catalog"`
+	SFTP      SFTPConfig      `key:"sftp"`
+	FTP       FTPConfig       `key:"ftp"`
+	Auth      AuthConfig      `key:"auth"`
+	OIDC      OIDCConfig      `key:"oidc"`
+	Password  PasswordConfig  `key:"password"`
+	Orders    OrderConfig     `key:"orders"`
+	API       APIConfig       `key:"api"`
+	RateLimit RateLimitConfig `key:"rate_limit"`
+	Metrics   MetricsConfig   `key:"metrics"`
+	Tracing   TracingConfig   `key:"tracing"`
+}
+
+type ServerConfig struct {
+	IP              string        `env:"SERVER_IP" key:"ip" default:"127.0.0.1"`
+	Port            int           `env:"SERVER_PORT" key:"port" default:"4443" min:"1"`
+	Domain          string        `env:"SERVER_DOMAIN" key:"domain" default:"localhost"`
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" key:"shutdown_timeout" default:"30" unit:"1s" min:"1"`
+}
+
+type DatabaseConfig struct {
+	Host     string `env:"DB_HOST" key:"host" required:"true"`
+	Port     string `env:"DB_PORT" key:"port" default:"5432"`
+	User     string `env:"DB_USER" key:"user" required:"true"`
+	Password string `env:"DB_PASS" key:"password" secret:"true"`
+	Name     string `env:"DB_NAME" key:"name" required:"true"`
+}
+
+// JWTSettings points at the key files, the keys themselves are loaded into JWTConfig
+type JWTSettings struct {
+	RefreshPrivateKey string        `env:"JWT_REFRESH_PRIVATE_KEY" key:"refresh_private_key" required:"true"`
+	RefreshVerifyKeys []string      `env:"JWT_REFRESH_VERIFY_KEYS" key:"refresh_verify_keys"`
+	RefreshExpiry     time.Duration `env:"JWT_REFRESH_EXPIRY" key:"refresh_expiry" required:"true" unit:"1m" min:"1"`
+
+	AccessPrivateKey string        `env:"JWT_ACCESS_PRIVATE_KEY" key:"access_private_key" required:"true"`
+	AccessVerifyKeys []string      `env:"JWT_ACCESS_VERIFY_KEYS" key:"access_verify_keys"`
+	AccessExpiry     time.Duration `env:"JWT_ACCESS_EXPIRY" key:"access_expiry" required:"true" unit:"1m" min:"1"`
+}
+
+type SendGridConfig struct {
+	APIKey                  string `env:"SENDGRID_API_KEY_DEV" key:"api_key" required:"production" secret:"true"`
+	SenderEmail             string `env:"SENDGRID_SENDER_EMAIL" key:"sender_email" required:"production"`
+	OrderTemplateID         string `env:"SENDGRID_ORDER_TEMPLATE_ID" key:"order_template_id"`
+	FailedTemplateID        string `env:"SENDGRID_FAILED_TEMPLATE_ID" key:"failed_template_id"`
+	PasswordResetTemplateID string `env:"SENDGRID_PASSWORD_RESET_TEMPLATE_ID" key:"password_reset_template_id"`
+	VerifyTemplateID        string `env:"SENDGRID_VERIFY_TEMPLATE_ID" key:"verify_template_id"`
+}
+
+type XentralConfig struct {
+	URL   string `env:"XENTRAL_URL" key:"url" required:"production"`
+	Token string `env:"XENTRAL_TOKEN" key:"token" required:"production" secret:"true"`
+}
+
+// CatalogConfig selects where the sync fetches the ONIX and annotation archives from
+type CatalogConfig struct {
+	Source string `env:"CATALOG_SOURCE" key:"source" default:"sftp" oneof:"sftp,ftp,local"`
+
+	// Dir is the root of the local source, laid out like the server (Onix, OnixDL, Annot)
+	Dir string `env:"CATALOG_DIR" key:"dir"`
+
+	// Withdrawn is what happens to deleted and permanently unavailable titles: deactivate the
+	// product or hide it in every sales channel
+	Withdrawn string `env:"CATALOG_WITHDRAWN" key:"withdrawn" default:"deactivate" oneof:"deactivate,hide"`
+
+	// OnixSchedule and AnnotSchedule are the cron expressions of the ONIX and annotation syncs,
+	// in the time zone of the server
+	OnixSchedule  string `env:"CATALOG_ONIX_SCHEDULE" key:"onix_schedule" default:"0 2 * * *"`
+	AnnotSchedule string `env:"CATALOG_ANNOT_SCHEDULE" key:"annot_schedule" default:"0 4 * * *"`
+}
+
+// SFTPConfig is the Buchzentrum catalog server, it authenticates with the private key, the
+// password or both and only accepts the host keys listed in KnownHosts
+type SFTPConfig struct {
+	Address              string        `env:"SFTP_ADDRESS" key:"address" default:"sftp.buchzentrum.ch:22"`
+	User                 string        `env:"SFTP_USER" key:"user"`
+	Password             string        `env:"SFTP_PASSWORD" key:"password" secret:"true"`
+	PrivateKey           string        `env:"SFTP_PRIVATE_KEY" key:"private_key"`
+	PrivateKeyPassphrase string        `env:"SFTP_PRIVATE_KEY_PASSPHRASE" key:"private_key_passphrase" secret:"true"`
+	KnownHosts           string        `env:"SFTP_KNOWN_HOSTS" key:"known_hosts" default:"known_hosts"`
+	Timeout              time.Duration `env:"SFTP_TIMEOUT" key:"timeout" default:"10s" min:"1"`
+}
+
+type FTPConfig struct {
+	Address  string        `env:"FTP_ADDRESS" key:"address"`
+	User     string        `env:"FTP_USER" key:"user" default:"anonymous"`
+	Password string        `env:"FTP_PASSWORD" key:"password" secret:"true"`
+	TLS      bool          `env:"FTP_TLS" key:"tls" default:"true"`
+	Timeout  time.Duration `env:"FTP_TIMEOUT" key:"timeout" default:"10s" min:"1"`
+}
+
+type AuthConfig struct {
+	PasswordResetURL string        `env:"PASSWORD_RESET_URL" key:"password_reset_url"`
+	PasswordResetTTL time.Duration `env:"PASSWORD_RESET_TTL" key:"password_reset_ttl" default:"30" unit:"1m" min:"1"`
+	EmailVerifyURL   string        `env:"EMAIL_VERIFY_URL" key:"email_verify_url"`
+	EmailVerifyTTL   time.Duration `env:"EMAIL_VERIFY_TTL" key:"email_verify_ttl" default:"48" unit:"1h" min:"1"`
+
+	LockoutThreshold        int           `env:"LOGIN_LOCKOUT_THRESHOLD" key:"lockout_threshold" default:"5" min:"1"`
+	AccountLockoutThreshold int           `env:"LOGIN_ACCOUNT_LOCKOUT_THRESHOLD" key:"account_lockout_threshold" default:"20" min:"1"`
+	FailureWindow           time.Duration `env:"LOGIN_FAILURE_WINDOW" key:"failure_window" default:"15m" min:"1"`
+	LockoutBase             time.Duration `env:"LOGIN_LOCKOUT_BASE" key:"lockout_base" default:"1m" min:"1"`
+	LockoutMax              time.Duration `env:"LOGIN_LOCKOUT_MAX" key:"lockout_max" default:"1h" min:"1"`
+
+	TOTPIssuer             string   `env:"TOTP_ISSUER" key:"totp_issuer" default:"Bookbox"`
+	TwoFactorRequiredRoles []string `env:"TWO_FACTOR_REQUIRED_ROLES" key:"two_factor_required_roles"`
+
+	OIDCDefaultSalutation string `env:"OIDC_DEFAULT_SALUTATION" key:"oidc_default_salutation"`
+}
+
+type PasswordConfig struct {
+	MinLength      int  `env:"PASSWORD_MIN_LENGTH" key:"min_length" default:"10" min:"1"`
+	MaxLength      int  `env:"PASSWORD_MAX_LENGTH" key:"max_length" default:"128" min:"1"`
+	RequireUpper   bool `env:"PASSWORD_REQUIRE_UPPER" key:"require_upper" default:"true"`
+	RequireLower   bool `env:"PASSWORD_REQUIRE_LOWER" key:"require_lower" default:"true"`
+	RequireDigit   bool `env:"PASSWORD_REQUIRE_DIGIT" key:"require_digit" default:"true"`
+	RequireSpecial bool `env:"PASSWORD_REQUIRE_SPECIAL" key:"require_special" default:"false"`
+}
+
+type OrderConfig struct {
+	StatusURL        string        `env:"ORDER_STATUS_URL" key:"status_url"`
+	AccessSecret     string        `env:"ORDER_ACCESS_SECRET" key:"access_secret" required:"production" secret:"true"`
+	AccessTTL        time.Duration `env:"ORDER_ACCESS_TTL" key:"access_ttl" default:"180" unit:"24h" min:"1"`
+	UnverifiedOrders string        `env:"UNVERIFIED_ORDERS" key:"unverified_orders" default:"block" oneof:"allow,block,limit"`
+	UnverifiedLimit  int           `env:"UNVERIFIED_ORDER_LIMIT" key:"unverified_order_limit" default:"1" min:"0"`
+}
+
+type APIConfig struct {
+	// AuthKey is the legacy global api key
+	AuthKey  string `env:"AUTH_KEY" key:"auth_key" secret:"true"`
+	Required bool   `env:"API_KEY_REQUIRED" key:"key_required" default:"false"`
+}
+
+// RateLimitConfig holds the rules per route group, e.g. "ip=300/1m,account=600/1m" or "off",
+// an empty value keeps the built-in limits of the group
+type RateLimitConfig struct {
+	Auth    string `env:"RATE_LIMIT_AUTH" key:"auth"`
+	Payment string `env:"RATE_LIMIT_PAYMENT" key:"payment"`
+	Admin   string `env:"RATE_LIMIT_ADMIN" key:"admin"`
+	Crud    string `env:"RATE_LIMIT_CRUD" key:"crud"`
+	Default string `env:"RATE_LIMIT_DEFAULT" key:"default"`
+}
+
+// Groups returns the configured rules by route group
+func (r RateLimitConfig) Groups() map[string]string {
+	return map[string]string{
+		"auth":    r.Auth,
+		"payment": r.Payment,
+		"admin":   r.Admin,
+		"crud":    r.Crud,
+		"default": r.Default,
+	}
+}
+
+type MetricsConfig struct {
+	// Token is required in production, the metrics are not meant for the public
+	Token string `env:"METRICS_TOKEN" key:"token" required:"production" secret:"true"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `env:"TRACING_EXPORTER" key:"exporter" oneof:",otlp,stdout,file"`
+	File        string  `env:"TRACING_FILE" key:"file" default:"traces.json"`
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" key:"sample_ratio" default:"1" min:"0"`
+}
+
+// validate checks the rules which span several fields
+func (c *Config) validate() (problems []string) {
+	if net.ParseIP(c.Server.IP) == nil {
+		problems = append(problems, fmt.Sprintf("SERVER_IP (%q) is not an IP address", c.Server.IP))
+	}
+
+	switch c.Catalog.Source {
+	case "sftp":
+		if c.IsProduction() && c.SFTP.User == "" {
+			problems = append(problems, "SFTP_USER (sftp.user): is required")
+		}
+
+		if c.IsProduction() && c.SFTP.Password == "" && c.SFTP.PrivateKey == "" {
+			problems = append(problems, "SFTP_PASSWORD or SFTP_PRIVATE_KEY: one is required")
+		}
+	case "ftp":
+		if c.FTP.Address == "" {
+			problems = append(problems, "FTP_ADDRESS (ftp.address): is required for CATALOG_SOURCE=ftp")
+		}
+	case "local":
+		if c.Catalog.Dir == "" {
+			problems = append(problems, "CATALOG_DIR (catalog.dir): is required for CATALOG_SOURCE=local")
+		}
+	}
+
+	if c.Password.MinLength > c.Password.MaxLength {
+		problems = append(problems, fmt.Sprintf("PASSWORD_MIN_LENGTH (%d) is greater than PASSWORD_MAX_LENGTH (%d)",
+			c.Password.MinLength, c.Password.MaxLength))
+	}
+
+	if c.Auth.LockoutBase > c.Auth.LockoutMax {
+		problems = append(problems, fmt.Sprintf("LOGIN_LOCKOUT_BASE (%s) is greater than LOGIN_LOCKOUT_MAX (%s)",
+			c.Auth.LockoutBase, c.Auth.LockoutMax))
+	}
+
+	if c.Tracing.SampleRatio > 1 {
+		problems = append(problems, fmt.Sprintf("TRACING_SAMPLE_RATIO (%g) is greater than 1", c.Tracing.SampleRatio))
+	}
+
+	return
+}
+
+// IsProduction reports whether ENVIRONMENT is production
+func (c *Config) IsProduction() bool {
+	return c.Environment == EnvironmentProduction
+}
@@ -1,38 +1,152 @@
 package config
 
 import (
-	"bookbox-backend/pkg/logger"
 	"crypto/ed25519"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
+	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"go.uber.org/zap"
+	"github.com/golang-jwt/jwt/v4"
 )
 
 type JWTConfig struct {
-	RefreshPrivateKey ed25519.PrivateKey
-	RefreshPublicKey  ed25519.PublicKey
-	RefreshExpiry     time.Duration
+	Refresh       *KeySet
+	RefreshExpiry time.Duration
 
-	AccessPrivateKey ed25519.PrivateKey
-	AccessPublicKey  ed25519.PublicKey
-	AccessExpiry     time.Duration
+	Access       *KeySet
+	AccessExpiry time.Duration
 }
 
-func readED25519PrivateKey(envVar string) (ed25519.PrivateKey, error) {
+// KeySet signs with one key and verifies with every key of the set, so tokens issued
+// before a rotation stay valid while the previous key is still listed
+type KeySet struct {
+	SigningKID string
+	SigningKey ed25519.PrivateKey
+	VerifyKeys map[string]ed25519.PublicKey
+}
 
-	keyFilePEM, err := ReadFromRelativePath(
-		os.Getenv(envVar),
-	)
+// JWK is the public JSON Web Key of an Ed25519 key
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+var jwtConfig atomic.Value
+
+// GetJWT returns the currently loaded JWT configuration
+func GetJWT() *JWTConfig {
+	current, _ := jwtConfig.Load().(*JWTConfig)
+	if current == nil {
+		return &JWTConfig{}
+	}
+
+	return current
+}
+
+// ReloadJWT loads the key files again, the previous configuration stays active on error
+func ReloadJWT(settings JWTSettings) error {
+	loaded, err := loadJWT(settings)
+	if err != nil {
+		return err
+	}
+
+	jwtConfig.Store(loaded)
+	return nil
+}
+
+// KeyID returns the RFC 7638 thumbprint of the public key
+func KeyID(publicKey ed25519.PublicKey) string {
+	x := base64.RawURLEncoding.EncodeToString(publicKey)
+	sum := sha256.Sum256([]byte(`{"crv":"Ed25519","kty":"OKP","x":"` + x + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Sign signs the claims with the current key and sets the kid header
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if k == nil || k.SigningKey == nil {
+		return "", fmt.Errorf("jwt signing key is not loaded")
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = k.SigningKID
+	return token.SignedString(k.SigningKey)
+}
+
+// Keyfunc selects the verification key by the kid header, tokens without kid
+// were issued before rotation existed and are checked against the signing key
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if k == nil || k.SigningKey == nil {
+		return nil, fmt.Errorf("jwt keys are not loaded")
+	}
+
+	if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return k.SigningKey.Public(), nil
+	}
+
+	publicKey, ok := k.VerifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %s", kid)
+	}
+
+	return publicKey, nil
+}
+
+// JWKS returns every verification key of the set
+func (k *KeySet) JWKS() []JWK {
+	keys := make([]JWK, 0)
+	if k == nil {
+		return keys
+	}
+
+	for kid, publicKey := range k.VerifyKeys {
+		keys = append(keys, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(publicKey),
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: "EdDSA",
+		})
+	}
+
+	return keys
+}
+
+func decodePEM(path string) (*pem.Block, error) {
+	keyFilePEM, err := ReadFromRelativePath(path)
 	if err != nil {
 		return nil, err
 	}
 
 	keyDecodedPEM, _ := pem.Decode(keyFilePEM)
+	if keyDecodedPEM == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	return keyDecodedPEM, nil
+}
+
+func readED25519PrivateKey(path string) (ed25519.PrivateKey, error) {
+	keyDecodedPEM, err := decodePEM(path)
+	if err != nil {
+		return nil, err
+	}
 
 	keyParsed, err := x509.ParsePKCS8PrivateKey(
 		keyDecodedPEM.Bytes,
@@ -41,61 +155,91 @@ func readED25519PrivateKey(envVar string) (ed25519.PrivateKey, error) {
 		return nil, err
 	}
 
-	return keyParsed.(ed25519.PrivateKey), nil
+	privateKey, ok := keyParsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 key", path)
+	}
 
+	return privateKey, nil
 }
 
-var JWT JWTConfig
+// readED25519PublicKey accepts a public key or a private key file
+func readED25519PublicKey(path string) (ed25519.PublicKey, error) {
+	keyDecodedPEM, err := decodePEM(path)
+	if err != nil {
+		return nil, err
+	}
 
-func init() {
+	var keyParsed any
+	if keyDecodedPEM.Type == "PUBLIC KEY" {
+		keyParsed, err = x509.ParsePKIXPublicKey(keyDecodedPEM.Bytes)
+	} else {
+		keyParsed, err = x509.ParsePKCS8PrivateKey(keyDecodedPEM.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	log := logger.Log
+	switch key := keyParsed.(type) {
+	case ed25519.PublicKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key.Public().(ed25519.PublicKey), nil
+	}
 
-	// Read refresh token's private key file.
-	refreshPrivateKey, err := readED25519PrivateKey(
-		"JWT_REFRESH_PRIVATE_KEY",
-	)
+	return nil, fmt.Errorf("%s is not an ed25519 key", path)
+}
+
+// loadKeySet reads the signing key and the previous keys, which are only used for verification
+func loadKeySet(privateKeyPath string, verifyKeyPaths []string) (*KeySet, error) {
+	signingKey, err := readED25519PrivateKey(privateKeyPath)
 	if err != nil {
-		log.Error("readED25519PrivateKey failed",
-			zap.Error(err),
-		)
-		return
+		return nil, err
 	}
-	refreshExpiry, err := strconv.Atoi(os.Getenv("JWT_REFRESH_EXPIRY"))
-	if err != nil {
-		log.Error("JWT_REFRESH_EXPIRY reading failed",
-			zap.Error(err),
-		)
-		return
+
+	signingPublicKey := signingKey.Public().(ed25519.PublicKey)
+	keySet := &KeySet{
+		SigningKID: KeyID(signingPublicKey),
+		SigningKey: signingKey,
+		VerifyKeys: map[string]ed25519.PublicKey{},
 	}
+	keySet.VerifyKeys[keySet.SigningKID] = signingPublicKey
 
-	// Read access token's private key file.
-	accessPrivateKey, err := readED25519PrivateKey("JWT_ACCESS_PRIVATE_KEY")
-	if err != nil {
-		log.Error("readED25519PrivateKey failed",
-			zap.Error(err),
-		)
-		return
+	for _, path := range verifyKeyPaths {
+		publicKey, err := readED25519PublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+
+		keySet.VerifyKeys[KeyID(publicKey)] = publicKey
 	}
-	accessExpiry, err := strconv.Atoi(os.Getenv("JWT_ACCESS_EXPIRY"))
+
+	return keySet, nil
+}
+
+func loadJWT(settings JWTSettings) (*JWTConfig, error) {
+	// Read refresh token's keys.
+	refreshKeys, err := loadKeySet(settings.RefreshPrivateKey, settings.RefreshVerifyKeys)
 	if err != nil {
-		log.Error("JWT_ACCESS_EXPIRY reading failed",
-			zap.Error(err),
-		)
+		return nil, fmt.Errorf("refresh keys: %w", err)
+	}
 
-		return
+	// Read access token's keys.
+	accessKeys, err := loadKeySet(settings.AccessPrivateKey, settings.AccessVerifyKeys)
+	if err != nil {
+		return nil, fmt.Errorf("access keys: %w", err)
 	}
 
-	JWT = JWTConfig{
+	return &JWTConfig{
 
-		// Refresh key.
-		RefreshPrivateKey: refreshPrivateKey,
-		RefreshExpiry:     time.Minute * time.Duration(refreshExpiry),
+		// Refresh keys.
+		Refresh:       refreshKeys,
+		RefreshExpiry: settings.RefreshExpiry,
 
-		// Access key.
-		AccessPrivateKey: accessPrivateKey,
-		AccessExpiry:     time.Minute * time.Duration(accessExpiry),
-	}
+		// Access keys.
+		Access:       accessKeys,
+		AccessExpiry: settings.AccessExpiry,
+	}, nil
 }
 
 func ReadFromRelativePath(revPath string) ([]byte, error) {
@@ -105,7 +249,7 @@ func ReadFromRelativePath(revPath string) ([]byte, error) {
 		return nil, err
 	}
 
-	path = path + "/" + revPath[2:]
+	path = path + "/" + strings.TrimPrefix(revPath, "./")
 
 	content, err := os.ReadFile(filepath.Clean(path))
 	if err != nil {
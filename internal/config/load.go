@@ -0,0 +1,340 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[redacted]"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is a single setting of Config, key is its dotted path in the file and env the variable
+type field struct {
+	value reflect.Value
+	tag   reflect.StructTag
+	key   string
+	env   string
+}
+
+// Load reads the configuration, every missing or invalid value is reported, not only the first
+func Load() (*Config, error) {
+	cfg := &Config{}
+	fields := collectFields(reflect.ValueOf(cfg).Elem(), "", "")
+
+	fileValues, err := readFile(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return nil, err
+	}
+
+	raw, problems := readFields(fields, fileValues)
+
+	providerFields, providerProblems := cfg.OIDC.loadProviders(fileValues, raw)
+	fields = append(fields, providerFields...)
+	problems = append(problems, providerProblems...)
+
+	for key := range fileValues {
+		problems = append(problems, fmt.Sprintf("%s: unknown key in %s", key, os.Getenv("CONFIG_FILE")))
+	}
+
+	for _, f := range fields {
+		if f.required(cfg.Environment) && strings.TrimSpace(raw[f.key]) == "" {
+			problems = append(problems, fmt.Sprintf("%s: is required", f.name()))
+		}
+	}
+
+	problems = append(problems, cfg.validate()...)
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, &Error{Problems: problems}
+	}
+
+	return cfg, nil
+}
+
+// readFields sets the fields from their default, the file, the environment and the secret
+// files, the later ones win. The values used are returned by key, the file values read are
+// removed from fileValues.
+func readFields(fields []field, fileValues map[string]string) (raw map[string]string, problems []string) {
+	raw = make(map[string]string, len(fields))
+	for _, f := range fields {
+		value, ok := f.tag.Get("default"), false
+		if fileValue, found := fileValues[f.key]; found {
+			value, ok = fileValue, true
+			delete(fileValues, f.key)
+		}
+		// an empty variable counts as unset, as it always did
+		if envValue := os.Getenv(f.env); envValue != "" {
+			value, ok = envValue, true
+		}
+		if f.tag.Get("secret") == "true" {
+			secret, found, readErr := readSecret(f.env)
+			if readErr != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", f.name(), readErr))
+				continue
+			}
+			if found {
+				value, ok = secret, true
+			}
+		}
+
+		if !ok && f.tag.Get("default") == "" {
+			continue
+		}
+
+		raw[f.key] = value
+		err := setValue(f, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", f.name(), err))
+		}
+	}
+
+	return
+}
+
+// Error lists every problem of the configuration
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Dump returns the settings by key with the secrets redacted, for logging on startup
+func (c *Config) Dump() map[string]string {
+	dump := make(map[string]string)
+	for _, f := range c.fields() {
+		value := fmt.Sprint(f.value.Interface())
+		if f.value.Kind() == reflect.Slice {
+			value = strings.Join(f.value.Interface().([]string), ",")
+		}
+
+		if f.tag.Get("secret") == "true" && value != "" {
+			value = redacted
+		}
+
+		dump[f.key] = value
+	}
+
+	return dump
+}
+
+// fields returns the settings of every section and of the OIDC providers
+func (c *Config) fields() []field {
+	fields := collectFields(reflect.ValueOf(c).Elem(), "", "")
+	for _, name := range c.OIDC.Providers {
+		provider := c.OIDC.Provider[name]
+		fields = append(fields, provider.fields()...)
+	}
+
+	return fields
+}
+
+func (f field) name() string {
+	if f.env != "" {
+		return fmt.Sprintf("%s (%s)", f.env, f.key)
+	}
+
+	return f.key
+}
+
+func (f field) required(environment string) bool {
+	switch f.tag.Get("required") {
+	case "true":
+		return true
+	case EnvironmentProduction:
+		return environment == EnvironmentProduction
+	}
+
+	return false
+}
+
+// collectFields flattens the nested sections, keys are joined with dots and envPrefix is put in
+// front of the variables. Fields with the key "-" are not settings.
+func collectFields(value reflect.Value, prefix string, envPrefix string) (fields []field) {
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		if structField.Tag.Get("key") == "-" {
+			continue
+		}
+
+		key := prefix + structField.Tag.Get("key")
+
+		if structField.Type.Kind() == reflect.Struct && structField.Type != durationType {
+			fields = append(fields, collectFields(value.Field(i), key+".", envPrefix)...)
+			continue
+		}
+
+		env := structField.Tag.Get("env")
+		if env != "" {
+			env = envPrefix + env
+		}
+
+		fields = append(fields, field{
+			value: value.Field(i),
+			tag:   structField.Tag,
+			key:   key,
+			env:   env,
+		})
+	}
+
+	return
+}
+
+// setValue parses the text into the field and checks oneof and min
+func setValue(f field, text string) (err error) {
+	text = strings.TrimSpace(text)
+
+	if oneof, ok := f.tag.Lookup("oneof"); ok {
+		allowed := strings.Split(oneof, ",")
+		found := false
+		for _, value := range allowed {
+			found = found || value == text
+		}
+		if !found {
+			return fmt.Errorf("%q is not one of %s", text, strings.Join(allowed, ", "))
+		}
+	}
+
+	var number float64
+	switch {
+	case f.value.Type() == durationType:
+		var duration time.Duration
+		duration, err = parseDuration(text, f.tag.Get("unit"))
+		if err != nil {
+			return
+		}
+		f.value.SetInt(int64(duration))
+		number = float64(duration)
+
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(text)
+		return
+
+	case f.value.Kind() == reflect.Bool:
+		var value bool
+		value, err = strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", text)
+		}
+		f.value.SetBool(value)
+		return
+
+	case f.value.Kind() == reflect.Int:
+		var value int
+		value, err = strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", text)
+		}
+		f.value.SetInt(int64(value))
+		number = float64(value)
+
+	case f.value.Kind() == reflect.Float64:
+		var value float64
+		value, err = strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", text)
+		}
+		f.value.SetFloat(value)
+		number = value
+
+	case f.value.Kind() == reflect.Slice:
+		values := make([]string, 0)
+		for _, value := range strings.Split(text, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		f.value.Set(reflect.ValueOf(values))
+		return
+
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+
+	if minimum, ok := f.tag.Lookup("min"); ok {
+		limit, _ := strconv.ParseFloat(minimum, 64)
+		if number < limit {
+			return fmt.Errorf("%q is lower than %s", text, minimum)
+		}
+	}
+
+	return
+}
+
+// parseDuration accepts Go durations ("15m") and plain numbers, which are multiplied by the
+// unit of the field, so the existing variables in minutes, hours or days keep working
+func parseDuration(text string, unit string) (time.Duration, error) {
+	if count, err := strconv.ParseFloat(text, 64); err == nil && unit != "" {
+		scale, err := time.ParseDuration(unit)
+		if err != nil {
+			return 0, err
+		}
+
+		return time.Duration(count * float64(scale)), nil
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration", text)
+	}
+
+	return duration, nil
+}
+
+// readFile returns the values of the YAML or TOML file by dotted key
+func readFile(path string) (values map[string]string, err error) {
+	values = make(map[string]string)
+	if path == "" {
+		return
+	}
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CONFIG_FILE: %w", err)
+	}
+
+	document := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &document)
+	case ".toml":
+		err = toml.Unmarshal(content, &document)
+	default:
+		return nil, fmt.Errorf("CONFIG_FILE %s has to be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CONFIG_FILE %s: %w", path, err)
+	}
+
+	flatten(document, "", values)
+	return
+}
+
+func flatten(document map[string]any, prefix string, values map[string]string) {
+	for key, value := range document {
+		switch typed := value.(type) {
+		case map[string]any:
+			flatten(typed, prefix+key+".", values)
+		case []any:
+			items := make([]string, 0, len(typed))
+			for _, item := range typed {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[prefix+key] = strings.Join(items, ",")
+		default:
+			values[prefix+key] = fmt.Sprint(value)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// OIDCConfig lists the OpenID Connect identity providers usable for login, e.g.
+// OIDC_PROVIDERS="google,staff". The names are dynamic, so the settings of a provider are read
+// with the prefix OIDC_<NAME>_ from the environment and below oidc.<name> from the file.
+type OIDCConfig struct {
+	Providers []string `env:"OIDC_PROVIDERS" key:"providers"`
+
+	// Provider holds the settings of the listed providers by name
+	Provider map[string]OIDCProvider `key:"-"`
+}
+
+// OIDCProvider is an OpenID Connect identity provider usable for login
+type OIDCProvider struct {
+	Name         string   `key:"-"`
+	Issuer       string   `env:"ISSUER" key:"issuer" required:"true"`
+	ClientID     string   `env:"CLIENT_ID" key:"client_id" required:"true"`
+	ClientSecret string   `env:"CLIENT_SECRET" key:"client_secret" secret:"true"`
+	RedirectURL  string   `env:"REDIRECT_URL" key:"redirect_url" required:"true"`
+	Scopes       []string `env:"SCOPES" key:"scopes" default:"openid,email,profile"`
+
+	// Role is given to accounts created through the provider, only accounts with this
+	// role may log in through it, so a social login can never open an admin account
+	Role string `env:"ROLE" key:"role" default:"customer"`
+
+	// AllowSignup creates accounts for unknown emails, it is off unless enabled per provider
+	AllowSignup bool `env:"ALLOW_SIGNUP" key:"allow_signup" default:"false"`
+}
+
+func (p *OIDCProvider) fields() []field {
+	return collectFields(reflect.ValueOf(p).Elem(), "oidc."+p.Name+".", "OIDC_"+strings.ToUpper(p.Name)+"_")
+}
+
+// loadProviders reads the settings of the listed providers like the other sections, the raw
+// values are added to raw for the required check
+func (c *OIDCConfig) loadProviders(fileValues map[string]string, raw map[string]string) (fields []field, problems []string) {
+	c.Provider = make(map[string]OIDCProvider, len(c.Providers))
+
+	for _, name := range c.Providers {
+		provider := OIDCProvider{Name: name}
+		providerFields := provider.fields()
+
+		providerRaw, providerProblems := readFields(providerFields, fileValues)
+		for key, value := range providerRaw {
+			raw[key] = value
+		}
+
+		// scopes were always separated by spaces or commas
+		provider.Scopes = strings.Fields(strings.Join(provider.Scopes, " "))
+
+		c.Provider[name] = provider
+		fields = append(fields, providerFields...)
+		problems = append(problems, providerProblems...)
+	}
+
+	return
+}
@@ -0,0 +1,52 @@
+package config
+
+import "testing"
+
+func TestOIDCProviders(t *testing.T) {
+	for _, name := range []string{"GOOGLE", "STAFF"} {
+		t.Setenv("OIDC_"+name+"_ISSUER", "https://idp.example")
+		t.Setenv("OIDC_"+name+"_CLIENT_ID", "bookbox")
+		t.Setenv("OIDC_"+name+"_REDIRECT_URL", "https://shop.example/login/callback")
+	}
+	t.Setenv("OIDC_GOOGLE_ALLOW_SIGNUP", "true")
+	t.Setenv("OIDC_STAFF_SCOPES", "openid email, groups")
+
+	settings := OIDCConfig{Providers: []string{"google", "staff"}}
+	fileValues := map[string]string{"oidc.staff.role": "admin"}
+	raw := map[string]string{}
+
+	_, problems := settings.loadProviders(fileValues, raw)
+	if len(problems) > 0 {
+		t.Fatalf("unexpected problems %v", problems)
+	}
+
+	if len(fileValues) != 0 {
+		t.Errorf("file values not used: %v", fileValues)
+	}
+
+	google, staff := settings.Provider["google"], settings.Provider["staff"]
+
+	if !google.AllowSignup {
+		t.Error("google: signup is not allowed although enabled")
+	}
+
+	if staff.AllowSignup {
+		t.Error("staff: signup is allowed without being enabled")
+	}
+
+	if google.Role != "customer" || staff.Role != "admin" {
+		t.Errorf("roles are %q and %q, want customer and admin", google.Role, staff.Role)
+	}
+
+	if got := google.Scopes; len(got) != 3 || got[0] != "openid" || got[2] != "profile" {
+		t.Errorf("google: default scopes are %v", got)
+	}
+
+	if got := staff.Scopes; len(got) != 3 || got[1] != "email" || got[2] != "groups" {
+		t.Errorf("staff: scopes are %v, want openid email groups", got)
+	}
+
+	if raw["oidc.google.issuer"] != "https://idp.example" {
+		t.Errorf("raw issuer of google is %q", raw["oidc.google.issuer"])
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bookbox-backend/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// secretsRefreshInterval is how often WatchSecrets reads the secret files again
+const secretsRefreshInterval = 30 * time.Second
+
+// readSecret returns the secret from SECRETS_DIR/<name>, a directory with one file per variable
+// like a mounted Kubernetes secret, or from the file named in <name>_FILE. found is false when
+// neither is set and the variable itself is used.
+func readSecret(name string) (value string, found bool, err error) {
+	if dir := os.Getenv("SECRETS_DIR"); dir != "" {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return strings.TrimSpace(string(content)), true, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", false, err
+		}
+	}
+
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		content, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return "", false, err
+		}
+
+		return strings.TrimSpace(string(content)), true, nil
+	}
+
+	return "", false, nil
+}
+
+// Live is the configuration with the latest secrets. It is created on startup and passed to the
+// packages which use secrets that rotate, they call Get on every use instead of keeping the
+// values. DB_PASS is used for new connections only, the pool replaces them within an hour. The
+// client secrets of the OIDC providers are only read on startup and need a restart.
+type Live struct {
+	current atomic.Value
+}
+
+func NewLive(cfg *Config) *Live {
+	live := &Live{}
+	live.current.Store(cfg)
+
+	return live
+}
+
+// Get returns the configuration with the secrets of the last reload
+func (l *Live) Get() *Config {
+	return l.current.Load().(*Config)
+}
+
+// ReloadSecrets reads the secret files again and swaps in a configuration with the rotated
+// values. A secret which can't be read or became empty keeps its previous value.
+func (l *Live) ReloadSecrets() (changed []string, err error) {
+	updated := *l.Get()
+	problems := make([]string, 0)
+
+	for _, f := range collectFields(reflect.ValueOf(&updated).Elem(), "", "") {
+		if f.tag.Get("secret") != "true" {
+			continue
+		}
+
+		name := f.env
+		value, found, readErr := readSecret(name)
+		if readErr != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", f.name(), readErr))
+			continue
+		}
+
+		if !found || value == "" || value == f.value.String() {
+			continue
+		}
+
+		f.value.SetString(value)
+		changed = append(changed, name)
+	}
+
+	if len(changed) > 0 {
+		l.current.Store(&updated)
+	}
+
+	if len(problems) > 0 {
+		err = &Error{Problems: problems}
+	}
+
+	return
+}
+
+// WatchSecrets reloads the secrets until ctx is cancelled, so rotated credentials are used
+// without a restart
+func (l *Live) WatchSecrets(ctx context.Context) {
+	ticker := time.NewTicker(secretsRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := l.ReloadSecrets()
+		if err != nil {
+			logger.Log.Error("failed to reload secrets, keeping the previous values",
+				zap.Error(err),
+			)
+		}
+
+		if len(changed) > 0 {
+			logger.Log.Info("secrets rotated",
+				zap.Strings("secrets", changed),
+			)
+		}
+	}
+}
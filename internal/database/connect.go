@@ -1,25 +1,75 @@
 package database
 
 import (
+	"bookbox-backend/internal/metrics"
+	"context"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
 var DB *gorm.DB
 
+// migrationErr is the result of the migration on startup, reported by the readiness check
+var migrationErr error = fmt.Errorf("migration did not run")
+
+// MigrationError returns why the migration on startup failed, nil if it succeeded
+func MigrationError() error {
+	return migrationErr
+}
+
+// Ping checks that the database is reachable
+func Ping(ctx context.Context) error {
+	if DB == nil {
+		return fmt.Errorf("database is not connected")
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
 func Connect() (err error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC", database.Host, database.User, database.Password, database.Database, database.Port)
-	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+	dsn := fmt.Sprintf("host=%s user=%s dbname=%s port=%s sslmode=disable TimeZone=UTC", database.Host, database.User, database.Database, database.Port)
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return err
+	}
+
+	// the password is set per connection instead of in the dsn, so a rotated DB_PASS is used
+	// for the connections opened after the rotation
+	conn := stdlib.OpenDB(*connConfig, stdlib.OptionBeforeConnect(func(ctx context.Context, cfg *pgx.ConnConfig) error {
+		cfg.Password = liveConfig.Get().Database.Password
+		return nil
+	}))
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
 		return err
 	}
 
+	err = gormDB.Use(metrics.GormPlugin{})
+	if err != nil {
+		return err
+	}
+
+	// query values are left out of the spans, they contain personal data
+	err = gormDB.Use(tracing.NewPlugin(tracing.WithoutMetrics(), tracing.WithoutQueryVariables()))
+	if err != nil {
+		return err
+	}
+
 	sqlDB, err := gormDB.DB()
 	if err != nil {
 		return err
@@ -35,7 +85,8 @@ func Connect() (err error) {
 	sqlDB.SetConnMaxLifetime(time.Hour) // Adjust based on your requirements
 
 	// Run migrations.
-	Migrate(gormDB)
+	err = Migrate(gormDB)
+	migrationErr = err
 	if err != nil {
 		fmt.Println("failed to migrate :", err.Error())
 	}
@@ -21,6 +21,10 @@ func Migrate(gormDB *gorm.DB) (err error) {
 		return err
 	}
 
+	// accounts created before email verification existed are treated as verified
+	hasEmailVerified := gormDB.Migrator().HasTable(&model.User{}) &&
+		gormDB.Migrator().HasColumn(&model.User{}, "email_verified")
+
 	// Migrate ORM models.
 	err = gormDB.AutoMigrate(
 		&model.Category{},
@@ -39,11 +43,25 @@ func Migrate(gormDB *gorm.DB) (err error) {
 		&model.Discount{},
 		&model.Address{},
 		&model.Sync{},
+		&model.SyncRun{},
+		&model.SyncRunFile{},
+		&model.ScheduledJob{},
+		&model.APIKey{},
+		&model.AuditLog{},
+		&model.UserIdentity{},
+		&model.UserTwoFactor{},
 	)
 	if err != nil {
 		return
 	}
 
+	if !hasEmailVerified {
+		err = gormDB.Exec("UPDATE users SET email_verified = true").Error
+		if err != nil {
+			return
+		}
+	}
+
 	//Seed()
 
 	return
@@ -1,8 +1,8 @@
 package database
 
 import (
+	"bookbox-backend/internal/config"
 	"bookbox-backend/pkg/logger"
-	"os"
 
 	"go.uber.org/zap"
 )
@@ -11,27 +11,31 @@ type DBConfig struct {
 	Host     string
 	Port     string
 	User     string
-	Password string
 	Database string
 }
 
-var database DBConfig
+var (
+	database DBConfig
 
-func init() {
+	// liveConfig provides DB_PASS, it is read for every new connection so a rotated password
+	// is used once the pool replaces its connections
+	liveConfig *config.Live
+)
+
+// Setup connects to the database and seeds it, a failed seed is only logged
+func Setup(live *config.Live) (err error) {
+	liveConfig = live
 
+	settings := live.Get().Database
 	database = DBConfig{
-		Host:     os.Getenv("DB_HOST"),
-		Port:     os.Getenv("DB_PORT"),
-		Password: os.Getenv("DB_PASS"),
-		User:     os.Getenv("DB_USER"),
-		Database: os.Getenv("DB_NAME"),
+		Host:     settings.Host,
+		Port:     settings.Port,
+		User:     settings.User,
+		Database: settings.Name,
 	}
 
-	err := Connect()
+	err = Connect()
 	if err != nil {
-		logger.Log.Fatal("failed to start database : ",
-			zap.Error(err),
-		)
 		return
 	}
 
@@ -40,6 +44,7 @@ func init() {
 		logger.Log.Error("failed to seed database : ",
 			zap.Error(err),
 		)
-		return
 	}
+
+	return nil
 }
@@ -2,6 +2,7 @@ package database
 
 import (
 	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/passhash"
 	"bookbox-backend/pkg/crypto"
 	"bookbox-backend/pkg/logger"
 	"strconv"
@@ -76,7 +77,11 @@ func SeedUsers(log *zap.Logger) (err error) {
 		},
 	}
 	user.ID = "1"
-	user.Password = crypto.HashPassword(user.Password)
+	user.Password, err = passhash.Hash(user.Password)
+	if err != nil {
+		return
+	}
+
 	err = DB.Create(&user).Error
 	if err != nil {
 		return
@@ -99,7 +104,11 @@ func SeedUsers(log *zap.Logger) (err error) {
 	}
 	user.ID = "2"
 
-	user.Password = crypto.HashPassword(user.Password)
+	user.Password, err = passhash.Hash(user.Password)
+	if err != nil {
+		return
+	}
+
 	err = DB.Create(&user).Error
 	if err != nil {
 		return
@@ -122,7 +131,11 @@ func SeedUsers(log *zap.Logger) (err error) {
 	}
 	user.ID = "3"
 
-	user.Password = crypto.HashPassword(user.Password)
+	user.Password, err = passhash.Hash(user.Password)
+	if err != nil {
+		return
+	}
+
 	err = DB.Create(&user).Error
 	if err != nil {
 		return
@@ -1,12 +1,16 @@
 package postrun
 
 import (
+	"bookbox-backend/internal/config"
 	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/metrics"
 	"bookbox-backend/internal/model"
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/server/processor"
-	"bookbox-backend/pkg/logger"
+	"bookbox-backend/internal/tracing"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -80,8 +84,10 @@ func OrderPostrunList(request request.GetRequest, queried any, issuer *model.Use
 	return
 }
 
-func OrderPostrunCreate(queried any, issuer *model.User) (response any, err error) {
-	tx := database.DB.Begin()
+func OrderPostrunCreate(ctx context.Context, queried any, issuer *model.User) (response any, err error) {
+	log := requestid.Logger(ctx)
+
+	tx := database.DB.WithContext(ctx).Begin()
 	defer func() {
 		if err != nil {
 			tx.Rollback()
@@ -99,15 +105,15 @@ func OrderPostrunCreate(queried any, issuer *model.User) (response any, err erro
 
 	rawOrder, err := json.Marshal(order)
 	if err != nil {
-		logger.Log.Error("Failed to marshal order data", zap.Error(err))
+		log.Error("Failed to marshal order data", zap.Error(err))
 		// Handle the error if needed
 	} else {
-		logger.Log.Info("Order Details:", zap.String("order", string(rawOrder)))
+		log.Info("Order Details:", zap.String("order", string(rawOrder)))
 	}
 
 	for _, orderItem := range order.Products {
 		row := model.Product{}
-		db := database.DB.Where("id = ?", orderItem.ProductID).First(&row)
+		db := database.DB.WithContext(ctx).Where("id = ?", orderItem.ProductID).First(&row)
 		if db.Error != nil {
 			err = tx.Error
 			return
@@ -148,14 +154,14 @@ func OrderPostrunCreate(queried any, issuer *model.User) (response any, err erro
 	if order.PaymentStatus == "paid" {
 		id := order.ID
 
-		err = ProcessPaidOrder(order)
+		err = ProcessPaidOrder(ctx, order)
 		if err != nil {
-			logger.Log.Error("Failed to run ProcessPaidOrder func", zap.Error(err))
+			log.Error("Failed to run ProcessPaidOrder func", zap.Error(err))
 			return
 		}
-		logger.Log.Info("xentral order processing starting")
+		log.Info("xentral order processing starting")
 
-		err = processor.ProcessOrder(id, logger.Log)
+		err = processor.ProcessOrder(ctx, id, log)
 		if err != nil {
 			return
 		}
@@ -166,12 +172,12 @@ func OrderPostrunCreate(queried any, issuer *model.User) (response any, err erro
 }
 
 // if order is paid, send email
-func OrderPostrunUpdate(request *request.Request, issuer *model.User, log *zap.Logger) (err error) {
+func OrderPostrunUpdate(ctx context.Context, request *request.Request, issuer *model.User, log *zap.Logger) (err error) {
 	paymentStatus, _ := request.Data["payment_status"].(string)
 
 	if paymentStatus == "paid" {
 		id := request.Data["id"].(string)
-		err = processor.ProcessOrder(id, log)
+		err = processor.ProcessOrder(ctx, id, log)
 		if err != nil {
 			return err
 		}
@@ -180,14 +186,20 @@ func OrderPostrunUpdate(request *request.Request, issuer *model.User, log *zap.L
 	return
 }
 
-func ProcessPaidOrder(order model.Order) error {
-	var err error
+func ProcessPaidOrder(ctx context.Context, order model.Order) (err error) {
+	ctx, span := tracing.Start(ctx, "postrun.ProcessPaidOrder")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	log := requestid.Logger(ctx)
+
 	// Check if user details are available
 	if order.UserID != nil && *order.UserID != "" {
-		logger.Log.Info("Processing user details started FIRST FUNC")
+		log.Info("Processing user details started FIRST FUNC")
 		// Retrieve user details from the database based on user_id
 		user := &model.User{}
-		userQuery := database.DB.Where("id = ?", *order.UserID).First(user)
+		userQuery := database.DB.WithContext(ctx).Where("id = ?", *order.UserID).First(user)
 		if userQuery.Error != nil {
 			return userQuery.Error
 		}
@@ -196,15 +208,15 @@ func ProcessPaidOrder(order model.Order) error {
 		if user.DeliveryAddressID != nil {
 			// Retrieve address details based on delivery_address_id
 			address := &model.Address{}
-			addressQuery := database.DB.Where("id = ?", *user.DeliveryAddressID).First(address)
+			addressQuery := database.DB.WithContext(ctx).Where("id = ?", *user.DeliveryAddressID).First(address)
 			if addressQuery.Error != nil {
 				return addressQuery.Error
 			}
 
 			// Pass the order, user, and address details to the function
-			err = ProcessUserDetails(&order, user, address, logger.Log)
+			err = ProcessUserDetails(ctx, &order, user, address)
 			if err != nil {
-				logger.Log.Error("Failed to make POST request for user details", zap.Error(err))
+				log.Error("Failed to make POST request for user details", zap.Error(err))
 				// Log an error, but continue with the processing
 			}
 		}
@@ -213,11 +225,11 @@ func ProcessPaidOrder(order model.Order) error {
 
 	// Check if product details are empty
 	if len(order.Products) == 0 {
-		logger.Log.Error("Product details are empty or null")
+		log.Error("Product details are empty or null")
 		// Log an error, but continue with the processing
 	}
 
-	logger.Log.Info("OrderPostrunCreate: Started processing order")
+	log.Info("OrderPostrunCreate: Started processing order")
 
 	for _, orderItem := range order.Products {
 		// Check if product_id is present in the order item
@@ -228,37 +240,37 @@ func ProcessPaidOrder(order model.Order) error {
 		// Retrieve product details using product_id
 		productDetails, err := getProductDetails(orderItem.ProductID)
 		if err != nil {
-			logger.Log.Error("Failed to retrieve product details", zap.Error(err))
+			log.Error("Failed to retrieve product details", zap.Error(err))
 			return err
 		}
-		logger.Log.Info("OrderPostrunCreate: Retrieved product details", zap.String("product_id", orderItem.ProductID))
+		log.Info("OrderPostrunCreate: Retrieved product details", zap.String("product_id", orderItem.ProductID))
 
 		// Use the product details to construct the payload for the API request
 		payload, err := constructProductPayload(productDetails)
 		if err != nil {
 			// Handle the error, such as logging or returning an error response
-			logger.Log.Error("Failed to construct product payload", zap.Error(err))
+			log.Error("Failed to construct product payload", zap.Error(err))
 			return err
 		}
-		logger.Log.Info("OrderPostrunCreate: Constructed product payload", zap.String("payload", string(payload)))
+		log.Info("OrderPostrunCreate: Constructed product payload", zap.String("payload", string(payload)))
 
 		// Make the API request to create the product
-		err = createProductAPIRequest(payload)
+		err = createProductAPIRequest(ctx, payload)
 		if err != nil {
-			logger.Log.Error("Failed to make API request to create product", zap.Error(err))
+			log.Error("Failed to make API request to create product", zap.Error(err))
 			return err
 		}
-		logger.Log.Info("OrderPostrunCreate: Created product successfully", zap.String("product_id", orderItem.ProductID))
+		log.Info("OrderPostrunCreate: Created product successfully", zap.String("product_id", orderItem.ProductID))
 
 	}
 
 	// Process sales order details
-	err = ProcessSalesOrderDetails(order, logger.Log)
+	err = ProcessSalesOrderDetails(ctx, order)
 	if err != nil {
-		logger.Log.Error("Failed to make POST request for sales order details", zap.Error(err))
+		log.Error("Failed to make POST request for sales order details", zap.Error(err))
 		// Log an error, but continue with the processing
 	}
-	logger.Log.Info("OrderPostrunCreate: Completed processing product order")
+	log.Info("OrderPostrunCreate: Completed processing product order")
 
 	return nil
 }
@@ -384,33 +396,37 @@ func convertStringToFloat(str string) float64 {
 }
 
 // Helper function to make the API request to create the product
-func createProductAPIRequest(payload []byte) error {
-	url := "https://652fcc41115ea.xentral.biz/api/products"
+func createProductAPIRequest(ctx context.Context, payload []byte) error {
+	url := xentralURL("/api/products")
 
-	req, _ := http.NewRequest("POST", url, bytes.NewReader(payload))
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
 	req.Header.Add("accept", "text/html")
 	req.Header.Add("content-type", "application/vnd.xentral.default.v1+json")
-	req.Header.Add("authorization", "Bearer 9|X63RhGycfCWFIV8FmNZw8YliZ11Vcs9Np99k9VT8")
+	req.Header.Add("authorization", xentralAuthorization())
+	requestid.SetHeader(ctx, req)
 
-	res, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	res, err := tracing.HTTPClient.Do(req)
+	observeXentral("create_product", start, res, err)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 
 	body, _ := io.ReadAll(res.Body)
-	logger.Log.Info("Response from create product API:", zap.String("response", string(body)))
+	requestid.Logger(ctx).Info("Response from create product API:", zap.String("response", string(body)))
 
 	return nil
 }
 
-func ProcessUserDetails(order *model.Order, user *model.User, address *model.Address, log *zap.Logger) error {
+func ProcessUserDetails(ctx context.Context, order *model.Order, user *model.User, address *model.Address) error {
+	log := requestid.Logger(ctx)
 	log.Info("Processing user details started")
 
-	url := "https://652fcc41115ea.xentral.biz/api/customers"
+	url := xentralURL("/api/customers")
 
 	// Step 1: Check if the user already exists
-	customerID, err := getCustomerID(order.Email, log)
+	customerID, err := getCustomerID(ctx, order.Email, log)
 	if err != nil {
 		log.Error("Failed to get customer ID", zap.Error(err))
 		return err
@@ -463,12 +479,15 @@ func ProcessUserDetails(order *model.Order, user *model.User, address *model.Add
         }
     }`, salutationType, order.FirstName, order.LastName, address.Street, address.ZipCode, address.City, address.City, address.Country, order.Email)
 
-	req, _ := http.NewRequest("POST", url, strings.NewReader(payload))
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(payload))
 	req.Header.Add("accept", "text/html")
 	req.Header.Add("content-type", "application/vnd.xentral.default.v1+json")
-	req.Header.Add("authorization", "Bearer 9|X63RhGycfCWFIV8FmNZw8YliZ11Vcs9Np99k9VT8")
+	req.Header.Add("authorization", xentralAuthorization())
+	requestid.SetHeader(ctx, req)
 
-	res, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	res, err := tracing.HTTPClient.Do(req)
+	observeXentral("create_customer", start, res, err)
 	if err != nil {
 		return err
 	}
@@ -481,9 +500,9 @@ func ProcessUserDetails(order *model.Order, user *model.User, address *model.Add
 }
 
 // Helper function to get customer ID
-func getCustomerID(email string, log *zap.Logger) (string, error) {
-	url := fmt.Sprintf("https://652fcc41115ea.xentral.biz/api/customers?filter[0][key]=email&filter[0][value]=%s&filter[0][op]=equals", email)
-	response, err := makeGETRequest(url, log)
+func getCustomerID(ctx context.Context, email string, log *zap.Logger) (string, error) {
+	url := xentralURL(fmt.Sprintf("/api/customers?filter[0][key]=email&filter[0][value]=%s&filter[0][op]=equals", email))
+	response, err := makeGETRequest(ctx, url, log)
 	if err != nil {
 		return "", err
 	}
@@ -513,9 +532,9 @@ func getCustomerID(email string, log *zap.Logger) (string, error) {
 }
 
 // Helper function to get product ID
-func getProductID(productID string, log *zap.Logger) (string, error) {
-	url := fmt.Sprintf("https://652fcc41115ea.xentral.biz/api/products?filter[0][key]=number&filter[0][value]=%s&filter[0][op]=equals", productID)
-	response, err := makeGETRequest(url, log)
+func getProductID(ctx context.Context, productID string, log *zap.Logger) (string, error) {
+	url := xentralURL(fmt.Sprintf("/api/products?filter[0][key]=number&filter[0][value]=%s&filter[0][op]=equals", productID))
+	response, err := makeGETRequest(ctx, url, log)
 	if err != nil {
 		return "", err
 	}
@@ -532,17 +551,19 @@ func getProductID(productID string, log *zap.Logger) (string, error) {
 }
 
 // Helper function to make a GET request
-func makeGETRequest(url string, log *zap.Logger) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func makeGETRequest(ctx context.Context, url string, log *zap.Logger) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Add("accept", "application/vnd.xentral.default.v1+json")
-	req.Header.Add("authorization", "Bearer 9|X63RhGycfCWFIV8FmNZw8YliZ11Vcs9Np99k9VT8")
-
-	res, err := http.DefaultClient.Do(req)
+	req.Header.Add("authorization", xentralAuthorization())
+	requestid.SetHeader(ctx, req)
 
+	start := time.Now()
+	res, err := tracing.HTTPClient.Do(req)
+	observeXentral("get", start, res, err)
 	if res.StatusCode != http.StatusOK {
 		log.Error("Xentral API returned an error", zap.Int("status_code", res.StatusCode))
 		return nil, fmt.Errorf("xentral API returned an error: %d", res.StatusCode)
@@ -557,18 +578,19 @@ func makeGETRequest(url string, log *zap.Logger) ([]byte, error) {
 	return io.ReadAll(res.Body)
 }
 
-func ProcessSalesOrderDetails(order model.Order, log *zap.Logger) error {
+func ProcessSalesOrderDetails(ctx context.Context, order model.Order) error {
+	log := requestid.Logger(ctx)
 	log.Info("Processing sales order details started")
 
 	// Step 1: Get Customer ID from Xentral API
-	customerID, err := getCustomerID(order.Email, log)
+	customerID, err := getCustomerID(ctx, order.Email, log)
 	if err != nil {
 		log.Error("Failed to get customer ID", zap.Error(err))
 		return err
 	}
 
 	// Step 2: Get Product ID from Xentral API
-	productID, err := getProductID(order.Products[0].ProductID, log)
+	productID, err := getProductID(ctx, order.Products[0].ProductID, log)
 	if err != nil {
 		log.Error("Failed to get product ID", zap.Error(err))
 		return err
@@ -587,7 +609,7 @@ func ProcessSalesOrderDetails(order model.Order, log *zap.Logger) error {
 	}
 
 	// Step 4: Make Sales Order API Request
-	url := "https://652fcc41115ea.xentral.biz/api/salesOrders/actions/import"
+	url := xentralURL("/api/salesOrders/actions/import")
 	currentDate := time.Now().Format("2006-01-02")
 
 	// Update the payload with the new customerID and productID
@@ -651,12 +673,15 @@ func ProcessSalesOrderDetails(order model.Order, log *zap.Logger) error {
 	// Log the payload to check the format and values
 	log.Info("Sales Order Payload:", zap.String("payload", string(payloadJSON)))
 
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(payloadJSON))
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payloadJSON))
 	req.Header.Add("accept", "text/html")
 	req.Header.Add("content-type", "application/vnd.xentral.default.v1-beta+json")
-	req.Header.Add("authorization", "Bearer 9|X63RhGycfCWFIV8FmNZw8YliZ11Vcs9Np99k9VT8")
+	req.Header.Add("authorization", xentralAuthorization())
+	requestid.SetHeader(ctx, req)
 
-	res, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	res, err := tracing.HTTPClient.Do(req)
+	observeXentral("import_sales_order", start, res, err)
 	if err != nil {
 		log.Error("Failed to make sales order API request", zap.Error(err))
 		return err
@@ -668,3 +693,31 @@ func ProcessSalesOrderDetails(order model.Order, log *zap.Logger) error {
 
 	return nil
 }
+
+// liveConfig holds the Xentral settings with the latest XENTRAL_TOKEN
+var liveConfig *config.Live
+
+// Setup hands the configuration to the Xentral calls
+func Setup(live *config.Live) {
+	liveConfig = live
+}
+
+// xentralURL joins the path with XENTRAL_URL
+func xentralURL(path string) string {
+	return strings.TrimSuffix(liveConfig.Get().Xentral.URL, "/") + path
+}
+
+// xentralAuthorization is read on every call, so a rotated XENTRAL_TOKEN is used right away
+func xentralAuthorization() string {
+	return "Bearer " + liveConfig.Get().Xentral.Token
+}
+
+// observeXentral records the outcome of a Xentral API call
+func observeXentral(operation string, start time.Time, res *http.Response, err error) {
+	statusCode := 0
+	if res != nil {
+		statusCode = res.StatusCode
+	}
+
+	metrics.ObserveExternal("xentral", operation, start, statusCode, err)
+}
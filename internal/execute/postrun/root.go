@@ -3,13 +3,15 @@ package postrun
 import (
 	"bookbox-backend/internal/model"
 	"bookbox-backend/internal/request"
+	"context"
 
 	"go.uber.org/zap"
 )
 
 var (
-	CreateFunctions = map[string]func(any, *model.User) (any, error){
+	CreateFunctions = map[string]func(context.Context, any, *model.User) (any, error){
 		"order": OrderPostrunCreate,
+		"user":  UserPostrunCreate,
 	}
 
 	ReadFunctions = map[string]func(request.GetRequest, any, *model.User) (any, error){
@@ -20,8 +22,9 @@ var (
 		"sales_channel": SalesChannelPostrunRead,
 	}
 
-	UpdateFunctions = map[string]func(*request.Request, *model.User, *zap.Logger) error{
+	UpdateFunctions = map[string]func(context.Context, *request.Request, *model.User, *zap.Logger) error{
 		"order": OrderPostrunUpdate,
+		"user":  UserPostrunUpdate,
 	}
 
 	ListFunctions = map[string]func(request.GetRequest, any, *model.User) (any, error){
@@ -4,6 +4,11 @@ import (
 	"bookbox-backend/internal/database"
 	"bookbox-backend/internal/model"
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/auth"
+	"context"
+
+	"go.uber.org/zap"
 )
 
 func UserPostrunRead(request request.GetRequest, queried any, issuer *model.User) (response any, err error) {
@@ -67,3 +72,32 @@ func UserPostrunList(request request.GetRequest, queried any, issuer *model.User
 
 	return
 }
+
+// UserPostrunCreate sends the verification email for new accounts
+func UserPostrunCreate(ctx context.Context, queried any, issuer *model.User) (response any, err error) {
+	user := queried.(*model.User)
+	response = user
+
+	if !user.EmailVerified {
+		go auth.SendEmailVerification(requestid.Detach(ctx), *user, requestid.Logger(ctx))
+	}
+
+	return
+}
+
+// UserPostrunUpdate sends a new verification email when the address was changed
+func UserPostrunUpdate(ctx context.Context, request *request.Request, issuer *model.User, log *zap.Logger) (err error) {
+	if changed, _ := request.Data["email_changed"].(bool); !changed {
+		return
+	}
+
+	user := model.User{}
+	err = database.DB.Where("id = ?", request.Data["id"]).First(&user).Error
+	if err != nil {
+		return
+	}
+
+	go auth.SendEmailVerification(requestid.Detach(ctx), user, log)
+
+	return
+}
@@ -1,6 +1,7 @@
 package prerun
 
 import (
+	"bookbox-backend/internal/config"
 	"bookbox-backend/internal/database"
 	"bookbox-backend/internal/model"
 	"bookbox-backend/internal/request"
@@ -10,6 +11,23 @@ import (
 
 // OrderPrerunRead prerun functions for user
 func OrderPrerunRead(req *request.GetRequest, issuer *model.User) (err error) {
+	if issuer.Role != model.UserCustomerRole {
+		return
+	}
+
+	// customers can only read their own orders, guests use order/status with an access token
+	var count int64
+	err = database.DB.Model(&model.Order{}).
+		Where("id = ? AND user_id = ?", req.Data.ID, issuer.ID).
+		Count(&count).Error
+	if err != nil {
+		return
+	}
+
+	if count == 0 {
+		err = fmt.Errorf("order with specified id does not exist")
+	}
+
 	return
 }
 
@@ -45,8 +63,64 @@ const (
 	defaultDeliveryStatus = "open"
 )
 
+const (
+	unverifiedOrdersAllow = "allow"
+	unverifiedOrdersBlock = "block"
+	unverifiedOrdersLimit = "limit"
+)
+
+var (
+	// UNVERIFIED_ORDERS: allow, block (default) or limit to UNVERIFIED_ORDER_LIMIT orders
+	unverifiedOrdersMode = unverifiedOrdersBlock
+	unverifiedOrderLimit = int64(1)
+)
+
+// Setup applies the order section of the configuration
+func Setup(settings config.OrderConfig) {
+	// the mode is one of the constants above, the configuration rejects other values
+	unverifiedOrdersMode = settings.UnverifiedOrders
+	unverifiedOrderLimit = int64(settings.UnverifiedLimit)
+}
+
+// checkVerifiedForOrder limits ordering for customers who didn't verify their email yet
+func checkVerifiedForOrder(issuer *model.User) (err error) {
+	if issuer.Role != model.UserCustomerRole || issuer.EmailVerified {
+		return
+	}
+
+	switch unverifiedOrdersMode {
+	case unverifiedOrdersAllow:
+		return
+	case unverifiedOrdersLimit:
+		var count int64
+		err = database.DB.Model(&model.Order{}).Where("user_id = ?", issuer.ID).Count(&count).Error
+		if err != nil {
+			return
+		}
+
+		if count < unverifiedOrderLimit {
+			return
+		}
+	}
+
+	err = fmt.Errorf("email must be verified before ordering")
+	return
+}
+
 // OrderPrerunCreate prerun functions for user
 func OrderPrerunCreate(req *request.Request, issuer *model.User) (err error) {
+	err = checkVerifiedForOrder(issuer)
+	if err != nil {
+		return
+	}
+
+	// guest orders have no user, they are linked once the email is verified by an account
+	if issuer.Role == model.UserCustomerRole {
+		req.Data["user_id"] = issuer.ID
+	} else if issuer.Role != model.UserAdminRole {
+		delete(req.Data, "user_id")
+	}
+
 	if issuer.Role != "admin" {
 		req.Data["order_status"] = defaultOrderStatus
 		req.Data["payment_status"] = defaultPaymentStatus
@@ -96,6 +170,11 @@ func OrderPrerunCreate(req *request.Request, issuer *model.User) (err error) {
 			return
 		}
 
+		if row.IsWithdrawn() {
+			err = fmt.Errorf("product: %s is no longer available", row.Title)
+			return
+		}
+
 		if row.Stock == 0 {
 			err = fmt.Errorf("product: %s is out of stock", row.Title)
 			return
@@ -114,6 +193,11 @@ func OrderPrerunCreate(req *request.Request, issuer *model.User) (err error) {
 			return
 		}
 
+		if scProducts.Hidden {
+			err = fmt.Errorf("product: %s is no longer available", row.Title)
+			return
+		}
+
 		sellingPrice := row.SellingPrice
 		if scProducts.ChangedPrice != 0 {
 			sellingPrice = scProducts.ChangedPrice
@@ -30,9 +30,16 @@ func UserPrerunUpdate(request *request.Request, issuer *model.User) (err error)
 		delete(request.Data, "orders")
 		delete(request.Data, "reviews")
 		delete(request.Data, "discounts")
+		delete(request.Data, "email_verified")
+		delete(request.Data, "email_verified_at")
 	}
 
-	return validation.UserValidateUpdate(request, issuer)
+	err = validation.UserValidateUpdate(request, issuer)
+	if err != nil {
+		return
+	}
+
+	return validation.UserKeepVerification(request)
 }
 
 // UserPrerunCreate prerun functions for user
@@ -44,6 +51,8 @@ func UserPrerunCreate(request *request.Request, issuer *model.User) (err error)
 		}
 
 		request.Data["role"] = model.UserCustomerRole
+		request.Data["email_verified"] = false
+		delete(request.Data, "email_verified_at")
 	}
 
 	err = validation.UserValidateCreate(request, issuer)
@@ -3,9 +3,9 @@ package validation
 import (
 	"bookbox-backend/internal/database"
 	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/passhash"
 	"bookbox-backend/internal/request"
 	"bookbox-backend/internal/route/auth"
-	"bookbox-backend/pkg/crypto"
 	"fmt"
 	"net/mail"
 )
@@ -29,7 +29,15 @@ func UserValidateUpdate(request *request.Request, issuer *model.User) (err error
 		}
 
 		if password != "" {
-			request.Data["password"] = crypto.HashPassword(password)
+			err = passhash.PasswordPolicy.Validate(password)
+			if err != nil {
+				return
+			}
+
+			request.Data["password"], err = passhash.Hash(password)
+			if err != nil {
+				return
+			}
 		}
 
 	} else {
@@ -60,13 +68,46 @@ func UserValidateCreate(request *request.Request, issuer *model.User) (err error
 		return
 	}
 
+	err = passhash.PasswordPolicy.Validate(password)
+	if err != nil {
+		return
+	}
+
 	err = CheckIfExists(request)
 	if err != nil {
 		return
 	}
 
-	if password != "" {
-		request.Data["password"] = crypto.HashPassword(password)
+	request.Data["password"], err = passhash.Hash(password)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// UserKeepVerification keeps the stored verification state, since updates write every column.
+// Changing the email resets it and marks the request with email_changed for the postrun.
+func UserKeepVerification(request *request.Request) (err error) {
+	id, _ := request.Data["id"].(string)
+
+	user := model.User{}
+	err = database.DB.Select("id", "email", "email_verified", "email_verified_at").Where("id = ?", id).First(&user).Error
+	if err != nil {
+		err = fmt.Errorf("user with specified id does not exist")
+		return
+	}
+
+	if _, ok := request.Data["email_verified"]; !ok {
+		request.Data["email_verified"] = user.EmailVerified
+		request.Data["email_verified_at"] = user.EmailVerifiedAt
+	}
+
+	email, ok := request.Data["email"].(string)
+	if ok && email != user.Email {
+		request.Data["email_verified"] = false
+		request.Data["email_verified_at"] = 0
+		request.Data["email_changed"] = true
 	}
 
 	return
@@ -0,0 +1,99 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Manager runs the background workers with a shared root context and waits for them on shutdown
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	log    *zap.Logger
+
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+	running map[string]bool
+}
+
+func New(log *zap.Logger) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Manager{
+		ctx:     ctx,
+		cancel:  cancel,
+		log:     log,
+		running: map[string]bool{},
+	}
+}
+
+// Context is cancelled when the shutdown starts
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go starts a worker, it has to return once ctx is cancelled
+func (m *Manager) Go(name string, worker func(ctx context.Context)) {
+	m.mutex.Lock()
+	m.running[name] = true
+	m.mutex.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer func() {
+			m.mutex.Lock()
+			delete(m.running, name)
+			m.mutex.Unlock()
+
+			m.wg.Done()
+		}()
+
+		m.log.Info("worker started",
+			zap.String("worker", name),
+		)
+
+		worker(m.ctx)
+
+		m.log.Info("worker stopped",
+			zap.String("worker", name),
+		)
+	}()
+}
+
+// Shutdown cancels the workers and waits for them until ctx is done, the error names the
+// workers which did not stop in time
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("workers did not stop in time: %s", strings.Join(m.Running(), ", "))
+	}
+}
+
+// Running returns the names of the workers which have not returned yet
+func (m *Manager) Running() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	names := make([]string, 0, len(m.running))
+	for name := range m.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
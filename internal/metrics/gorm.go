@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const gormStartKey = "metrics:start"
+
+// GormPlugin times every query through GORM callbacks
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+func (p GormPlugin) Initialize(db *gorm.DB) (err error) {
+	callbacks := db.Callback()
+	for _, err = range []error{
+		callbacks.Create().Before("*").Register("metrics:before_create", before),
+		callbacks.Create().After("*").Register("metrics:after_create", after("create")),
+		callbacks.Query().Before("*").Register("metrics:before_query", before),
+		callbacks.Query().After("*").Register("metrics:after_query", after("query")),
+		callbacks.Update().Before("*").Register("metrics:before_update", before),
+		callbacks.Update().After("*").Register("metrics:after_update", after("update")),
+		callbacks.Delete().Before("*").Register("metrics:before_delete", before),
+		callbacks.Delete().After("*").Register("metrics:after_delete", after("delete")),
+		callbacks.Row().Before("*").Register("metrics:before_row", before),
+		callbacks.Row().After("*").Register("metrics:after_row", after("row")),
+		callbacks.Raw().Before("*").Register("metrics:before_raw", before),
+		callbacks.Raw().After("*").Register("metrics:after_raw", after("raw")),
+	} {
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func before(db *gorm.DB) {
+	db.InstanceSet(gormStartKey, time.Now())
+}
+
+func after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(gormStartKey)
+		if !ok {
+			return
+		}
+
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+
+		DBQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			DBQueryErrors.WithLabelValues(operation, table).Inc()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	namespace = "bookbox"
+
+	// EntityKey is the gin context key under which crud routes store the requested entity
+	EntityKey = "metricsEntity"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, entity, method and status code.",
+	}, []string{"route", "entity", "method", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, entity and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "entity", "method"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "GORM query latency by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"operation", "table"})
+
+	DBQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "GORM queries which returned an error, record not found is not counted.",
+	}, []string{"operation", "table"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Data cache lookups by result (hit, miss).",
+	}, []string{"result"})
+
+	SyncRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_runs_total",
+		Help:      "Catalog sync runs by result (success, error).",
+	}, []string{"result"})
+
+	SyncDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_run_duration_seconds",
+		Help:      "Duration of catalog sync runs.",
+		Buckets:   []float64{60, 300, 600, 1800, 3600, 7200, 14400},
+	})
+
+	SyncProducts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_products_total",
+		Help:      "Products written by the catalog sync by action (created, updated, withdrawn, failed).",
+	}, []string{"action"})
+
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Orders waiting in the ebook queue and the dead letter queue.",
+	}, []string{"queue"})
+
+	ExternalCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "external_calls_total",
+		Help:      "Calls to external services by service, operation and outcome (success, error).",
+	}, []string{"service", "operation", "outcome"})
+
+	ExternalDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "external_call_duration_seconds",
+		Help:      "Latency of calls to external services.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+)
+
+// ObserveHTTP records one finished request, route is the registered path so ids in urls don't create new series
+func ObserveHTTP(route, entity, method string, status int, start time.Time) {
+	if route == "" {
+		route = "unmatched"
+	}
+
+	HTTPRequests.WithLabelValues(route, entity, method, strconv.Itoa(status)).Inc()
+	HTTPDuration.WithLabelValues(route, entity, method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveExternal records one call to an external service, status codes of 400 and above count as errors
+func ObserveExternal(service, operation string, start time.Time, statusCode int, err error) {
+	outcome := "success"
+	if err != nil || statusCode >= 400 {
+		outcome = "error"
+	}
+
+	ExternalCalls.WithLabelValues(service, operation, outcome).Inc()
+	ExternalDuration.WithLabelValues(service, operation).Observe(time.Since(start).Seconds())
+}
+
+// CacheLookup records a data cache hit or miss
+func CacheLookup(found bool) {
+	if found {
+		CacheRequests.WithLabelValues("hit").Inc()
+		return
+	}
+
+	CacheRequests.WithLabelValues("miss").Inc()
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	APIKeyPrefix = "bbk_"
+)
+
+type APIKey struct {
+	Root
+	Name          string   `json:"name,omitempty" gorm:"column:name"`
+	Prefix        string   `json:"prefix,omitempty" gorm:"column:prefix"`
+	KeyHash       string   `json:"-" gorm:"column:key_hash;uniqueIndex"`
+	Entities      []string `json:"entities" gorm:"column:entities;serializer:json"`
+	Operations    []string `json:"operations" gorm:"column:operations;serializer:json"`
+	SalesChannels []string `json:"sales_channels" gorm:"column:sales_channels;serializer:json"`
+	ExpiresAt     int64    `json:"expires_at,omitempty" gorm:"column:expires_at"`
+	LastUsedAt    int64    `json:"last_used_at,omitempty" gorm:"column:last_used_at"`
+	RevokedAt     int64    `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+}
+
+func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
+	if len(k.ID) == 0 {
+		id := uuid.New().String()
+		k.ID = id
+	}
+
+	if k.Active == nil {
+		value := true
+		k.Active = &value
+	}
+	k.CreatedAt = time.Now()
+
+	return nil
+}
+
+// IsUsable reports whether the key is active, not revoked and not expired
+func (k *APIKey) IsUsable() bool {
+	if k.Active != nil && !*k.Active {
+		return false
+	}
+
+	if k.RevokedAt != 0 {
+		return false
+	}
+
+	if k.ExpiresAt != 0 && time.Now().Unix() > k.ExpiresAt {
+		return false
+	}
+
+	return true
+}
+
+// Allows checks the entity and operation scopes, an empty scope allows everything
+func (k *APIKey) Allows(operation, entity string) bool {
+	if k == nil {
+		return true
+	}
+
+	return inScope(k.Operations, operation) && inScope(k.Entities, entity)
+}
+
+// AllowsSalesChannel checks the sales channel scope, an empty scope allows everything
+func (k *APIKey) AllowsSalesChannel(salesChannelID string) bool {
+	if k == nil {
+		return true
+	}
+
+	return inScope(k.SalesChannels, salesChannelID)
+}
+
+func inScope(scope []string, value string) bool {
+	if len(scope) == 0 {
+		return true
+	}
+
+	for _, s := range scope {
+		if s == "*" || s == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditLoginFailed = "login_failed"
+	AuditLoginLocked = "login_locked"
+
+	AuditTwoFactorFailed = "two_factor_failed"
+	AuditTwoFactorReset  = "two_factor_reset"
+
+	AuditDataExport  = "data_export"
+	AuditDataErasure = "data_erasure"
+)
+
+type AuditLog struct {
+	Root
+	Action    string `json:"action,omitempty" gorm:"column:action;index"`
+	UserID    string `json:"user_id,omitempty" gorm:"column:user_id;index"`
+	Email     string `json:"email,omitempty" gorm:"column:email;index"`
+	IP        string `json:"ip,omitempty" gorm:"column:ip"`
+	UserAgent string `json:"user_agent,omitempty" gorm:"column:user_agent"`
+	Details   string `json:"details,omitempty" gorm:"column:details"`
+	Date      int64  `json:"date,omitempty" gorm:"column:date"`
+}
+
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if len(a.ID) == 0 {
+		id := uuid.New().String()
+		a.ID = id
+	}
+
+	if a.Active == nil {
+		value := true
+		a.Active = &value
+	}
+
+	a.Date = time.Now().Unix()
+	a.CreatedAt = time.Now()
+	return nil
+}
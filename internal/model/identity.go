@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to the subject of an external identity provider
+type UserIdentity struct {
+	Root
+	UserID   string `json:"user_id" gorm:"column:user_id;index;not null"`
+	User     *User  `json:"user,omitempty" gorm:"foreignKey:user_id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Provider string `json:"provider" gorm:"column:provider;not null;uniqueIndex:idx_identity_subject"`
+	Subject  string `json:"subject" gorm:"column:subject;not null;uniqueIndex:idx_identity_subject"`
+	Email    string `json:"email,omitempty" gorm:"column:email"`
+}
+
+func (i *UserIdentity) BeforeCreate(tx *gorm.DB) error {
+	if len(i.ID) == 0 {
+		id := uuid.New().String()
+		i.ID = id
+	}
+
+	if i.Active == nil {
+		value := true
+		i.Active = &value
+	}
+
+	i.CreatedAt = time.Now()
+	return nil
+}
@@ -35,6 +35,27 @@ type Product struct {
 	Reviews         []Review              `json:"reviews,omitempty" gorm:"foreignKey:product_id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Categories      []ProductCategory     `json:"categories,omitempty" gorm:"foreignKey:product_id;constraint:OnDelete:SET NULL;"`
 	SalesChannels   []SalesChannelProduct `json:"sales_channels,omitempty" gorm:"foreignKey:product_id;constraint:OnDelete:SET NULL;"`
+
+	// Availability tells a temporary stock of 0 apart from titles which will not come back,
+	// UnavailableReason is the wholesaler's reason for the latter
+	Availability      string `json:"availability,omitempty" gorm:"column:availability;not null;default:available"`
+	UnavailableReason string `json:"unavailable_reason,omitempty" gorm:"column:unavailable_reason"`
+}
+
+const (
+	ProductAvailable  = "available"
+	ProductOutOfStock = "out_of_stock"
+
+	// ProductUnavailable is out of print or permanently not available
+	ProductUnavailable = "unavailable"
+
+	// ProductDeleted was removed from the catalog with an ONIX deletion
+	ProductDeleted = "deleted"
+)
+
+// IsWithdrawn is set for products which cannot be ordered any more
+func (p *Product) IsWithdrawn() bool {
+	return p.Availability == ProductUnavailable || p.Availability == ProductDeleted
 }
 
 type ProductCategory struct {
@@ -30,6 +30,11 @@ type SalesChannelProduct struct {
 	Product        Product `json:"product" gorm:"foreignKey:product_id;constraint:OnDelete:SET NULL;"`
 	ChangedPrice   float64 `json:"changed_price"`
 	ChangedTitle   string  `json:"changed_title"`
+
+	// Hidden removes the product from the sales channel, the catalog sync hides withdrawn
+	// titles this way with CATALOG_WITHDRAWN=hide
+	Hidden       bool   `json:"hidden" gorm:"column:hidden;not null;default:false"`
+	HiddenReason string `json:"hidden_reason,omitempty" gorm:"column:hidden_reason"`
 }
 
 func (pc *SalesChannelProduct) BeforeCreate(tx *gorm.DB) error {
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// ScheduledJob is the state of a scheduler job, shared by every instance
+type ScheduledJob struct {
+	Name     string `json:"name" gorm:"primaryKey"`
+	Schedule string `json:"schedule"`
+
+	// LastWindow is the latest window of the schedule which ran successfully, the windows after
+	// it are due
+	LastWindow     time.Time  `json:"last_window"`
+	LastStartedAt  *time.Time `json:"last_started_at,omitempty"`
+	LastFinishedAt *time.Time `json:"last_finished_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+
+	// Requested runs the job on the next check, outside of its schedule
+	Requested bool `json:"requested"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
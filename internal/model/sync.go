@@ -5,4 +5,13 @@ type Sync struct {
 	IsFullSynced      bool  `json:"is_full_synced"`
 	LastOnixSyncDate  int64 `json:"last_onix_sync_date"`
 	LastAnnotSyncDate int64 `json:"last_annot_sync_date"`
+
+	// Checkpoint lists the files an unfinished sync already wrote, so it resumes after them
+	Checkpoint []string `json:"checkpoint" gorm:"serializer:json"`
+
+	// Paused stops the scheduled runs, runs requested by an admin still start
+	Paused bool `json:"paused"`
+
+	// RequestedMode is set by an admin to start a full or partial run, the worker clears it
+	RequestedMode string `json:"requested_mode,omitempty"`
 }
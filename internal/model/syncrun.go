@@ -0,0 +1,84 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	SyncRunRunning     = "running"
+	SyncRunSucceeded   = "succeeded"
+	SyncRunFailed      = "failed"
+	SyncRunInterrupted = "interrupted"
+
+	// SyncModeFull imports the full archives, SyncModePartial only the ones since the last run
+	SyncModeFull    = "full"
+	SyncModePartial = "partial"
+
+	SyncTriggerSchedule = "schedule"
+	SyncTriggerManual   = "manual"
+)
+
+// SyncCounts are the products written by a sync run or one of its files
+type SyncCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Withdrawn int `json:"withdrawn"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+}
+
+func (c *SyncCounts) Add(other SyncCounts) {
+	c.Created += other.Created
+	c.Updated += other.Updated
+	c.Withdrawn += other.Withdrawn
+	c.Skipped += other.Skipped
+	c.Failed += other.Failed
+}
+
+// SyncRun is one run of the ONIX or annotation sync with the files it wrote
+type SyncRun struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	Job         string     `json:"job" gorm:"column:job;index"`
+	TriggeredBy string     `json:"triggered_by" gorm:"column:triggered_by"`
+	Mode        string     `json:"mode" gorm:"column:mode"`
+	Status      string     `json:"status" gorm:"column:status;index"`
+	StartedAt   time.Time  `json:"started_at" gorm:"column:started_at;index"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty" gorm:"column:finished_at"`
+	SyncCounts  `gorm:"embedded;embeddedPrefix:products_"`
+	Error       string        `json:"error,omitempty" gorm:"column:error"`
+	Files       []SyncRunFile `json:"files,omitempty" gorm:"foreignKey:sync_run_id;constraint:OnDelete:CASCADE"`
+}
+
+// SyncRunFile is an ONIX file or annotation archive written by a sync run
+type SyncRunFile struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	SyncRunID  string     `json:"sync_run_id" gorm:"index"`
+	Phase      string     `json:"phase" gorm:"column:phase"`
+	Archive    string     `json:"archive" gorm:"column:archive"`
+	Name       string     `json:"name" gorm:"column:name"`
+	StartedAt  time.Time  `json:"started_at" gorm:"column:started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" gorm:"column:finished_at"`
+	SyncCounts `gorm:"embedded;embeddedPrefix:products_"`
+	Error      string `json:"error,omitempty" gorm:"column:error"`
+}
+
+func (r *SyncRun) BeforeCreate(tx *gorm.DB) error {
+	if len(r.ID) == 0 {
+		id := uuid.New().String()
+		r.ID = id
+	}
+
+	return nil
+}
+
+func (f *SyncRunFile) BeforeCreate(tx *gorm.DB) error {
+	if len(f.ID) == 0 {
+		id := uuid.New().String()
+		f.ID = id
+	}
+
+	return nil
+}
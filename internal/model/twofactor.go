@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserTwoFactor holds the TOTP enrollment of a user, it is kept apart from User so
+// crud updates of the user can't overwrite it
+type UserTwoFactor struct {
+	Root
+	UserID        string   `json:"user_id" gorm:"column:user_id;uniqueIndex;not null"`
+	User          *User    `json:"user,omitempty" gorm:"foreignKey:user_id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Secret        string   `json:"-" gorm:"column:secret"`
+	Enabled       bool     `json:"enabled" gorm:"column:enabled;not null;default:false"`
+	EnabledAt     int64    `json:"enabled_at,omitempty" gorm:"column:enabled_at"`
+	RecoveryCodes []string `json:"-" gorm:"column:recovery_codes;serializer:json"`
+}
+
+func (t *UserTwoFactor) BeforeCreate(tx *gorm.DB) error {
+	if len(t.ID) == 0 {
+		id := uuid.New().String()
+		t.ID = id
+	}
+
+	if t.Active == nil {
+		value := true
+		t.Active = &value
+	}
+
+	t.CreatedAt = time.Now()
+	return nil
+}
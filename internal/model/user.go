@@ -17,13 +17,15 @@ type User struct {
 	Root
 	Role              string     `json:"role,omitempty" gorm:"type:user_role;not null;column:role"`
 	Password          string     `json:"password" gorm:"column:password"`
-	Salutation        string     `json:"salutation,omitempty" gorm:"type:user_salutation;not null;column:salutation"`
+	Salutation        string     `json:"salutation,omitempty" gorm:"type:user_salutation;column:salutation"`
 	FirstName         string     `json:"first_name,omitempty" gorm:"column:first_name"`
 	Type              string     `json:"type" gorm:"type:user_type;not null;column:type"`
 	LastName          string     `json:"last_name,omitempty" gorm:"column:last_name"`
 	ZipCode           string     `json:"zip_code,omitempty" gorm:"column:zip_code"`
 	City              string     `json:"city,omitempty" gorm:"column:city"`
 	Email             string     `json:"email,omitempty" gorm:"column:email"`
+	EmailVerified     bool       `json:"email_verified" gorm:"column:email_verified;not null;default:false"`
+	EmailVerifiedAt   int64      `json:"email_verified_at,omitempty" gorm:"column:email_verified_at"`
 	PhoneNumber       string     `json:"phone_number,omitempty" gorm:"column:phone_number"`
 	Country           string     `json:"country,omitempty" gorm:"column:country"`
 	Reviews           []Review   `json:"reviews,omitempty" gorm:"foreignKey:user_id;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
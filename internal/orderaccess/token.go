@@ -0,0 +1,92 @@
+package orderaccess
+
+import (
+	"bookbox-backend/internal/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// liveConfig provides ORDER_ACCESS_SECRET, without it no tokens are issued. It is read on
+	// every use, tokens signed with a rotated secret become invalid.
+	liveConfig *config.Live
+
+	// TTL of issued tokens
+	TTL time.Duration
+)
+
+// Setup applies the order section of the configuration
+func Setup(live *config.Live) {
+	liveConfig = live
+	TTL = live.Get().Orders.AccessTTL
+}
+
+func secret() []byte {
+	return []byte(liveConfig.Get().Orders.AccessSecret)
+}
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, secret())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewToken returns a token which grants read access to the order until it expires
+func NewToken(orderID string) (string, error) {
+	if len(secret()) == 0 {
+		return "", fmt.Errorf("ORDER_ACCESS_SECRET is not set")
+	}
+
+	payload := orderID + "." + strconv.FormatInt(time.Now().Add(TTL).Unix(), 10)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+
+	return encoded + "." + sign(payload), nil
+}
+
+// Verify returns the order of a valid, unexpired token
+func Verify(token string) (orderID string, err error) {
+	if len(secret()) == 0 {
+		err = fmt.Errorf("ORDER_ACCESS_SECRET is not set")
+		return
+	}
+
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		err = fmt.Errorf("token is malformed")
+		return
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return
+	}
+
+	payload := string(raw)
+	if !hmac.Equal([]byte(signature), []byte(sign(payload))) {
+		err = fmt.Errorf("token signature is invalid")
+		return
+	}
+
+	orderID, expiry, found := strings.Cut(payload, ".")
+	if !found {
+		err = fmt.Errorf("token is malformed")
+		return
+	}
+
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return
+	}
+
+	if time.Now().Unix() > expiresAt {
+		err = fmt.Errorf("token is expired")
+		return
+	}
+
+	return
+}
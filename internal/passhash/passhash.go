@@ -0,0 +1,169 @@
+package passhash
+
+import (
+	"bookbox-backend/pkg/crypto"
+	cryptorand "crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id parameters, hashes stored with other parameters are rehashed on login
+const (
+	argonTime    uint32 = 3
+	argonMemory  uint32 = 64 * 1024
+	argonThreads uint8  = 2
+	argonKeyLen  uint32 = 32
+	saltLen             = 16
+
+	argonPrefix = "$argon2id$"
+)
+
+var (
+	errMalformedHash = fmt.Errorf("stored password hash is malformed")
+
+	// dummyHash is verified when there is no hash to check, so the response time doesn't tell
+	// whether an account exists. It uses the current parameters and matches no password.
+	dummyHash = fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argonPrefix,
+		argon2.Version,
+		argonMemory,
+		argonTime,
+		argonThreads,
+		base64.RawStdEncoding.EncodeToString(make([]byte, saltLen)),
+		base64.RawStdEncoding.EncodeToString(make([]byte, argonKeyLen)),
+	)
+)
+
+// Hash returns a self describing argon2id hash in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func Hash(password string) (string, error) {
+	salt := make([]byte, saltLen)
+	_, err := cryptorand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argonPrefix,
+		argon2.Version,
+		argonMemory,
+		argonTime,
+		argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify checks the password against an argon2id hash or a legacy salt.sha256 hash
+func Verify(password, stored string) (bool, error) {
+	if strings.HasPrefix(stored, argonPrefix) {
+		return verifyArgon2id(password, stored)
+	}
+
+	return verifyLegacy(password, stored)
+}
+
+// VerifyDummy takes as long as verifying a current hash and always fails, it is used for
+// logins of unknown users
+func VerifyDummy(password string) {
+	verifyArgon2id(password, dummyHash)
+}
+
+// NeedsRehash reports whether the stored hash is legacy or uses outdated parameters
+func NeedsRehash(stored string) bool {
+	if !strings.HasPrefix(stored, argonPrefix) {
+		return true
+	}
+
+	params, _, _, err := decodeArgon2id(stored)
+	if err != nil {
+		return true
+	}
+
+	return params != currentParams()
+}
+
+type argonParams struct {
+	version int
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+func currentParams() argonParams {
+	return argonParams{
+		version: argon2.Version,
+		memory:  argonMemory,
+		time:    argonTime,
+		threads: argonThreads,
+	}
+}
+
+func decodeArgon2id(stored string) (params argonParams, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=65536,t=3,p=2", salt, hash
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		err = errMalformedHash
+		return
+	}
+
+	_, err = fmt.Sscanf(parts[2], "v=%d", &params.version)
+	if err != nil {
+		err = errMalformedHash
+		return
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads)
+	if err != nil {
+		err = errMalformedHash
+		return
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		err = errMalformedHash
+		return
+	}
+
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		err = errMalformedHash
+		return
+	}
+
+	return
+}
+
+func verifyArgon2id(password, stored string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(stored)
+	if err != nil {
+		return false, err
+	}
+
+	if params.version != argon2.Version {
+		return false, fmt.Errorf("unsupported argon2 version %d", params.version)
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
+
+// verifyLegacy checks the old salt.sha256(salt.password) format
+func verifyLegacy(password, stored string) (bool, error) {
+	splits := strings.Split(stored, ".")
+	if len(splits) < 2 {
+		return false, errMalformedHash
+	}
+
+	salt := splits[0]
+	computed := crypto.SHA256(fmt.Sprintf("%s.%s", salt, password))
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(splits[1])) == 1, nil
+}
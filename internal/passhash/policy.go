@@ -0,0 +1,76 @@
+package passhash
+
+import (
+	"bookbox-backend/internal/config"
+	"fmt"
+	"unicode"
+)
+
+type Policy struct {
+	MinLength      int
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+}
+
+var (
+	// PasswordPolicy is the password section of the configuration
+	PasswordPolicy Policy
+)
+
+// Setup applies the password section of the configuration
+func Setup(settings config.PasswordConfig) {
+	PasswordPolicy = Policy(settings)
+}
+
+// Validate checks the password against the policy
+func (p Policy) Validate(password string) (err error) {
+	length := len([]rune(password))
+	if length < p.MinLength {
+		err = fmt.Errorf("password must be at least %d characters long", p.MinLength)
+		return
+	}
+
+	if p.MaxLength > 0 && length > p.MaxLength {
+		err = fmt.Errorf("password cannot be longer than %d characters", p.MaxLength)
+		return
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSpecial = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		err = fmt.Errorf("password must contain an uppercase letter")
+		return
+	}
+
+	if p.RequireLower && !hasLower {
+		err = fmt.Errorf("password must contain a lowercase letter")
+		return
+	}
+
+	if p.RequireDigit && !hasDigit {
+		err = fmt.Errorf("password must contain a digit")
+		return
+	}
+
+	if p.RequireSpecial && !hasSpecial {
+		err = fmt.Errorf("password must contain a special character")
+		return
+	}
+
+	return
+}
@@ -274,7 +274,7 @@ func Relate(entity string, relation request.Relationship, db *gorm.DB) *gorm.DB
 		case "sales_channels":
 			where := makeCondition(relation.RelationParams, "sales_channels", false)
 			db = db.
-				Joins("JOIN sales_channel_products ON products.id = sales_channel_products.product_id").
+				Joins("JOIN sales_channel_products ON products.id = sales_channel_products.product_id AND NOT sales_channel_products.hidden").
 				Joins("JOIN sales_channels ON sales_channels.id = sales_channel_products.sales_channel_id").
 				Where(where.Main, where.Values...)
 
@@ -39,6 +39,7 @@ type Response struct {
 	Data       any      `json:"data,omitempty"`
 	NextOffset int      `json:"next_offset,omitempty"`
 	Total      int      `json:"total,omitempty"`
+	RequestID  string   `json:"request_id,omitempty"`
 }
 
 type Filter struct {
@@ -0,0 +1,80 @@
+package requestid
+
+import (
+	"bookbox-backend/pkg/logger"
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// Header carries the request ID from clients and on outbound calls
+	Header = "X-Request-ID"
+
+	// ContextKey is the gin context key of the request ID
+	ContextKey = "id"
+)
+
+type contextKey struct{}
+
+var (
+	// incoming IDs are only accepted when they can't break log lines or headers
+	validID = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,128}$`)
+)
+
+// Accept returns the incoming ID if it is usable, otherwise a new UUID
+func Accept(incoming string) string {
+	if validID.MatchString(incoming) {
+		return incoming
+	}
+
+	return uuid.New().String()
+}
+
+// WithID returns a copy of ctx carrying the request ID
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID of a gin or standard context, "" if there is none
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if ginCtx, ok := ctx.(*gin.Context); ok {
+		return ginCtx.GetString(ContextKey)
+	}
+
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Detach returns a background context with only the request ID and the current span, for
+// work which outlives the request, gin contexts must not be used after the handler returned
+func Detach(ctx context.Context) context.Context {
+	detached := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	return WithID(detached, FromContext(ctx))
+}
+
+// Logger returns the logger with the request ID attached to every line
+func Logger(ctx context.Context) *zap.Logger {
+	id := FromContext(ctx)
+	if id == "" {
+		return logger.Log
+	}
+
+	return logger.Log.With(zap.String("requestId", id))
+}
+
+// SetHeader forwards the request ID on an outbound request
+func SetHeader(ctx context.Context, req *http.Request) {
+	if id := FromContext(ctx); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
@@ -0,0 +1,134 @@
+package apikey
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/auth"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func CreateHandler(ctx *gin.Context) {
+	var (
+		createRequest  = request.Request{}
+		createResponse = request.Response{}
+	)
+
+	// bind input data to request format
+	err := ctx.ShouldBindJSON(&createRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, createResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.Any("data", createRequest.Data),
+	))
+
+	log.Info("apikey/create started")
+
+	issuer, err := auth.GetIssuer(ctx)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, createResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("only admins can call this route")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, createResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	name, _ := createRequest.Data["name"].(string)
+	if name == "" {
+		err = fmt.Errorf("name is empty")
+		log.Error("Data missing fields",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, createResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	apiKey := model.APIKey{
+		Name: name,
+	}
+
+	apiKey.Entities, err = toStringSlice(createRequest.Data["entities"])
+	if err == nil {
+		apiKey.Operations, err = toStringSlice(createRequest.Data["operations"])
+	}
+	if err == nil {
+		apiKey.SalesChannels, err = toStringSlice(createRequest.Data["sales_channels"])
+	}
+	if err == nil {
+		apiKey.ExpiresAt, err = parseExpiry(createRequest.Data["expires_at"])
+	}
+	if err != nil {
+		log.Error("Failed to parse input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, createResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	key, hash, err := generateKey()
+	if err != nil {
+		log.Error("failed to generate api key",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, createResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	apiKey.KeyHash = hash
+	apiKey.Prefix = displayPrefix(key)
+
+	err = database.DB.Create(&apiKey).Error
+	if err != nil {
+		log.Error("failed to create api key",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, createResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("apikey/create finished",
+		zap.String("apiKeyId", apiKey.ID),
+	)
+
+	// the plaintext key is only ever returned here
+	createResponse.Data = map[string]any{
+		"id":      apiKey.ID,
+		"key":     key,
+		"api_key": apiKey,
+	}
+	createResponse.Status = true
+	ctx.JSON(200, createResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "apikey/create", CreateHandler)
+}
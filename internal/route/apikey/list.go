@@ -0,0 +1,70 @@
+package apikey
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/auth"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func ListHandler(ctx *gin.Context) {
+	var (
+		listResponse = request.Response{}
+	)
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("apikey/list started")
+
+	issuer, err := auth.GetIssuer(ctx)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("only admins can call this route")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	apiKeys := []model.APIKey{}
+	err = database.DB.Order("created_at desc").Find(&apiKeys).Error
+	if err != nil {
+		log.Error("failed to list api keys",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("apikey/list finished",
+		zap.Int("count", len(apiKeys)),
+	)
+
+	listResponse.Data = apiKeys
+	listResponse.Total = len(apiKeys)
+	listResponse.Status = true
+	ctx.JSON(200, listResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "apikey/list", ListHandler)
+}
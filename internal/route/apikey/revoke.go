@@ -0,0 +1,93 @@
+package apikey
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/auth"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func RevokeHandler(ctx *gin.Context) {
+	var (
+		revokeRequest  = request.Request{}
+		revokeResponse = request.Response{}
+	)
+
+	// bind input data to request format
+	err := ctx.ShouldBindJSON(&revokeRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, revokeResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	id, _ := revokeRequest.Data["id"].(string)
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.String("apiKeyId", id),
+	))
+
+	log.Info("apikey/revoke started")
+
+	issuer, err := auth.GetIssuer(ctx)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, revokeResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("only admins can call this route")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, revokeResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	res := database.DB.Model(&model.APIKey{}).
+		Where("id = ? AND revoked_at = 0", id).
+		UpdateColumn("revoked_at", time.Now().Unix())
+	if res.Error != nil {
+		log.Error("failed to revoke api key",
+			zap.Error(res.Error),
+		)
+
+		fail.ReturnError(ctx, revokeResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	if res.RowsAffected == 0 {
+		err = fmt.Errorf("api key with specified id does not exist or is already revoked")
+		log.Error("failed to revoke api key",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, revokeResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	log.Info("apikey/revoke finished")
+
+	revokeResponse.Status = true
+	ctx.JSON(200, revokeResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "apikey/revoke", RevokeHandler)
+}
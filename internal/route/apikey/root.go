@@ -0,0 +1,75 @@
+package apikey
+
+import (
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/server/middlewares"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const (
+	keyBytes = 32
+)
+
+// generateKey creates a new plaintext key and its stored hash
+func generateKey() (key string, hash string, err error) {
+	raw := make([]byte, keyBytes)
+	_, err = rand.Read(raw)
+	if err != nil {
+		return
+	}
+
+	key = model.APIKeyPrefix + hex.EncodeToString(raw)
+	hash = middlewares.HashAPIKey(key)
+	return
+}
+
+// displayPrefix is the non secret part of the key, used to recognise it in listings
+func displayPrefix(key string) string {
+	return key[:len(model.APIKeyPrefix)+8]
+}
+
+func toStringSlice(value any) (out []string, err error) {
+	if value == nil {
+		return
+	}
+
+	values, ok := value.([]any)
+	if !ok {
+		err = fmt.Errorf("scope must be a list of strings")
+		return
+	}
+
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			err = fmt.Errorf("scope must be a list of strings")
+			return
+		}
+		out = append(out, s)
+	}
+
+	return
+}
+
+func parseExpiry(value any) (expiresAt int64, err error) {
+	if value == nil {
+		return
+	}
+
+	expiry, ok := value.(float64)
+	if !ok {
+		err = fmt.Errorf("expires_at must be a unix timestamp")
+		return
+	}
+
+	expiresAt = int64(expiry)
+	if expiresAt != 0 && expiresAt <= time.Now().Unix() {
+		err = fmt.Errorf("expires_at must be in the future")
+		return
+	}
+
+	return
+}
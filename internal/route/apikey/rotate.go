@@ -0,0 +1,130 @@
+package apikey
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/auth"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RotateHandler issues a new key with the same scopes and revokes the old one
+func RotateHandler(ctx *gin.Context) {
+	var (
+		rotateRequest  = request.Request{}
+		rotateResponse = request.Response{}
+	)
+
+	// bind input data to request format
+	err := ctx.ShouldBindJSON(&rotateRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, rotateResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	id, _ := rotateRequest.Data["id"].(string)
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.String("apiKeyId", id),
+	))
+
+	log.Info("apikey/rotate started")
+
+	issuer, err := auth.GetIssuer(ctx)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, rotateResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("only admins can call this route")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, rotateResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	oldKey := model.APIKey{}
+	res := database.DB.Where("id = ?", id).Limit(1).Find(&oldKey)
+	if res.Error != nil || res.RowsAffected == 0 || !oldKey.IsUsable() {
+		err = fmt.Errorf("api key with specified id does not exist or is revoked")
+		log.Error("failed to read api key",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, rotateResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	key, hash, err := generateKey()
+	if err != nil {
+		log.Error("failed to generate api key",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, rotateResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	newKey := model.APIKey{
+		Name:          oldKey.Name,
+		Prefix:        displayPrefix(key),
+		KeyHash:       hash,
+		Entities:      oldKey.Entities,
+		Operations:    oldKey.Operations,
+		SalesChannels: oldKey.SalesChannels,
+		ExpiresAt:     oldKey.ExpiresAt,
+	}
+
+	tx := database.DB.Begin()
+	err = tx.Create(&newKey).Error
+	if err == nil {
+		err = tx.Model(&oldKey).UpdateColumn("revoked_at", time.Now().Unix()).Error
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Error("failed to rotate api key",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, rotateResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("apikey/rotate finished",
+		zap.String("newApiKeyId", newKey.ID),
+	)
+
+	// the plaintext key is only ever returned here
+	rotateResponse.Data = map[string]any{
+		"id":      newKey.ID,
+		"key":     key,
+		"api_key": newKey,
+	}
+	rotateResponse.Status = true
+	ctx.JSON(200, rotateResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "apikey/rotate", RotateHandler)
+}
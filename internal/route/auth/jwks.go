@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"bookbox-backend/internal/config"
+	"bookbox-backend/internal/server/router"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys of the access tokens, including the keys that are
+// still accepted after a rotation, so other services can verify tokens offline
+func JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(200, gin.H{
+		"keys": config.GetJWT().Access.JWKS(),
+	})
+}
+
+func init() {
+	router.Router.Handle("GET", ".well-known/jwks.json", JWKS)
+}
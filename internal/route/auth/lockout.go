@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/pkg/logger"
+	"bookbox-backend/pkg/redis"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	loginFailKeyPrefix = "login_fail:"
+	loginLockKeyPrefix = "login_lock:"
+)
+
+var (
+	// failures within loginFailureWindow needed before the account is locked for the client
+	loginLockoutThreshold = 5
+	loginFailureWindow    = 15 * time.Minute
+
+	// failures from every address within loginFailureWindow needed before the account is locked
+	// for all clients
+	loginAccountLockoutThreshold = 20
+
+	// the lock doubles with every further failure, up to loginLockoutMax
+	loginLockoutBase = time.Minute
+	loginLockoutMax  = time.Hour
+)
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// lockoutKeys returns the counter per account and client IP and the counter per account. The
+// first locks out only the address which guesses, so others can't lock the owner out with a
+// few tries. The second has a higher threshold and stops guessing from many addresses.
+func lockoutKeys(email string, ip string) (clientKey string, accountKey string) {
+	email = normalizeEmail(email)
+	return email + ":" + ip, "account:" + email
+}
+
+// loginLockedFor returns how long the account stays locked for the client, 0 if it isn't
+func loginLockedFor(email string, ip string) (lockedFor time.Duration) {
+	clientKey, accountKey := lockoutKeys(email, ip)
+
+	for _, key := range []string{clientKey, accountKey} {
+		ttl, err := redis.Client.TTL(loginLockKeyPrefix + key).Result()
+		if err == nil && ttl > lockedFor {
+			lockedFor = ttl
+		}
+	}
+
+	return
+}
+
+// registerLoginFailure counts the failure and returns the lock duration if the account got locked
+// for the client or for everyone
+func registerLoginFailure(email string, ip string) (lockedFor time.Duration) {
+	clientKey, accountKey := lockoutKeys(email, ip)
+
+	lockedFor = countLoginFailure(clientKey, loginLockoutThreshold)
+	if accountLockedFor := countLoginFailure(accountKey, loginAccountLockoutThreshold); accountLockedFor > lockedFor {
+		lockedFor = accountLockedFor
+	}
+
+	return
+}
+
+// countLoginFailure increments the failures of key and locks it once threshold is reached
+func countLoginFailure(key string, threshold int) (lockedFor time.Duration) {
+	failKey := loginFailKeyPrefix + key
+	failTTL := loginFailureWindow + loginLockoutMax
+
+	// the counter is created with its expiry, a crash before the Expire below can't leave a
+	// counter which never expires
+	_, err := redis.Client.SetNX(failKey, 0, failTTL).Result()
+	if err != nil {
+		logger.Log.Warn("failed to count login failure",
+			zap.Error(err),
+		)
+		return
+	}
+
+	count, err := redis.Client.Incr(failKey).Result()
+	if err != nil {
+		logger.Log.Warn("failed to count login failure",
+			zap.Error(err),
+		)
+		return
+	}
+
+	// every failure extends the window, so slow guessing is still counted
+	redis.Client.Expire(failKey, failTTL)
+
+	if count < int64(threshold) {
+		return
+	}
+
+	exponent := float64(count - int64(threshold))
+	lockedFor = time.Duration(float64(loginLockoutBase) * math.Pow(2, exponent))
+	if lockedFor > loginLockoutMax || lockedFor <= 0 {
+		lockedFor = loginLockoutMax
+	}
+
+	err = redis.Client.Set(loginLockKeyPrefix+key, count, lockedFor).Err()
+	if err != nil {
+		logger.Log.Warn("failed to lock login",
+			zap.Error(err),
+		)
+	}
+
+	return
+}
+
+// resetLoginFailures clears the counter of the client after a successful login. The counter of
+// the account is kept, a login of the owner must not give a guesser on other addresses new tries.
+func resetLoginFailures(email string, ip string) {
+	clientKey, _ := lockoutKeys(email, ip)
+	redis.Client.Del(loginFailKeyPrefix+clientKey, loginLockKeyPrefix+clientKey)
+}
+
+// auditLogin stores login failures and lockouts in the audit log
+func auditLogin(ctx *gin.Context, action string, email string, details string) {
+	entry := model.AuditLog{
+		Action:    action,
+		Email:     normalizeEmail(email),
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		Details:   details,
+	}
+
+	err := database.DB.Create(&entry).Error
+	if err != nil {
+		requestid.Logger(ctx).Error("failed to write audit log",
+			zap.String("action", action),
+			zap.Error(err),
+		)
+	}
+}
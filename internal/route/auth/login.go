@@ -3,13 +3,14 @@ package auth
 import (
 	"bookbox-backend/internal/database"
 	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/passhash"
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/route/fail"
 	"bookbox-backend/internal/server/router"
-	"bookbox-backend/pkg/crypto"
 	"bookbox-backend/pkg/logger"
 	"fmt"
-	"strings"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -26,15 +27,15 @@ func Login(ctx *gin.Context) {
 	// Parse the posted JSON data.
 	err := ctx.ShouldBind(&loginRequest)
 	if err != nil {
-		logger.Log.Error("Failed to bind input data",
+		requestid.Logger(ctx).Error("Failed to bind input data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, loginResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, loginResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
-	log := logger.Log.WithOptions(zap.Fields(
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
 		zap.Any("data", loginRequest.Data),
 	))
 
@@ -56,16 +57,60 @@ func Login(ctx *gin.Context) {
 		return
 	}
 
+	if lockedFor := loginLockedFor(email, ctx.ClientIP()); lockedFor > 0 {
+		err = fmt.Errorf("too many failed login attempts, try again later")
+		log.Warn("auth/login locked",
+			zap.Duration("lockedFor", lockedFor),
+		)
+
+		auditLogin(ctx, model.AuditLoginLocked, email, "login attempted while locked")
+		ctx.Header("Retry-After", strconv.Itoa(int(lockedFor.Seconds())+1))
+		fail.ReturnError(ctx, loginResponse, []string{err.Error()}, 429, log)
+		return
+	}
+
 	user, err := CheckPassword(email, password)
 	if err != nil {
 		log.Error("auth/login failed",
 			zap.Error(err),
 		)
 
+		auditLogin(ctx, model.AuditLoginFailed, email, err.Error())
+
+		if lockedFor := registerLoginFailure(email, ctx.ClientIP()); lockedFor > 0 {
+			log.Warn("auth/login locked after repeated failures",
+				zap.Duration("lockedFor", lockedFor),
+			)
+
+			auditLogin(ctx, model.AuditLoginLocked, email, fmt.Sprintf("locked for %s", lockedFor))
+		}
+
 		fail.ReturnError(ctx, loginResponse, []string{err.Error()}, 400, log)
 		return
 	}
 
+	challenge, err := loginChallenge(user.ID)
+	if err != nil {
+		log.Error("failed to check two-factor authentication",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, loginResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	// the token pair is issued by auth/login/2fa
+	if challenge != nil {
+		log.Info("login waiting for second factor")
+
+		loginResponse.Data = challenge
+		loginResponse.Status = true
+		ctx.JSON(200, loginResponse)
+		return
+	}
+
+	resetLoginFailures(email, ctx.ClientIP())
+
 	// Generate JWT pair.
 	err = NewTokenPair(ctx, user.ID)
 	if err != nil {
@@ -86,23 +131,54 @@ func Login(ctx *gin.Context) {
 func CheckPassword(email string, password string) (user model.User, err error) {
 	// Look for the provided user.
 	err = database.DB.Select("id", "password").Where("email = ?", email).First(&user).Error
-	// User not found.
+	// User not found, answer like a wrong password so accounts can't be enumerated. The
+	// password is still hashed, so the response takes as long as for an existing account.
 	if err != nil {
+		passhash.VerifyDummy(password)
+		err = fmt.Errorf("username or password are incorrect")
 		return
 	}
 
-	splits := strings.Split(user.Password, ".")
-	salt := splits[0]
-	password = fmt.Sprintf("%s.%s", salt, password)
-	password = crypto.SHA256(password)
-	if len(splits) < 2 || password != splits[1] {
+	ok, err := passhash.Verify(password, user.Password)
+	if err != nil || !ok {
 		err = fmt.Errorf("username or password are incorrect")
 		return
 	}
 
+	// upgrade legacy or outdated hashes while the plaintext is known
+	if passhash.NeedsRehash(user.Password) {
+		rehashPassword(user.ID, password)
+	}
+
 	return
 }
 
+// rehashPassword stores a fresh hash, failures are logged and retried on the next login
+func rehashPassword(userID string, password string) {
+	hashed, err := passhash.Hash(password)
+	if err != nil {
+		logger.Log.Warn("failed to rehash password",
+			zap.String("userId", userID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	// UpdateColumn skips the User hooks, which would otherwise recreate the addresses
+	err = database.DB.Model(&model.User{}).Where("id = ?", userID).UpdateColumn("password", hashed).Error
+	if err != nil {
+		logger.Log.Warn("failed to store rehashed password",
+			zap.String("userId", userID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	logger.Log.Info("password hash upgraded",
+		zap.String("userId", userID),
+	)
+}
+
 func init() {
 	router.Router.Handle("POST", "auth/login", Login)
 }
@@ -2,9 +2,9 @@ package auth
 
 import (
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/route/fail"
 	"bookbox-backend/internal/server/router"
-	"bookbox-backend/pkg/logger"
 	"fmt"
 
 	"github.com/gin-gonic/gin"
@@ -17,7 +17,7 @@ func Logout(ctx *gin.Context) {
 		logoutResponse = request.Response{}
 	)
 
-	log := logger.Log.WithOptions(zap.Fields())
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
 
 	log.Info("logout started")
 
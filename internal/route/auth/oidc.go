@@ -0,0 +1,540 @@
+package auth
+
+import (
+	"bookbox-backend/internal/config"
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"bookbox-backend/pkg/logger"
+	"bookbox-backend/pkg/redis"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStatePrefix = "oidc_state:"
+	oidcStateTTL    = 10 * time.Minute
+
+	// oidcDiscoveryTimeout bounds the discovery of a provider, a login doesn't wait longer for it
+	oidcDiscoveryTimeout = 10 * time.Second
+)
+
+var (
+	// providers don't know the salutation, accounts created through them get this one if it
+	// is configured and none otherwise until the customer sets it in the profile
+	oidcDefaultSalutation string
+	oidcDefaultType       = "Privat"
+
+	// oidcProviders are the configured providers by name
+	oidcProviders = map[string]config.OIDCProvider{}
+
+	// oidcClientsMutex guards oidcClients and oidcDiscovery, it is never held during a discovery
+	oidcClients      = map[string]*oidcClient{}
+	oidcClientsMutex sync.Mutex
+
+	// oidcDiscovery runs one discovery per provider at a time, an unreachable provider only
+	// delays the logins through it
+	oidcDiscovery = map[string]*sync.Mutex{}
+)
+
+type oidcClient struct {
+	settings config.OIDCProvider
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// oidcState is kept in Redis between the redirect to the provider and the callback
+type oidcState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Nonce         string `json:"nonce"`
+}
+
+// verified accepts true and "true", some providers send the claim as string
+func (c oidcClaims) verified() bool {
+	switch value := c.EmailVerified.(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+
+	return false
+}
+
+// getOIDCClient discovers the provider on first use, so an unreachable provider
+// doesn't prevent the service from starting
+func getOIDCClient(ctx context.Context, name string) (*oidcClient, error) {
+	settings, ok := oidcProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("login provider does not exist")
+	}
+
+	client, discovery := cachedOIDCClient(name)
+	if client != nil {
+		return client, nil
+	}
+
+	discovery.Lock()
+	defer discovery.Unlock()
+
+	// another login may have discovered the provider while this one waited
+	client, _ = cachedOIDCClient(name)
+	if client != nil {
+		return client, nil
+	}
+
+	discoveryCtx, cancel := context.WithTimeout(ctx, oidcDiscoveryTimeout)
+	defer cancel()
+
+	provider, err := oidc.NewProvider(discoveryCtx, settings.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	client = &oidcClient{
+		settings: settings,
+		oauth2: oauth2.Config{
+			ClientID:     settings.ClientID,
+			ClientSecret: settings.ClientSecret,
+			RedirectURL:  settings.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       settings.Scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: settings.ClientID}),
+	}
+
+	oidcClientsMutex.Lock()
+	oidcClients[name] = client
+	oidcClientsMutex.Unlock()
+
+	return client, nil
+}
+
+// cachedOIDCClient returns the discovered client of the provider, or the lock to discover it
+func cachedOIDCClient(name string) (*oidcClient, *sync.Mutex) {
+	oidcClientsMutex.Lock()
+	defer oidcClientsMutex.Unlock()
+
+	if client, ok := oidcClients[name]; ok {
+		return client, nil
+	}
+
+	discovery, ok := oidcDiscovery[name]
+	if !ok {
+		discovery = &sync.Mutex{}
+		oidcDiscovery[name] = discovery
+	}
+
+	return nil, discovery
+}
+
+func randomString() (string, error) {
+	raw := make([]byte, 32)
+	_, err := cryptorand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(raw), nil
+}
+
+// OIDCStart returns the authorization URL of the provider, the frontend redirects the browser to it
+func OIDCStart(ctx *gin.Context) {
+	var (
+		startRequest  = request.Request{}
+		startResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&startRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, startResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	providerName, _ := startRequest.Data["provider"].(string)
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.String("provider", providerName),
+	))
+
+	log.Info("oidc start started")
+
+	client, err := getOIDCClient(ctx, providerName)
+	if err != nil {
+		log.Error("auth/oidc/start failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, startResponse, []string{"login provider is not available"}, 400, log)
+		return
+	}
+
+	state, stored, err := newOIDCState(providerName)
+	if err != nil {
+		log.Error("failed to store oidc state",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, startResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	startResponse.Data = map[string]any{
+		"state": state,
+		"authorization_url": client.oauth2.AuthCodeURL(state,
+			oidc.Nonce(stored.Nonce),
+			oauth2.S256ChallengeOption(stored.Verifier),
+		),
+	}
+
+	log.Info("oidc start finished")
+
+	startResponse.Status = true
+	ctx.JSON(200, startResponse)
+}
+
+// OIDCCallback exchanges the code returned by the provider and logs the user in
+func OIDCCallback(ctx *gin.Context) {
+	var (
+		callbackRequest  = request.Request{}
+		callbackResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&callbackRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, callbackResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("oidc callback started")
+
+	state, _ := callbackRequest.Data["state"].(string)
+	code, _ := callbackRequest.Data["code"].(string)
+	if state == "" || code == "" {
+		err = fmt.Errorf("state or code is missing")
+		log.Error("auth/oidc/callback failed")
+
+		fail.ReturnError(ctx, callbackResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	stored, err := consumeOIDCState(state)
+	if err != nil {
+		log.Error("auth/oidc/callback failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("login request is invalid or expired")
+		fail.ReturnError(ctx, callbackResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("provider", stored.Provider),
+	))
+
+	client, err := getOIDCClient(ctx, stored.Provider)
+	if err != nil {
+		log.Error("auth/oidc/callback failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, callbackResponse, []string{"login provider is not available"}, 400, log)
+		return
+	}
+
+	subject, claims, err := client.exchange(ctx.Request.Context(), code, stored)
+	if err != nil {
+		log.Error("auth/oidc/callback failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("login with provider failed")
+		fail.ReturnError(ctx, callbackResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	user, err := findOIDCUser(client.settings, subject, claims)
+	if err != nil {
+		log.Error("auth/oidc/callback failed",
+			zap.String("subject", subject),
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, callbackResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", user.ID),
+	))
+
+	challenge, err := loginChallenge(user.ID)
+	if err != nil {
+		log.Error("failed to check two-factor authentication",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, callbackResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	// the token pair is issued by auth/login/2fa
+	if challenge != nil {
+		log.Info("oidc callback waiting for second factor")
+
+		callbackResponse.Data = challenge
+		callbackResponse.Status = true
+		ctx.JSON(200, callbackResponse)
+		return
+	}
+
+	// Generate JWT pair.
+	err = NewTokenPair(ctx, user.ID)
+	if err != nil {
+		log.Error("Failed to generate token",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, callbackResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	log.Info("oidc callback finished")
+
+	callbackResponse.Status = true
+	ctx.JSON(200, callbackResponse)
+}
+
+// newOIDCState stores the PKCE verifier and nonce of a login under a random state
+func newOIDCState(provider string) (state string, stored oidcState, err error) {
+	state, err = randomString()
+	if err != nil {
+		return
+	}
+
+	stored = oidcState{
+		Provider: provider,
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	stored.Nonce, err = randomString()
+	if err != nil {
+		return
+	}
+
+	raw, err := json.Marshal(stored)
+	if err != nil {
+		return
+	}
+
+	err = redis.Client.Set(oidcStatePrefix+state, string(raw), oidcStateTTL).Err()
+	return
+}
+
+// consumeOIDCState returns the stored state and makes sure it is used only once
+func consumeOIDCState(state string) (stored oidcState, err error) {
+	key := oidcStatePrefix + state
+
+	raw, err := redis.Client.Get(key).Result()
+	if err != nil {
+		return
+	}
+
+	deleted, err := redis.Client.Del(key).Result()
+	if err != nil {
+		return
+	}
+
+	if deleted == 0 {
+		err = fmt.Errorf("state was already used")
+		return
+	}
+
+	err = json.Unmarshal([]byte(raw), &stored)
+	return
+}
+
+// exchange redeems the code with the PKCE verifier and verifies the returned ID token
+func (c *oidcClient) exchange(ctx context.Context, code string, stored oidcState) (subject string, claims oidcClaims, err error) {
+	token, err := c.oauth2.Exchange(ctx, code, oauth2.VerifierOption(stored.Verifier))
+	if err != nil {
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		err = fmt.Errorf("id_token is missing in token response")
+		return
+	}
+
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return
+	}
+
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return
+	}
+
+	if claims.Nonce != stored.Nonce {
+		err = fmt.Errorf("nonce does not match")
+		return
+	}
+
+	return idToken.Subject, claims, nil
+}
+
+// findOIDCUser returns the user linked to the subject, links an existing account with the same
+// verified email or creates a new account
+func findOIDCUser(settings config.OIDCProvider, subject string, claims oidcClaims) (user model.User, err error) {
+	identity := model.UserIdentity{}
+	res := database.DB.Where("provider = ? AND subject = ?", settings.Name, subject).Limit(1).Find(&identity)
+	if res.Error != nil {
+		return user, res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		if claims.Email == "" || !claims.verified() {
+			return user, fmt.Errorf("provider did not confirm the email")
+		}
+
+		res = database.DB.Where("email = ?", claims.Email).Limit(1).Find(&user)
+		if res.Error != nil {
+			return user, res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			if !settings.AllowSignup {
+				return user, fmt.Errorf("account does not exist")
+			}
+
+			user, err = createOIDCUser(settings, subject, claims)
+			return
+		}
+
+		if user.Role != settings.Role {
+			return user, fmt.Errorf("account can't log in with this provider")
+		}
+
+		err = database.DB.Create(&model.UserIdentity{
+			UserID:   user.ID,
+			Provider: settings.Name,
+			Subject:  subject,
+			Email:    claims.Email,
+		}).Error
+		if err != nil {
+			return
+		}
+
+		// the provider confirmed the email
+		if !user.EmailVerified {
+			err = MarkEmailVerified(user.ID)
+			if err != nil {
+				return
+			}
+		}
+	} else {
+		res = database.DB.Where("id = ?", identity.UserID).Limit(1).Find(&user)
+		if res.Error != nil {
+			return user, res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return user, fmt.Errorf("account does not exist")
+		}
+	}
+
+	if user.Role != settings.Role {
+		return user, fmt.Errorf("account can't log in with this provider")
+	}
+
+	if user.Active != nil && !*user.Active {
+		return user, fmt.Errorf("account is disabled")
+	}
+
+	return
+}
+
+func createOIDCUser(settings config.OIDCProvider, subject string, claims oidcClaims) (user model.User, err error) {
+	user = model.User{
+		Role:            settings.Role,
+		Salutation:      oidcDefaultSalutation,
+		Type:            oidcDefaultType,
+		FirstName:       claims.GivenName,
+		LastName:        claims.FamilyName,
+		Email:           claims.Email,
+		EmailVerified:   true,
+		EmailVerifiedAt: time.Now().Unix(),
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		create := tx
+		if user.Salutation == "" {
+			// an empty string is not a salutation, the column stays null
+			create = tx.Omit("salutation")
+		}
+
+		err := create.Create(&user).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&model.UserIdentity{
+			UserID:   user.ID,
+			Provider: settings.Name,
+			Subject:  subject,
+			Email:    claims.Email,
+		}).Error
+	})
+	if err != nil {
+		return
+	}
+
+	if _, linkErr := LinkGuestOrders(user.ID, user.Email); linkErr != nil {
+		logger.Log.Warn("failed to link guest orders",
+			zap.String("userId", user.ID),
+			zap.Error(linkErr),
+		)
+	}
+
+	return
+}
+
+func init() {
+	router.Router.Handle("POST", "auth/oidc/start", OIDCStart)
+	router.Router.Handle("POST", "auth/oidc/callback", OIDCCallback)
+}
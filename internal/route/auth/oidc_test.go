@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"bookbox-backend/internal/config"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+)
+
+// mockIdP is an OpenID provider with discovery, keys and a token endpoint which answers the
+// code "valid-code" with the ID token built by idToken
+type mockIdP struct {
+	*httptest.Server
+
+	key      *rsa.PrivateKey
+	verifier string
+	idToken  func(issuer string) jwt.MapClaims
+	signWith *rsa.PrivateKey
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &mockIdP{key: key, signWith: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "valid-code" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+
+		idp.verifier = r.PostForm.Get("code_verifier")
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.idToken(idp.URL))
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString(idp.signWith)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     signed,
+		})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+func useMockProvider(t *testing.T, idp *mockIdP) *oidcClient {
+	providers := oidcProviders
+	oidcProviders = map[string]config.OIDCProvider{
+		"mock": {
+			Name:        "mock",
+			Issuer:      idp.URL,
+			ClientID:    "bookbox",
+			RedirectURL: "https://shop.example/login/callback",
+			Scopes:      []string{"openid", "email", "profile"},
+			Role:        "customer",
+		},
+	}
+	t.Cleanup(func() {
+		oidcProviders = providers
+
+		oidcClientsMutex.Lock()
+		delete(oidcClients, "mock")
+		oidcClientsMutex.Unlock()
+	})
+
+	client, err := getOIDCClient(context.Background(), "mock")
+	if err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+
+	return client
+}
+
+func TestOIDCExchange(t *testing.T) {
+	stored := oidcState{
+		Provider: "mock",
+		Verifier: oauth2.GenerateVerifier(),
+		Nonce:    "nonce",
+	}
+
+	claims := func(change func(jwt.MapClaims)) func(issuer string) jwt.MapClaims {
+		return func(issuer string) jwt.MapClaims {
+			c := jwt.MapClaims{
+				"iss":            issuer,
+				"aud":            "bookbox",
+				"sub":            "subject-1",
+				"iat":            time.Now().Unix(),
+				"exp":            time.Now().Add(time.Hour).Unix(),
+				"nonce":          "nonce",
+				"email":          "reader@example.com",
+				"email_verified": "true",
+				"given_name":     "Rea",
+				"family_name":    "Der",
+			}
+			if change != nil {
+				change(c)
+			}
+			return c
+		}
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		idToken  func(issuer string) jwt.MapClaims
+		signWith *rsa.PrivateKey
+		wantErr  bool
+	}{
+		{name: "valid", code: "valid-code", idToken: claims(nil)},
+		{name: "unknown code", code: "other-code", idToken: claims(nil), wantErr: true},
+		{name: "nonce of another login", code: "valid-code", idToken: claims(func(c jwt.MapClaims) { c["nonce"] = "other" }), wantErr: true},
+		{name: "other audience", code: "valid-code", idToken: claims(func(c jwt.MapClaims) { c["aud"] = "other-client" }), wantErr: true},
+		{name: "other issuer", code: "valid-code", idToken: claims(func(c jwt.MapClaims) { c["iss"] = "https://idp.example" }), wantErr: true},
+		{name: "expired", code: "valid-code", idToken: claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }), wantErr: true},
+		{name: "signed with unknown key", code: "valid-code", idToken: claims(nil), signWith: otherKey, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			idp.idToken = test.idToken
+			if test.signWith != nil {
+				idp.signWith = test.signWith
+			}
+
+			client := useMockProvider(t, idp)
+
+			subject, got, err := client.exchange(context.Background(), test.code, stored)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got subject %q", subject)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("exchange failed: %v", err)
+			}
+
+			if idp.verifier != stored.Verifier {
+				t.Errorf("code_verifier = %q, want the stored verifier", idp.verifier)
+			}
+
+			if subject != "subject-1" {
+				t.Errorf("subject = %q, want subject-1", subject)
+			}
+
+			if got.Email != "reader@example.com" || !got.verified() || got.GivenName != "Rea" || got.FamilyName != "Der" {
+				t.Errorf("unexpected claims %+v", got)
+			}
+		})
+	}
+}
+
+func TestOIDCAuthorizationURL(t *testing.T) {
+	client := useMockProvider(t, newMockIdP(t))
+
+	stored := oidcState{Verifier: oauth2.GenerateVerifier(), Nonce: "nonce"}
+	authURL, err := url.Parse(client.oauth2.AuthCodeURL("state",
+		oidc.Nonce(stored.Nonce),
+		oauth2.S256ChallengeOption(stored.Verifier),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := authURL.Query()
+	for param, want := range map[string]string{
+		"client_id":             "bookbox",
+		"redirect_uri":          "https://shop.example/login/callback",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge_method": "S256",
+		"code_challenge":        oauth2.S256ChallengeFromVerifier(stored.Verifier),
+	} {
+		if got := query.Get(param); got != want {
+			t.Errorf("%s = %q, want %q", param, got, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"bookbox-backend/pkg/crypto"
+	"bookbox-backend/pkg/redis"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// One-time tokens are stored hashed in Redis under <purpose>:<hash> with the user ID as value.
+// <purpose>_user:<userID> points to the latest token, so issuing a new one invalidates the old one.
+const (
+	PasswordResetPurpose = "password_reset"
+	EmailVerifyPurpose   = "email_verify"
+	TwoFactorPurpose     = "two_factor"
+)
+
+// NewOneTimeToken creates a single-use token for the user and purpose
+func NewOneTimeToken(purpose string, userID string, ttl time.Duration) (token string, err error) {
+	raw := make([]byte, 32)
+	_, err = cryptorand.Read(raw)
+	if err != nil {
+		return
+	}
+
+	token = hex.EncodeToString(raw)
+	tokenHash := crypto.SHA256(token)
+	userKey := purpose + "_user:" + userID
+
+	previousHash, _ := redis.Client.Get(userKey).Result()
+	if previousHash != "" {
+		redis.Client.Del(purpose + ":" + previousHash)
+	}
+
+	err = redis.Client.Set(purpose+":"+tokenHash, userID, ttl).Err()
+	if err != nil {
+		return
+	}
+
+	err = redis.Client.Set(userKey, tokenHash, ttl).Err()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// ConsumeOneTimeToken returns the user of the token and makes sure it is used only once
+func ConsumeOneTimeToken(purpose string, token string) (userID string, err error) {
+	key := purpose + ":" + crypto.SHA256(token)
+
+	userID, err = redis.Client.Get(key).Result()
+	if err != nil {
+		return
+	}
+
+	// only the request which deletes the key may use it
+	deleted, err := redis.Client.Del(key).Result()
+	if err != nil {
+		return
+	}
+
+	if deleted == 0 {
+		err = fmt.Errorf("token already used")
+		return
+	}
+
+	redis.Client.Del(purpose + "_user:" + userID)
+
+	return
+}
+
+// PeekOneTimeToken returns the user of the token without using it up
+func PeekOneTimeToken(purpose string, token string) (string, error) {
+	return redis.Client.Get(purpose + ":" + crypto.SHA256(token)).Result()
+}
+
+// TokenLink adds the token to the configured link, which may already have a query string or
+// fragment
+func TokenLink(link string, token string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
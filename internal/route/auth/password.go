@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/passhash"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"bookbox-backend/internal/server/sendgrid"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	passwordResetTTL         time.Duration
+	passwordResetURL         string
+	passwordResetTemplateID  string
+	passwordResetSenderEmail string
+)
+
+func ForgotPassword(ctx *gin.Context) {
+	var (
+		forgotRequest  = request.Request{}
+		forgotResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&forgotRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, forgotResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("password forgot started")
+
+	email, _ := forgotRequest.Data["email"].(string)
+	if email == "" {
+		err = fmt.Errorf("email is missing")
+		log.Error("auth/password/forgot failed")
+
+		fail.ReturnError(ctx, forgotResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	// the email is sent in the background, so the response does not reveal if the account exists
+	go sendPasswordReset(requestid.Detach(ctx), email, log)
+
+	log.Info("password forgot finished")
+
+	forgotResponse.Status = true
+	ctx.JSON(200, forgotResponse)
+}
+
+func ResetPassword(ctx *gin.Context) {
+	var (
+		resetRequest  = request.Request{}
+		resetResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&resetRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, resetResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("password reset started")
+
+	token, _ := resetRequest.Data["token"].(string)
+	password, _ := resetRequest.Data["password"].(string)
+	if token == "" || password == "" {
+		err = fmt.Errorf("token or password is missing")
+		log.Error("auth/password/reset failed")
+
+		fail.ReturnError(ctx, resetResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	err = passhash.PasswordPolicy.Validate(password)
+	if err != nil {
+		log.Error("auth/password/reset failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, resetResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	userID, err := ConsumeOneTimeToken(PasswordResetPurpose, token)
+	if err != nil {
+		log.Error("auth/password/reset failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("token is invalid or expired")
+		fail.ReturnError(ctx, resetResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", userID),
+	))
+
+	hashed, err := passhash.Hash(password)
+	if err != nil {
+		log.Error("failed to hash password",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, resetResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	// UpdateColumn skips the User hooks, which would otherwise recreate the addresses
+	err = database.DB.Model(&model.User{}).Where("id = ?", userID).UpdateColumn("password", hashed).Error
+	if err != nil {
+		log.Error("failed to update password",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, resetResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	// revoke every token pair of the user
+	err = DestroyAllTokens(userID)
+	if err != nil {
+		log.Error("failed to revoke token pairs",
+			zap.Error(err),
+		)
+	}
+
+	log.Info("password reset finished")
+
+	resetResponse.Status = true
+	ctx.JSON(200, resetResponse)
+}
+
+func sendPasswordReset(ctx context.Context, email string, log *zap.Logger) {
+	user := model.User{}
+	res := database.DB.Select("id", "email").Where("email = ?", email).Limit(1).Find(&user)
+	if res.Error != nil || res.RowsAffected == 0 {
+		log.Info("password reset requested for unknown email")
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", user.ID),
+	))
+
+	token, err := NewOneTimeToken(PasswordResetPurpose, user.ID, passwordResetTTL)
+	if err != nil {
+		log.Error("failed to create password reset token",
+			zap.Error(err),
+		)
+		return
+	}
+
+	resetURL, err := TokenLink(passwordResetURL, token)
+	if err != nil {
+		log.Error("failed to build password reset link",
+			zap.Error(err),
+		)
+		return
+	}
+
+	notify := sendgrid.SendGrid{
+		From:       passwordResetSenderEmail,
+		To:         user.Email,
+		TemplateID: passwordResetTemplateID,
+		DynamicTemplateData: map[string]interface{}{
+			"reset_url":  resetURL,
+			"expires_in": int(passwordResetTTL.Minutes()),
+		},
+	}
+
+	err = notify.SendEmail(ctx)
+	if err != nil {
+		log.Error("failed to send password reset email",
+			zap.Error(err),
+		)
+		return
+	}
+
+	log.Info("password reset email sent")
+}
+
+func init() {
+	router.Router.Handle("POST", "auth/password/forgot", ForgotPassword)
+	router.Router.Handle("POST", "auth/password/reset", ResetPassword)
+}
@@ -0,0 +1,30 @@
+package auth
+
+import "bookbox-backend/internal/config"
+
+// Setup applies the auth settings, it is called by the server before the routes are served
+func Setup(cfg *config.Config) {
+	passwordResetURL = cfg.Auth.PasswordResetURL
+	passwordResetTTL = cfg.Auth.PasswordResetTTL
+	passwordResetTemplateID = cfg.SendGrid.PasswordResetTemplateID
+	passwordResetSenderEmail = cfg.SendGrid.SenderEmail
+
+	emailVerifyURL = cfg.Auth.EmailVerifyURL
+	emailVerifyTTL = cfg.Auth.EmailVerifyTTL
+	emailVerifyTemplateID = cfg.SendGrid.VerifyTemplateID
+	emailVerifySenderEmail = cfg.SendGrid.SenderEmail
+
+	oidcProviders = cfg.OIDC.Provider
+	oidcDefaultSalutation = cfg.Auth.OIDCDefaultSalutation
+
+	twoFactorIssuer = cfg.Auth.TOTPIssuer
+	for _, role := range cfg.Auth.TwoFactorRequiredRoles {
+		twoFactorRequiredRoles[role] = true
+	}
+
+	loginLockoutThreshold = cfg.Auth.LockoutThreshold
+	loginAccountLockoutThreshold = cfg.Auth.AccountLockoutThreshold
+	loginFailureWindow = cfg.Auth.FailureWindow
+	loginLockoutBase = cfg.Auth.LockoutBase
+	loginLockoutMax = cfg.Auth.LockoutMax
+}
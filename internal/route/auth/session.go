@@ -0,0 +1,332 @@
+package auth
+
+import (
+	"bookbox-backend/internal/config"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"bookbox-backend/pkg/logger"
+	"bookbox-backend/pkg/redis"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// last used is only written once per interval to avoid a Redis write on every request
+	sessionTouchInterval = time.Minute
+)
+
+// Session is the public view of a token pair, the refresh token itself is never returned
+type Session struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Current   bool      `json:"current"`
+}
+
+func (c TokenPairCache) expiry() time.Time {
+	if !c.ExpiresAt.IsZero() {
+		return c.ExpiresAt
+	}
+
+	// entries written before expiresAt was stored
+	return c.EntryTime.Add(config.GetJWT().RefreshExpiry)
+}
+
+// loadSessions returns the cached token pairs of the user keyed by refresh token
+func loadSessions(userID string) (sessions map[string]TokenPairCache, err error) {
+	raw, err := redis.Client.HGetAll(userID).Result()
+	if err != nil {
+		return
+	}
+
+	sessions = make(map[string]TokenPairCache, len(raw))
+	for refreshToken, cacheJSON := range raw {
+		cached := TokenPairCache{}
+		if json.Unmarshal([]byte(cacheJSON), &cached) != nil {
+			// unreadable entries can't be used for login anymore
+			redis.Client.HDel(userID, refreshToken)
+			continue
+		}
+
+		sessions[refreshToken] = cached
+	}
+
+	return
+}
+
+// pruneExpiredSessions removes token pairs whose refresh token has expired
+func pruneExpiredSessions(userID string) {
+	sessions, err := loadSessions(userID)
+	if err != nil {
+		logger.Log.Warn("failed to load sessions for cleanup",
+			zap.String("userId", userID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	now := time.Now()
+	for refreshToken, cached := range sessions {
+		if now.After(cached.expiry()) {
+			redis.Client.HDel(userID, refreshToken)
+		}
+	}
+}
+
+// touchSessionScript replaces the token pair only if it is still the one that was read, a pair
+// revoked or refreshed in the meantime is not written back. It returns 1 if the pair was replaced.
+const touchSessionScript = `
+if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then
+	redis.call("HSET", KEYS[1], ARGV[1], ARGV[3])
+	return 1
+end
+return 0
+`
+
+// touchSession updates the last used time of the token pair
+func touchSession(userID string, refreshToken string, cacheJSON string, cached TokenPairCache) {
+	if time.Since(cached.LastUsed) < sessionTouchInterval {
+		return
+	}
+
+	cached.LastUsed = time.Now()
+	raw, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+
+	err = redis.Client.Eval(touchSessionScript, []string{userID}, refreshToken, cacheJSON, string(raw)).Err()
+	if err != nil {
+		logger.Log.Warn("failed to touch session",
+			zap.String("userId", userID),
+			zap.Error(err),
+		)
+	}
+}
+
+// DestroyAllTokens revokes every token pair of the user
+func DestroyAllTokens(userID string) error {
+	return redis.Client.Del(userID).Err()
+}
+
+// DestroySession revokes the token pair with the given session id
+func DestroySession(userID string, sessionID string) (err error) {
+	sessions, err := loadSessions(userID)
+	if err != nil {
+		return
+	}
+
+	for refreshToken, cached := range sessions {
+		if cached.RefreshTokenUUID == sessionID {
+			return redis.Client.HDel(userID, refreshToken).Err()
+		}
+	}
+
+	return fmt.Errorf("session does not exist")
+}
+
+func ListSessions(ctx *gin.Context) {
+	var (
+		listResponse = request.Response{}
+	)
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("sessions list started")
+
+	issuer, err := GetIssuer(ctx)
+	if err != nil || issuer.ID == "" {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	pruneExpiredSessions(issuer.ID)
+
+	cachedSessions, err := loadSessions(issuer.ID)
+	if err != nil {
+		log.Error("failed to load sessions",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	currentRefreshToken := ctx.Request.Header.Get(RefreshTokenHeader)
+	sessions := make([]Session, 0, len(cachedSessions))
+	for refreshToken, cached := range cachedSessions {
+		sessions = append(sessions, Session{
+			ID:        cached.RefreshTokenUUID,
+			UserAgent: cached.UserAgent,
+			IP:        cached.IP,
+			CreatedAt: cached.EntryTime,
+			LastUsed:  cached.LastUsed,
+			ExpiresAt: cached.expiry(),
+			Current:   refreshToken == currentRefreshToken,
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsed.After(sessions[j].LastUsed)
+	})
+
+	log.Info("sessions list finished",
+		zap.Int("count", len(sessions)),
+	)
+
+	listResponse.Data = sessions
+	listResponse.Total = len(sessions)
+	listResponse.Status = true
+	ctx.JSON(200, listResponse)
+}
+
+// RevokeSessions revokes one session by id, or every session with "all": true
+func RevokeSessions(ctx *gin.Context) {
+	var (
+		revokeRequest  = request.Request{}
+		revokeResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&revokeRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, revokeResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.Any("data", revokeRequest.Data),
+	))
+
+	log.Info("sessions revoke started")
+
+	issuer, err := GetIssuer(ctx)
+	if err != nil || issuer.ID == "" {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, revokeResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	all, _ := revokeRequest.Data["all"].(bool)
+	sessionID, _ := revokeRequest.Data["session_id"].(string)
+
+	switch {
+	case all:
+		err = DestroyAllTokens(issuer.ID)
+	case sessionID != "":
+		err = DestroySession(issuer.ID, sessionID)
+	default:
+		err = fmt.Errorf("session_id or all is missing")
+	}
+	if err != nil {
+		log.Error("auth/sessions/revoke failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, revokeResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	log.Info("sessions revoke finished")
+
+	revokeResponse.Status = true
+	ctx.JSON(200, revokeResponse)
+}
+
+// ForceLogout lets admins revoke every session of a user
+func ForceLogout(ctx *gin.Context) {
+	var (
+		logoutRequest  = request.Request{}
+		logoutResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&logoutRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, logoutResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	userID, _ := logoutRequest.Data["user_id"].(string)
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.String("userId", userID),
+	))
+
+	log.Info("force logout started")
+
+	issuer, err := GetIssuer(ctx)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, logoutResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("only admins can call this route")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, logoutResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if userID == "" {
+		err = fmt.Errorf("user_id is missing")
+		log.Error("auth/sessions/logout_user failed")
+
+		fail.ReturnError(ctx, logoutResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	err = DestroyAllTokens(userID)
+	if err != nil {
+		log.Error("failed to revoke sessions",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, logoutResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("force logout finished")
+
+	logoutResponse.Status = true
+	ctx.JSON(200, logoutResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "auth/sessions", ListSessions)
+	router.Router.Handle("POST", "auth/sessions/revoke", RevokeSessions)
+	router.Router.Handle("POST", "auth/sessions/logout_user", ForceLogout)
+}
@@ -3,9 +3,10 @@ package auth
 import (
 	"bookbox-backend/internal/config"
 	"bookbox-backend/internal/model"
-	"bookbox-backend/pkg/logger"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/pkg/redis"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +23,10 @@ type TokenPairCache struct {
 	AccessTokenUUID  string    `json:"accessTokenUUID"`
 	RefreshTokenUUID string    `json:"refreshTokenUUID"`
 	EntryTime        time.Time `json:"entryTime"`
+	LastUsed         time.Time `json:"lastUsed"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	UserAgent        string    `json:"userAgent"`
+	IP               string    `json:"ip"`
 }
 
 const (
@@ -39,7 +44,7 @@ func DestroyToken(ctx *gin.Context, userID string) error {
 }
 
 func RefreshToken(ctx *gin.Context) (issuer *model.User, err error) {
-	logger.Log.Info("validating refresh token")
+	requestid.Logger(ctx).Info("validating refresh token")
 
 	tokenToValidate := ctx.Request.Header.Get(RefreshTokenHeader)
 	if err != nil {
@@ -63,30 +68,36 @@ func NewTokenPair(ctx *gin.Context, userID string) error {
 	refreshTokenUUID := uuid.New().String()
 	accessTokenUUID := uuid.New().String()
 
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.RegisteredClaims{
+	// one snapshot, so a reload in between can't mix keys of two configurations
+	jwtConfig := config.GetJWT()
+
+	refreshToken, err := jwtConfig.Refresh.Sign(jwt.RegisteredClaims{
 		Issuer:    userID,
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.JWT.RefreshExpiry)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(jwtConfig.RefreshExpiry)),
 		ID:        refreshTokenUUID,
-	}).
-		SignedString(config.JWT.RefreshPrivateKey)
+	})
 	if err != nil {
 		return err
 	}
 
-	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.RegisteredClaims{
+	accessToken, err := jwtConfig.Access.Sign(jwt.RegisteredClaims{
 		Issuer:    userID,
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.JWT.AccessExpiry)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(jwtConfig.AccessExpiry)),
 		ID:        accessTokenUUID,
-	}).
-		SignedString(config.JWT.AccessPrivateKey)
+	})
 	if err != nil {
 		return err
 	}
 
+	now := time.Now()
 	cacheJSON, err := json.Marshal(TokenPairCache{
 		AccessTokenUUID:  accessTokenUUID,
 		RefreshTokenUUID: refreshTokenUUID,
-		EntryTime:        time.Now(),
+		EntryTime:        now,
+		LastUsed:         now,
+		ExpiresAt:        now.Add(jwtConfig.RefreshExpiry),
+		UserAgent:        ctx.Request.UserAgent(),
+		IP:               ctx.ClientIP(),
 	})
 	if err != nil {
 		return err
@@ -97,6 +108,10 @@ func NewTokenPair(ctx *gin.Context, userID string) error {
 		return err
 	}
 
+	// the newest pair lives the longest, so the whole hash can expire with it
+	redis.Client.Expire(userID, jwtConfig.RefreshExpiry)
+	pruneExpiredSessions(userID)
+
 	ctx.Header(AccessTokenHeader, accessToken)
 	ctx.Header(RefreshTokenHeader, refreshToken)
 	return nil
@@ -106,18 +121,19 @@ func NewTokenPair(ctx *gin.Context, userID string) error {
 func NewAccessToken(ctx *gin.Context, userID string) error {
 	accessTokenUUID := uuid.New().String()
 
-	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.RegisteredClaims{
+	jwtConfig := config.GetJWT()
+
+	accessToken, err := jwtConfig.Access.Sign(jwt.RegisteredClaims{
 		Issuer:    userID,
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.JWT.AccessExpiry)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(jwtConfig.AccessExpiry)),
 		ID:        accessTokenUUID,
-	}).
-		SignedString(config.JWT.AccessPrivateKey)
+	})
 	if err != nil {
 		return err
 	}
 
-	cacheJSON, err := redis.Client.HGet(userID, ctx.Request.Header.Get(RefreshTokenHeader)).
-		Result()
+	refreshToken := ctx.Request.Header.Get(RefreshTokenHeader)
+	cacheJSON, err := redis.Client.HGet(userID, refreshToken).Result()
 	if err != nil {
 		return err
 	}
@@ -129,25 +145,25 @@ func NewAccessToken(ctx *gin.Context, userID string) error {
 	}
 
 	cachedTokenPair.AccessTokenUUID = accessTokenUUID
-	if err != nil {
-		return err
-	}
+	cachedTokenPair.LastUsed = time.Now()
+	cachedTokenPair.IP = ctx.ClientIP()
 
 	raw, err := json.Marshal(cachedTokenPair)
 	if err != nil {
 		return err
 	}
 
-	err = redis.Client.HSet(
-		userID,
-		ctx.Request.Header.Get(RefreshTokenHeader),
-		string(raw),
-	).Err()
-
+	// a pair revoked or refreshed since it was read is not written back, the hash may already
+	// be gone and would be recreated without expiry
+	replaced, err := redis.Client.Eval(touchSessionScript, []string{userID}, refreshToken, cacheJSON, string(raw)).Result()
 	if err != nil {
 		return err
 	}
 
+	if replaced != int64(1) {
+		return fmt.Errorf("session is revoked")
+	}
+
 	ctx.Header(AccessTokenHeader, accessToken)
 	ctx.Header(RefreshTokenHeader, ctx.Request.Header.Get(RefreshTokenHeader))
 
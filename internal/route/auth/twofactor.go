@@ -0,0 +1,815 @@
+package auth
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/passhash"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"bookbox-backend/pkg/crypto"
+	"bookbox-backend/pkg/logger"
+	"bookbox-backend/pkg/redis"
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp/totp"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	totpUsedKeyPrefix = "totp_used:"
+
+	// a code is valid for one period before and after the current one
+	totpReplayWindow = 90 * time.Second
+
+	recoveryCodeCount = 10
+	qrCodeSize        = 256
+)
+
+var (
+	errRecoveryCodeUsed = fmt.Errorf("recovery code was already used")
+
+	twoFactorIssuer       = "Bookbox"
+	twoFactorChallengeTTL = 5 * time.Minute
+
+	// roles which can't log in without a second factor, from TWO_FACTOR_REQUIRED_ROLES="admin"
+	twoFactorRequiredRoles = map[string]bool{}
+)
+
+func twoFactorRequired(role string) bool {
+	return twoFactorRequiredRoles[role]
+}
+
+func loadTwoFactor(userID string) (twoFactor model.UserTwoFactor, found bool, err error) {
+	res := database.DB.Where("user_id = ?", userID).Limit(1).Find(&twoFactor)
+	return twoFactor, res.RowsAffected > 0, res.Error
+}
+
+// loginChallenge returns the partial-auth response of a login which needs a second step,
+// nil means the token pair can be issued right away
+func loginChallenge(userID string) (challenge map[string]any, err error) {
+	user := model.User{}
+	err = database.DB.Select("id", "role").Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		return
+	}
+
+	twoFactor, _, err := loadTwoFactor(userID)
+	if err != nil {
+		return
+	}
+
+	if !twoFactor.Enabled && !twoFactorRequired(user.Role) {
+		return nil, nil
+	}
+
+	token, err := NewOneTimeToken(TwoFactorPurpose, userID, twoFactorChallengeTTL)
+	if err != nil {
+		return
+	}
+
+	challenge = map[string]any{
+		"challenge": token,
+	}
+
+	if twoFactor.Enabled {
+		challenge["two_factor_required"] = true
+	} else {
+		// the role requires it, the user enrolls with auth/2fa/setup and the challenge
+		challenge["two_factor_setup_required"] = true
+	}
+
+	return
+}
+
+// verifyTOTP checks the code and rejects a code which was already used
+func verifyTOTP(userID string, secret string, code string) bool {
+	if secret == "" || !totp.Validate(code, secret) {
+		return false
+	}
+
+	fresh, err := redis.Client.SetNX(totpUsedKeyPrefix+userID+":"+code, 1, totpReplayWindow).Result()
+	if err != nil {
+		// without Redis replays can't be detected, the code itself is valid
+		logger.Log.Warn("failed to check totp replay",
+			zap.String("userId", userID),
+			zap.Error(err),
+		)
+		return true
+	}
+
+	return fresh
+}
+
+// useRecoveryCode removes the code from the remaining recovery codes. The row is locked while the
+// code is removed, so two logins with the same code can't both use it.
+func useRecoveryCode(twoFactor *model.UserTwoFactor, code string) bool {
+	matched := ""
+	for _, storedHash := range twoFactor.RecoveryCodes {
+		if matchRecoveryCode(storedHash, code) {
+			matched = storedHash
+			break
+		}
+	}
+
+	if matched == "" {
+		return false
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		locked := model.UserTwoFactor{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", twoFactor.ID).
+			First(&locked).Error
+		if err != nil {
+			return err
+		}
+
+		remaining := make([]string, 0, len(locked.RecoveryCodes))
+		for _, storedHash := range locked.RecoveryCodes {
+			if storedHash != matched {
+				remaining = append(remaining, storedHash)
+			}
+		}
+
+		// another login used the code since it was read
+		if len(remaining) == len(locked.RecoveryCodes) {
+			return errRecoveryCodeUsed
+		}
+
+		res := tx.Model(&locked).
+			Select("recovery_codes").
+			UpdateColumns(model.UserTwoFactor{RecoveryCodes: remaining})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected != 1 {
+			return errRecoveryCodeUsed
+		}
+
+		twoFactor.RecoveryCodes = remaining
+		return nil
+	})
+
+	return err == nil
+}
+
+// matchRecoveryCode checks the code against an argon2id hash, codes issued before they were
+// hashed with passhash are stored as plain SHA-256 and keep working until they are replaced
+func matchRecoveryCode(storedHash string, code string) bool {
+	code = normalizeRecoveryCode(code)
+
+	ok, err := passhash.Verify(code, storedHash)
+	if err == nil && ok {
+		return true
+	}
+
+	return subtle.ConstantTimeCompare([]byte(storedHash), []byte(crypto.SHA256(code))) == 1
+}
+
+// verifySecondFactor accepts a TOTP code or one of the recovery codes
+func verifySecondFactor(twoFactor *model.UserTwoFactor, data map[string]any) bool {
+	if code, _ := data["code"].(string); code != "" {
+		return verifyTOTP(twoFactor.UserID, twoFactor.Secret, code)
+	}
+
+	if recoveryCode, _ := data["recovery_code"].(string); recoveryCode != "" {
+		return useRecoveryCode(twoFactor, recoveryCode)
+	}
+
+	return false
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// newRecoveryCodes returns the codes shown to the user once and the hashes to store
+func newRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		var raw string
+		raw, err = randomString()
+		if err != nil {
+			return
+		}
+
+		// 64 bits, stored as salted argon2id hash like a password
+		code := raw[:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+
+		var hash string
+		hash, err = passhash.Hash(normalizeRecoveryCode(code))
+		if err != nil {
+			return
+		}
+
+		codes = append(codes, code)
+		hashes = append(hashes, hash)
+	}
+
+	return
+}
+
+// enableTwoFactor activates a pending enrollment and returns its recovery codes
+func enableTwoFactor(twoFactor *model.UserTwoFactor) (codes []string, err error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return
+	}
+
+	twoFactor.Enabled = true
+	twoFactor.EnabledAt = time.Now().Unix()
+	twoFactor.RecoveryCodes = hashes
+
+	err = database.DB.Model(twoFactor).
+		Select("enabled", "enabled_at", "recovery_codes").
+		UpdateColumns(twoFactor).Error
+	return
+}
+
+// twoFactorUser returns the user of the login challenge, or the authenticated user
+func twoFactorUser(ctx *gin.Context, data map[string]any) (user model.User, err error) {
+	userID := ""
+	if challenge, _ := data["challenge"].(string); challenge != "" {
+		userID, err = PeekOneTimeToken(TwoFactorPurpose, challenge)
+		if err != nil {
+			err = fmt.Errorf("challenge is invalid or expired")
+			return
+		}
+	} else {
+		var issuer *model.User
+		issuer, err = GetIssuer(ctx)
+		if err != nil || issuer.ID == "" {
+			err = fmt.Errorf("user auth is incorrect")
+			return
+		}
+
+		userID = issuer.ID
+	}
+
+	err = database.DB.Where("id = ?", userID).First(&user).Error
+	return
+}
+
+// SetupTwoFactor creates a pending TOTP secret and returns it with the QR code
+func SetupTwoFactor(ctx *gin.Context) {
+	var (
+		setupRequest  = request.Request{}
+		setupResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&setupRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, setupResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("2fa setup started")
+
+	user, err := twoFactorUser(ctx, setupRequest.Data)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, setupResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", user.ID),
+	))
+
+	twoFactor, found, err := loadTwoFactor(user.ID)
+	if err != nil {
+		log.Error("failed to load 2fa",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, setupResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	if twoFactor.Enabled {
+		err = fmt.Errorf("two-factor authentication is already enabled")
+		log.Error("auth/2fa/setup failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, setupResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      twoFactorIssuer,
+		AccountName: user.Email,
+	})
+	if err != nil {
+		log.Error("failed to generate totp secret",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, setupResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	if found {
+		err = database.DB.Model(&twoFactor).UpdateColumn("secret", key.Secret()).Error
+	} else {
+		err = database.DB.Create(&model.UserTwoFactor{
+			UserID: user.ID,
+			Secret: key.Secret(),
+		}).Error
+	}
+	if err != nil {
+		log.Error("failed to store totp secret",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, setupResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	qrCode := ""
+	image, err := key.Image(qrCodeSize, qrCodeSize)
+	if err == nil {
+		var buffer bytes.Buffer
+		if png.Encode(&buffer, image) == nil {
+			qrCode = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buffer.Bytes())
+		}
+	}
+
+	log.Info("2fa setup finished")
+
+	setupResponse.Data = map[string]any{
+		"secret":      key.Secret(),
+		"otpauth_url": key.URL(),
+		"qr_code":     qrCode,
+	}
+	setupResponse.Status = true
+	ctx.JSON(200, setupResponse)
+}
+
+// EnableTwoFactor confirms the pending secret of a logged in user with a code
+func EnableTwoFactor(ctx *gin.Context) {
+	var (
+		enableRequest  = request.Request{}
+		enableResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&enableRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, enableResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("2fa enable started")
+
+	issuer, err := GetIssuer(ctx)
+	if err != nil || issuer.ID == "" {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, enableResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", issuer.ID),
+	))
+
+	twoFactor, found, err := loadTwoFactor(issuer.ID)
+	if err != nil || !found || twoFactor.Enabled {
+		err = fmt.Errorf("no pending two-factor setup")
+		log.Error("auth/2fa/enable failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, enableResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	code, _ := enableRequest.Data["code"].(string)
+	if !verifyTOTP(issuer.ID, twoFactor.Secret, code) {
+		err = fmt.Errorf("two-factor code is incorrect")
+		log.Error("auth/2fa/enable failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, enableResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	codes, err := enableTwoFactor(&twoFactor)
+	if err != nil {
+		log.Error("failed to enable 2fa",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, enableResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("2fa enable finished")
+
+	enableResponse.Data = map[string]any{
+		"recovery_codes": codes,
+	}
+	enableResponse.Status = true
+	ctx.JSON(200, enableResponse)
+}
+
+// LoginTwoFactor completes a login challenge with a TOTP or recovery code and issues the token pair,
+// a pending enrollment is enabled by the first valid code
+func LoginTwoFactor(ctx *gin.Context) {
+	var (
+		loginRequest  = request.Request{}
+		loginResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&loginRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, loginResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("login 2fa started")
+
+	challenge, _ := loginRequest.Data["challenge"].(string)
+	userID, err := ConsumeOneTimeToken(TwoFactorPurpose, challenge)
+	if err != nil {
+		log.Error("auth/login/2fa failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("challenge is invalid or expired")
+		fail.ReturnError(ctx, loginResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", userID),
+	))
+
+	user := model.User{}
+	err = database.DB.Select("id", "email").Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		log.Error("auth/login/2fa failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, loginResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	twoFactor, found, err := loadTwoFactor(userID)
+	if err != nil || !found {
+		err = fmt.Errorf("two-factor authentication is not set up")
+		log.Error("auth/login/2fa failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, loginResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	var ok bool
+	if twoFactor.Enabled {
+		ok = verifySecondFactor(&twoFactor, loginRequest.Data)
+	} else {
+		code, _ := loginRequest.Data["code"].(string)
+		ok = verifyTOTP(userID, twoFactor.Secret, code)
+	}
+
+	if !ok {
+		err = fmt.Errorf("two-factor code is incorrect")
+		log.Error("auth/login/2fa failed",
+			zap.Error(err),
+		)
+
+		auditLogin(ctx, model.AuditTwoFactorFailed, user.Email, err.Error())
+
+		if lockedFor := registerLoginFailure(user.Email, ctx.ClientIP()); lockedFor > 0 {
+			auditLogin(ctx, model.AuditLoginLocked, user.Email, fmt.Sprintf("locked for %s", lockedFor))
+		}
+
+		fail.ReturnError(ctx, loginResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	if !twoFactor.Enabled {
+		codes, err := enableTwoFactor(&twoFactor)
+		if err != nil {
+			log.Error("failed to enable 2fa",
+				zap.Error(err),
+			)
+
+			fail.ReturnError(ctx, loginResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+			return
+		}
+
+		loginResponse.Data = map[string]any{
+			"recovery_codes": codes,
+		}
+	}
+
+	resetLoginFailures(user.Email, ctx.ClientIP())
+
+	// Generate JWT pair.
+	err = NewTokenPair(ctx, userID)
+	if err != nil {
+		log.Error("Failed to generate token",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, loginResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	log.Info("login 2fa finished")
+
+	loginResponse.Status = true
+	ctx.JSON(200, loginResponse)
+}
+
+// DisableTwoFactor removes the enrollment of the logged in user, unless the role requires it
+func DisableTwoFactor(ctx *gin.Context) {
+	var (
+		disableRequest  = request.Request{}
+		disableResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&disableRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, disableResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("2fa disable started")
+
+	issuer, err := GetIssuer(ctx)
+	if err != nil || issuer.ID == "" {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, disableResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", issuer.ID),
+	))
+
+	if twoFactorRequired(issuer.Role) {
+		err = fmt.Errorf("two-factor authentication is required for your role")
+		log.Error("auth/2fa/disable failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, disableResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	twoFactor, found, err := loadTwoFactor(issuer.ID)
+	if err != nil || !found {
+		err = fmt.Errorf("two-factor authentication is not set up")
+		log.Error("auth/2fa/disable failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, disableResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	if twoFactor.Enabled && !verifySecondFactor(&twoFactor, disableRequest.Data) {
+		err = fmt.Errorf("two-factor code is incorrect")
+		log.Error("auth/2fa/disable failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, disableResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	err = database.DB.Delete(&twoFactor).Error
+	if err != nil {
+		log.Error("failed to disable 2fa",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, disableResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("2fa disable finished")
+
+	disableResponse.Status = true
+	ctx.JSON(200, disableResponse)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, the old ones stop working
+func RegenerateRecoveryCodes(ctx *gin.Context) {
+	var (
+		regenerateRequest  = request.Request{}
+		regenerateResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&regenerateRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, regenerateResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("2fa recovery codes started")
+
+	issuer, err := GetIssuer(ctx)
+	if err != nil || issuer.ID == "" {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, regenerateResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", issuer.ID),
+	))
+
+	twoFactor, found, err := loadTwoFactor(issuer.ID)
+	if err != nil || !found || !twoFactor.Enabled {
+		err = fmt.Errorf("two-factor authentication is not enabled")
+		log.Error("auth/2fa/recovery_codes failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, regenerateResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	code, _ := regenerateRequest.Data["code"].(string)
+	if !verifyTOTP(issuer.ID, twoFactor.Secret, code) {
+		err = fmt.Errorf("two-factor code is incorrect")
+		log.Error("auth/2fa/recovery_codes failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, regenerateResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	codes, err := enableTwoFactor(&twoFactor)
+	if err != nil {
+		log.Error("failed to store recovery codes",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, regenerateResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("2fa recovery codes finished")
+
+	regenerateResponse.Data = map[string]any{
+		"recovery_codes": codes,
+	}
+	regenerateResponse.Status = true
+	ctx.JSON(200, regenerateResponse)
+}
+
+// ResetTwoFactor lets admins remove the enrollment of a user who lost the device and the
+// recovery codes, the user is logged out everywhere
+func ResetTwoFactor(ctx *gin.Context) {
+	var (
+		resetRequest  = request.Request{}
+		resetResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&resetRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, resetResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	userID, _ := resetRequest.Data["user_id"].(string)
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.String("userId", userID),
+	))
+
+	log.Info("2fa reset started")
+
+	issuer, err := GetIssuer(ctx)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, resetResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("only admins can call this route")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, resetResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	user := model.User{}
+	res := database.DB.Select("id", "email").Where("id = ?", userID).Limit(1).Find(&user)
+	if userID == "" || res.Error != nil || res.RowsAffected == 0 {
+		err = fmt.Errorf("user with specified id does not exist")
+		log.Error("auth/2fa/reset failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, resetResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	err = database.DB.Where("user_id = ?", userID).Delete(&model.UserTwoFactor{}).Error
+	if err != nil {
+		log.Error("failed to reset 2fa",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, resetResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	err = DestroyAllTokens(userID)
+	if err != nil {
+		log.Error("failed to revoke token pairs",
+			zap.Error(err),
+		)
+	}
+
+	auditLogin(ctx, model.AuditTwoFactorReset, user.Email, fmt.Sprintf("reset by %s", issuer.ID))
+
+	log.Info("2fa reset finished")
+
+	resetResponse.Status = true
+	ctx.JSON(200, resetResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "auth/login/2fa", LoginTwoFactor)
+	router.Router.Handle("POST", "auth/2fa/setup", SetupTwoFactor)
+	router.Router.Handle("POST", "auth/2fa/enable", EnableTwoFactor)
+	router.Router.Handle("POST", "auth/2fa/disable", DisableTwoFactor)
+	router.Router.Handle("POST", "auth/2fa/recovery_codes", RegenerateRecoveryCodes)
+	router.Router.Handle("POST", "auth/2fa/reset", ResetTwoFactor)
+}
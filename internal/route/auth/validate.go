@@ -4,7 +4,7 @@ import (
 	"bookbox-backend/internal/config"
 	"bookbox-backend/internal/database"
 	"bookbox-backend/internal/model"
-	"bookbox-backend/pkg/logger"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/pkg/redis"
 	"encoding/json"
 	"fmt"
@@ -15,7 +15,7 @@ import (
 )
 
 func GetIssuer(ctx *gin.Context) (issuer *model.User, err error) {
-	logger.Log.Info("validating token")
+	requestid.Logger(ctx).Info("validating token")
 	if ctx.Request.Header.Get(AccessTokenHeader) == "" && ctx.Request.Header.Get(RefreshTokenHeader) == "" {
 		issuer = &model.User{
 			Role: "guest",
@@ -35,19 +35,18 @@ func ValidateTokenClaims(
 	tokenToValidate string,
 	isRefresh bool,
 ) (*model.User, error) {
+	keys := config.GetJWT().Access
+	if isRefresh {
+		keys = config.GetJWT().Refresh
+	}
+
 	parsedToken, err := jwt.ParseWithClaims(
 		tokenToValidate,
 		&jwt.RegisteredClaims{},
-		func(jwt *jwt.Token) (interface{}, error) {
-			if isRefresh {
-				return config.JWT.RefreshPrivateKey.Public(), nil
-			} else {
-				return config.JWT.AccessPrivateKey.Public(), nil
-			}
-		},
+		keys.Keyfunc,
 	)
 	if err != nil {
-		logger.Log.Warn("Failed to parse token data",
+		requestid.Logger(ctx).Warn("Failed to parse token data",
 			zap.Error(err),
 		)
 		return nil, err
@@ -56,7 +55,7 @@ func ValidateTokenClaims(
 	claims := parsedToken.Claims.(*jwt.RegisteredClaims)
 	cacheJSON, err := redis.Client.HGet(claims.Issuer, ctx.Request.Header.Get(RefreshTokenHeader)).Result()
 	if err != nil {
-		logger.Log.Error("Failed to get data from Redis",
+		requestid.Logger(ctx).Error("Failed to get data from Redis",
 			zap.Error(err),
 		)
 		return nil, err
@@ -81,6 +80,8 @@ func ValidateTokenClaims(
 		return nil, err
 	}
 
+	touchSession(claims.Issuer, ctx.Request.Header.Get(RefreshTokenHeader), cacheJSON, cachedTokenPair)
+
 	var issuer model.User
 	resp := database.DB.Where("id = ?", claims.Issuer).
 		First(&issuer)
@@ -90,7 +91,7 @@ func ValidateTokenClaims(
 		if resp.Error != nil {
 			err = resp.Error
 		}
-		logger.Log.Error("Issuer not found",
+		requestid.Logger(ctx).Error("Issuer not found",
 			zap.Error(err),
 		)
 
@@ -0,0 +1,329 @@
+package auth
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"bookbox-backend/internal/server/sendgrid"
+	"bookbox-backend/pkg/logger"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	emailVerifyTTL         time.Duration
+	emailVerifyURL         string
+	emailVerifyTemplateID  string
+	emailVerifySenderEmail string
+)
+
+// VerifyEmail marks the account of the emailed token as verified
+func VerifyEmail(ctx *gin.Context) {
+	var (
+		verifyRequest  = request.Request{}
+		verifyResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&verifyRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, verifyResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("verify started")
+
+	token, _ := verifyRequest.Data["token"].(string)
+	if token == "" {
+		err = fmt.Errorf("token is missing")
+		log.Error("auth/verify failed")
+
+		fail.ReturnError(ctx, verifyResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	userID, err := ConsumeOneTimeToken(EmailVerifyPurpose, token)
+	if err != nil {
+		log.Error("auth/verify failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("token is invalid or expired")
+		fail.ReturnError(ctx, verifyResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", userID),
+	))
+
+	err = MarkEmailVerified(userID)
+	if err != nil {
+		log.Error("failed to mark email verified",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, verifyResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("verify finished")
+
+	verifyResponse.Status = true
+	ctx.JSON(200, verifyResponse)
+}
+
+// ResendVerification sends the verification email again, admins can specify user_id
+func ResendVerification(ctx *gin.Context) {
+	var (
+		resendRequest  = request.Request{}
+		resendResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&resendRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, resendResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("verify resend started")
+
+	issuer, err := GetIssuer(ctx)
+	if err != nil || issuer.ID == "" {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, resendResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	user := *issuer
+	userID, _ := resendRequest.Data["user_id"].(string)
+	if userID != "" && userID != issuer.ID {
+		if issuer.Role != model.UserAdminRole {
+			err = fmt.Errorf("only admins can resend for other users")
+			log.Error("authorization failed",
+				zap.Error(err),
+			)
+
+			fail.ReturnError(ctx, resendResponse, []string{err.Error()}, 403, log)
+			return
+		}
+
+		res := database.DB.Where("id = ?", userID).Limit(1).Find(&user)
+		if res.Error != nil || res.RowsAffected == 0 {
+			err = fmt.Errorf("user with specified id does not exist")
+			log.Error("auth/verify/resend failed",
+				zap.Error(err),
+			)
+
+			fail.ReturnError(ctx, resendResponse, []string{err.Error()}, 400, log)
+			return
+		}
+	}
+
+	if user.EmailVerified {
+		err = fmt.Errorf("email is already verified")
+		log.Error("auth/verify/resend failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, resendResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	err = SendEmailVerification(ctx, user, log)
+	if err != nil {
+		fail.ReturnError(ctx, resendResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("verify resend finished")
+
+	resendResponse.Status = true
+	ctx.JSON(200, resendResponse)
+}
+
+// MarkVerified lets admins verify an account manually
+func MarkVerified(ctx *gin.Context) {
+	var (
+		markRequest  = request.Request{}
+		markResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&markRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, markResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	userID, _ := markRequest.Data["user_id"].(string)
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.String("userId", userID),
+	))
+
+	log.Info("verify mark started")
+
+	issuer, err := GetIssuer(ctx)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, markResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("only admins can call this route")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, markResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if userID == "" {
+		err = fmt.Errorf("user_id is missing")
+		log.Error("auth/verify/mark failed")
+
+		fail.ReturnError(ctx, markResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	err = MarkEmailVerified(userID)
+	if err != nil {
+		log.Error("failed to mark email verified",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, markResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	log.Info("verify mark finished")
+
+	markResponse.Status = true
+	ctx.JSON(200, markResponse)
+}
+
+// MarkEmailVerified sets the verified flag without running the User hooks
+func MarkEmailVerified(userID string) error {
+	res := database.DB.Model(&model.User{}).Where("id = ?", userID).UpdateColumns(map[string]any{
+		"email_verified":    true,
+		"email_verified_at": time.Now().Unix(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("user with specified id does not exist")
+	}
+
+	user := model.User{}
+	err := database.DB.Select("id", "email").Where("id = ?", userID).First(&user).Error
+	if err == nil {
+		_, err = LinkGuestOrders(user.ID, user.Email)
+	}
+	if err != nil {
+		// the customer can retry with order/claim
+		logger.Log.Warn("failed to link guest orders",
+			zap.String("userId", userID),
+			zap.Error(err),
+		)
+	}
+
+	return nil
+}
+
+// LinkGuestOrders assigns the guest orders placed with the email to the user, it must only be
+// called for verified emails
+func LinkGuestOrders(userID string, email string) (linked int64, err error) {
+	if email == "" {
+		return
+	}
+
+	res := database.DB.Model(&model.Order{}).
+		Where("user_id IS NULL AND LOWER(email) = LOWER(?)", email).
+		UpdateColumn("user_id", userID)
+
+	return res.RowsAffected, res.Error
+}
+
+// SendEmailVerification emails a fresh verification token to the user
+func SendEmailVerification(ctx context.Context, user model.User, log *zap.Logger) (err error) {
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", user.ID),
+	))
+
+	token, err := NewOneTimeToken(EmailVerifyPurpose, user.ID, emailVerifyTTL)
+	if err != nil {
+		log.Error("failed to create email verification token",
+			zap.Error(err),
+		)
+		return
+	}
+
+	verifyURL, err := TokenLink(emailVerifyURL, token)
+	if err != nil {
+		log.Error("failed to build email verification link",
+			zap.Error(err),
+		)
+		return
+	}
+
+	notify := sendgrid.SendGrid{
+		From:       emailVerifySenderEmail,
+		To:         user.Email,
+		TemplateID: emailVerifyTemplateID,
+		DynamicTemplateData: map[string]interface{}{
+			"first_name": user.FirstName,
+			"verify_url": verifyURL,
+		},
+	}
+
+	err = notify.SendEmail(ctx)
+	if err != nil {
+		log.Error("failed to send verification email",
+			zap.Error(err),
+		)
+		return
+	}
+
+	log.Info("verification email sent")
+
+	return
+}
+
+func init() {
+	router.Router.Handle("POST", "auth/verify", VerifyEmail)
+	router.Router.Handle("POST", "auth/verify/resend", ResendVerification)
+	router.Router.Handle("POST", "auth/verify/mark", MarkVerified)
+}
@@ -9,10 +9,12 @@ import (
 	"bookbox-backend/internal/execute/prerun"
 	"bookbox-backend/internal/query"
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/route/auth"
 	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/middlewares"
 	"bookbox-backend/internal/server/router"
-	"bookbox-backend/pkg/logger"
+	"bookbox-backend/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -27,15 +29,17 @@ func CreateHandler(ctx *gin.Context) {
 	// bind input data to request format
 	err := ctx.ShouldBind(&createRequest)
 	if err != nil {
-		logger.Log.Error("Failed to bind input data",
+		requestid.Logger(ctx).Error("Failed to bind input data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, createResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, createResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
-	log := logger.Log.WithOptions(zap.Fields(
+	setMetricsEntity(ctx, createRequest.Entity)
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
 		zap.String("entity", createRequest.Entity),
 	))
 
@@ -72,7 +76,7 @@ func CreateHandler(ctx *gin.Context) {
 	}
 
 	// check authorisation
-	if !IsAuthorized(issuer, "create", createRequest.Entity) {
+	if !IsAuthorized(issuer, "create", createRequest.Entity) || !IsKeyAuthorized(ctx, "create", createRequest.Entity) {
 		errMsg := "authorization failed"
 		log.Error(errMsg,
 			zap.Error(err),
@@ -83,9 +87,24 @@ func CreateHandler(ctx *gin.Context) {
 		return
 	}
 
+	// check sales channel scope of the api key, the row must be created in one of its channels
+	field, channels, ok := salesChannelScope(ctx, "create", createRequest.Entity)
+	salesChannelID, _ := createRequest.Data[field.key].(string)
+	if !ok || (channels != nil && !middlewares.GetAPIKey(ctx).AllowsSalesChannel(salesChannelID)) {
+		err = fmt.Errorf("api key is not authorized for this sales channel")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, createResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
 	// run prerun functions if they exist
 	if f, exist := prerun.CreateFunctions[createRequest.Entity]; exist {
+		_, span := startHook(ctx, "prerun.create", createRequest.Entity)
 		err = f(&createRequest, issuer)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed in prerun function",
 				zap.Error(err),
@@ -116,13 +135,13 @@ func CreateHandler(ctx *gin.Context) {
 		return
 	}
 
-	err = database.DB.Create(row).Error
+	err = database.DB.WithContext(ctx).Create(row).Error
 	if err != nil {
 		log.Error("Failed to create data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, createResponse, []string{err.Error()}, 400, log)
+		fail.ReturnError(ctx, createResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 		return
 	}
 
@@ -130,13 +149,15 @@ func CreateHandler(ctx *gin.Context) {
 
 	// run postrun functions if they exist
 	if f, exist := postrun.CreateFunctions[createRequest.Entity]; exist {
-		row, err = f(row, issuer)
+		hookCtx, span := startHook(ctx, "postrun.create", createRequest.Entity)
+		row, err = f(hookCtx, row, issuer)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed in postrun function",
 				zap.Error(err),
 			)
 
-			fail.ReturnError(ctx, createResponse, []string{err.Error()}, 400, log)
+			fail.ReturnError(ctx, createResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 			return
 		}
 	}
@@ -149,13 +170,15 @@ func CreateHandler(ctx *gin.Context) {
 
 	// run postrun cache functions if they exist
 	if f, exist := postrun.CacheCreateFunctions[createRequest.Entity]; exist {
+		_, span := startHook(ctx, "postrun.cache_create", createRequest.Entity)
 		err = f(createRequest, createResponse, issuer, log)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed to run postrun function",
 				zap.Error(err),
 			)
 
-			fail.ReturnError(ctx, createResponse, []string{err.Error()}, 400, log)
+			fail.ReturnError(ctx, createResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 			return
 		}
 	}
@@ -5,10 +5,11 @@ import (
 	"bookbox-backend/internal/execute/prerun"
 	"bookbox-backend/internal/query"
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/route/auth"
 	"bookbox-backend/internal/route/fail"
 	"bookbox-backend/internal/server/router"
-	"bookbox-backend/pkg/logger"
+	"bookbox-backend/internal/tracing"
 	"encoding/json"
 	"fmt"
 
@@ -25,15 +26,17 @@ func DeleteHandler(ctx *gin.Context) {
 	// bind input data to request format
 	err := ctx.ShouldBindJSON(&deleteRequest)
 	if err != nil {
-		logger.Log.Error("Failed to bind input data",
+		requestid.Logger(ctx).Error("Failed to bind input data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, deleteResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, deleteResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
-	log := logger.Log.WithOptions(zap.Fields(
+	setMetricsEntity(ctx, deleteRequest.Entity)
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
 		zap.String("entity", deleteRequest.Entity),
 		zap.Any("data", deleteRequest.Data),
 	))
@@ -69,7 +72,7 @@ func DeleteHandler(ctx *gin.Context) {
 	}
 
 	// check authorisation
-	if !IsAuthorized(issuer, "delete", deleteRequest.Entity) {
+	if !IsAuthorized(issuer, "delete", deleteRequest.Entity) || !IsKeyAuthorized(ctx, "delete", deleteRequest.Entity) {
 		errMsg := "authorization failed"
 		log.Error(errMsg,
 			zap.Error(err),
@@ -80,9 +83,27 @@ func DeleteHandler(ctx *gin.Context) {
 		return
 	}
 
+	// check sales channel scope of the api key, only rows of its channels are deleted
+	field, channels, ok := salesChannelScope(ctx, "delete", deleteRequest.Entity)
+	if ok {
+		id, _ := deleteRequest.Data["id"].(string)
+		ok, err = isInSalesChannels(ctx, deleteRequest.Entity, id, field, channels)
+	}
+	if !ok || err != nil {
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("api key is not authorized for this sales channel")
+		fail.ReturnError(ctx, deleteResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
 	// run prerun functions if they exist
 	if f, exist := prerun.DeleteFunctions[deleteRequest.Entity]; exist {
+		_, span := startHook(ctx, "prerun.delete", deleteRequest.Entity)
 		err = f(&deleteRequest, issuer)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed to run prerun function",
 				zap.Error(err),
@@ -105,7 +126,7 @@ func DeleteHandler(ctx *gin.Context) {
 		return
 	}
 
-	res := database.DB.Delete(&row, "id = ?", id)
+	res := database.DB.WithContext(ctx).Delete(&row, "id = ?", id)
 	if res.Error != nil {
 		err := fmt.Errorf("failed to update rows, wrong id or product already removed")
 		log.Error("Delete failed",
@@ -10,10 +10,11 @@ import (
 	"bookbox-backend/internal/execute/prerun"
 	"bookbox-backend/internal/query"
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/route/auth"
 	"bookbox-backend/internal/route/fail"
 	"bookbox-backend/internal/server/router"
-	"bookbox-backend/pkg/logger"
+	"bookbox-backend/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -29,15 +30,17 @@ func ReadHandler(ctx *gin.Context) {
 	// bind input data to request format
 	err := ctx.ShouldBind(&readRequest)
 	if err != nil {
-		logger.Log.Error("Failed to bind input data",
+		requestid.Logger(ctx).Error("Failed to bind input data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
-	log := logger.Log.WithOptions(zap.Fields(
+	setMetricsEntity(ctx, readRequest.Entity)
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
 		zap.String("entity", readRequest.Entity),
 		zap.Any("data", readRequest.Data),
 	))
@@ -68,7 +71,7 @@ func ReadHandler(ctx *gin.Context) {
 	}
 
 	// check authorisation
-	if !IsAuthorized(issuer, "read", readRequest.Entity) {
+	if !IsAuthorized(issuer, "read", readRequest.Entity) || !IsKeyAuthorized(ctx, "read", readRequest.Entity) {
 		errMsg := "authorization failed"
 		log.Error(errMsg,
 			zap.Error(err),
@@ -79,12 +82,26 @@ func ReadHandler(ctx *gin.Context) {
 		return
 	}
 
+	// check sales channel scope of the api key, only rows of its channels are read
+	field, channels, ok := salesChannelScope(ctx, "read", readRequest.Entity)
+	if !ok {
+		err = fmt.Errorf("api key is not authorized for this sales channel")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
 	// if authorized, add universal filter
 	prerun.UniversalFilter(&readRequest, issuer)
 
 	// run prerun functions if they exist
 	if f, exist := prerun.ReadFunctions[readRequest.Entity]; exist {
+		_, span := startHook(ctx, "prerun.read", readRequest.Entity)
 		err = f(&readRequest, issuer)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed to run prerun function",
 				zap.Error(err),
@@ -95,17 +112,18 @@ func ReadHandler(ctx *gin.Context) {
 		}
 	}
 
-	dbHandler := query.DetermineRelations(readRequest, database.DB)
+	dbHandler := query.DetermineRelations(readRequest, database.DB.WithContext(ctx))
 
 	res := dbHandler.
 		Omit("password").
+		Scopes(inSalesChannels(field, channels)).
 		Find(row, "id = ?", readRequest.Data.ID)
 	if res.Error != nil {
 		log.Error("read failed",
 			zap.Error(res.Error),
 		)
 
-		fail.ReturnError(ctx, readResponse, []string{fail.SystemError(res.Error)}, 400, log)
+		fail.ReturnError(ctx, readResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 		return
 	}
 
@@ -122,7 +140,9 @@ func ReadHandler(ctx *gin.Context) {
 
 	// run postrun functions if they exist
 	if f, exist := postrun.ReadFunctions[readRequest.Entity]; exist {
+		_, span := startHook(ctx, "postrun.read", readRequest.Entity)
 		row, err = f(readRequest, row, issuer)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed in postrun function",
 				zap.Error(err),
@@ -151,15 +171,17 @@ func ListHandler(ctx *gin.Context) {
 	// bind input data to request format
 	err := ctx.ShouldBind(&listRequest)
 	if err != nil {
-		logger.Log.Error("Failed to bind input data",
+		requestid.Logger(ctx).Error("Failed to bind input data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
-	log := logger.Log.WithOptions(zap.Fields(
+	setMetricsEntity(ctx, listRequest.Entity)
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
 		zap.String("entity", listRequest.Entity),
 		zap.Any("metadata", listRequest.Metadata),
 	))
@@ -190,7 +212,7 @@ func ListHandler(ctx *gin.Context) {
 	}
 
 	// check authorization
-	if !IsAuthorized(issuer, "list", listRequest.Entity) {
+	if !IsAuthorized(issuer, "list", listRequest.Entity) || !IsKeyAuthorized(ctx, "list", listRequest.Entity) {
 		errMsg := "authorization failed"
 		log.Error(errMsg,
 			zap.Error(err),
@@ -201,9 +223,23 @@ func ListHandler(ctx *gin.Context) {
 		return
 	}
 
+	// check sales channel scope of the api key, only rows of its channels are listed
+	field, channels, ok := salesChannelScope(ctx, "list", listRequest.Entity)
+	if !ok {
+		err = fmt.Errorf("api key is not authorized for this sales channel")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
 	// run prerun functions if they exist
 	if f, exist := prerun.CacheFunctions[listRequest.Entity]; exist {
+		_, span := startHook(ctx, "prerun.cache_list", listRequest.Entity)
 		data, found, err := f(listRequest, issuer, log)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed to run prerun function",
 				zap.Error(err),
@@ -226,7 +262,9 @@ func ListHandler(ctx *gin.Context) {
 
 	// run prerun functions if they exist
 	if f, exist := prerun.ListFunctions[listRequest.Entity]; exist {
+		_, span := startHook(ctx, "prerun.list", listRequest.Entity)
 		err = f(&listRequest, issuer)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed to run prerun function",
 				zap.Error(err),
@@ -259,7 +297,10 @@ func ListHandler(ctx *gin.Context) {
 		return
 	}
 
-	dbContext, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// the filters are grouped, so a should filter can't reach the rows of other sales channels
+	filter := database.DB.Where(where.Main, where.Values...).Or(should.Main, should.Values...)
+
+	dbContext, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	dbHandler := database.DB.WithContext(dbContext)
@@ -268,8 +309,8 @@ func ListHandler(ctx *gin.Context) {
 	res := dbHandler.
 		Omit("password").
 		Scopes(query.Paginate(listRequest.Metadata.Limit, listRequest.Metadata.Offset)).
-		Where(where.Main, where.Values...).
-		Or(should.Main, should.Values...).
+		Where(filter).
+		Scopes(inSalesChannels(field, channels)).
 		Order(orderBy).
 		Find(rows)
 
@@ -278,7 +319,7 @@ func ListHandler(ctx *gin.Context) {
 			zap.Error(res.Error),
 		)
 
-		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(res.Error)}, 400, log)
+		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 		return
 	}
 
@@ -290,8 +331,8 @@ func ListHandler(ctx *gin.Context) {
 	res = dbHandler.
 		Omit("password").
 		Scopes(query.Paginate(countLabelThreshold, 1)).
-		Where(where.Main, where.Values...).
-		Or(should.Main, should.Values...).
+		Where(filter).
+		Scopes(inSalesChannels(field, channels)).
 		Find(rowsCheck)
 
 	if res.Error != nil {
@@ -299,7 +340,7 @@ func ListHandler(ctx *gin.Context) {
 			zap.Error(res.Error),
 		)
 
-		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(res.Error)}, 400, log)
+		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 		return
 	}
 
@@ -315,7 +356,9 @@ func ListHandler(ctx *gin.Context) {
 
 	// run prerun functions if they exist
 	if f, exist := postrun.ListFunctions[listRequest.Entity]; exist {
+		_, span := startHook(ctx, "postrun.list", listRequest.Entity)
 		rows, err = f(listRequest, rows, issuer)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed to run postrun function",
 				zap.Error(err),
@@ -341,7 +384,9 @@ func ListHandler(ctx *gin.Context) {
 
 	// run postrun cache functions if they exist
 	if f, exist := postrun.CacheListFunctions[listRequest.Entity]; exist {
+		_, span := startHook(ctx, "postrun.cache_list", listRequest.Entity)
 		err = f(listRequest, listResponse, issuer, log)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed to run postrun function",
 				zap.Error(err),
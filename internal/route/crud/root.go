@@ -1,11 +1,23 @@
 package crud
 
 import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/metrics"
 	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/query"
+	"bookbox-backend/internal/server/middlewares"
+	"bookbox-backend/internal/tracing"
+	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"log"
 	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
 )
 
 var (
@@ -29,6 +41,107 @@ func IsAuthorized(issuer *model.User, operation string, entity string) (isAuth b
 	return
 }
 
+// IsKeyAuthorized checks the scopes of the API key used for the request
+func IsKeyAuthorized(ctx *gin.Context, operation string, entity string) bool {
+	return middlewares.GetAPIKey(ctx).Allows(operation, entity)
+}
+
+// salesChannelField ties the rows of an entity to their sales channel
+type salesChannelField struct {
+	// key of the sales channel in the request data
+	key string
+	// column with its table, list queries can join other tables
+	column string
+}
+
+var (
+	// salesChannelFields are the entities which belong to a sales channel
+	salesChannelFields = map[string]salesChannelField{
+		"order":         {key: "sales_channel_id", column: "orders.sales_channel_id"},
+		"sales_channel": {key: "id", column: "sales_channels.id"},
+	}
+
+	// catalogEntities are shared by every sales channel, keys scoped to sales channels may read
+	// them but not change them
+	catalogEntities = map[string]bool{
+		"product":  true,
+		"category": true,
+	}
+)
+
+// salesChannelScope returns the sales channels the API key of the request is limited to, nil
+// when it may use every channel. ok is false when a scoped key can't use the operation on the
+// entity, because its rows can't be filtered by sales channel.
+func salesChannelScope(ctx *gin.Context, operation string, entity string) (field salesChannelField, channels []string, ok bool) {
+	key := middlewares.GetAPIKey(ctx)
+
+	// an empty scope or "*" allows every channel
+	if key.AllowsSalesChannel("") {
+		return salesChannelField{}, nil, true
+	}
+
+	if field, found := salesChannelFields[entity]; found {
+		return field, key.SalesChannels, true
+	}
+
+	return salesChannelField{}, nil, catalogEntities[entity] && (operation == "read" || operation == "list")
+}
+
+// inSalesChannels limits a query to the rows of the sales channels, without channels the query
+// is unchanged
+func inSalesChannels(field salesChannelField, channels []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if channels == nil {
+			return db
+		}
+
+		return db.Where(field.column+" IN ?", channels)
+	}
+}
+
+// isInSalesChannels checks that the row with the id belongs to the sales channels, before a
+// prerun function or the update touches it
+func isInSalesChannels(ctx context.Context, entity string, id string, field salesChannelField, channels []string) (bool, error) {
+	if channels == nil {
+		return true, nil
+	}
+
+	var count int64
+	err := database.DB.WithContext(ctx).
+		Model(query.Determine(entity)).
+		Scopes(inSalesChannels(field, channels)).
+		Where("id = ?", id).
+		Count(&count).Error
+
+	return count != 0, err
+}
+
+// setMetricsEntity labels the request metrics with the entity, unknown entities are not
+// used as label so clients can't create new series
+func setMetricsEntity(ctx *gin.Context, entity string) {
+	if query.Determine(entity) != nil {
+		ctx.Set(metrics.EntityKey, entity)
+	}
+}
+
+// requestError is a problem with the request itself, its message is returned with 400. Every
+// other error is internal, it is only logged and the client gets a system error with 500.
+type requestError string
+
+func (e requestError) Error() string {
+	return string(e)
+}
+
+func isRequestError(err error) bool {
+	var target requestError
+	return errors.As(err, &target)
+}
+
+// startHook opens a span for a prerun or postrun function
+func startHook(ctx context.Context, name string, entity string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name, attribute.String("entity", entity))
+}
+
 func init() {
 	err := json.Unmarshal(PermissionsRaw, &Permissions)
 	if err != nil {
@@ -6,10 +6,12 @@ import (
 	"bookbox-backend/internal/execute/prerun"
 	"bookbox-backend/internal/query"
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/route/auth"
 	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/middlewares"
 	"bookbox-backend/internal/server/router"
-	"bookbox-backend/pkg/logger"
+	"bookbox-backend/internal/tracing"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -28,15 +30,17 @@ func UpdateHandler(ctx *gin.Context) {
 	// bind input data to request format
 	err := ctx.ShouldBindJSON(&updateRequest)
 	if err != nil {
-		logger.Log.Error("Failed to bind input data",
+		requestid.Logger(ctx).Error("Failed to bind input data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
-	log := logger.Log.WithOptions(zap.Fields(
+	setMetricsEntity(ctx, updateRequest.Entity)
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
 		zap.String("entity", updateRequest.Entity),
 	))
 
@@ -71,7 +75,7 @@ func UpdateHandler(ctx *gin.Context) {
 	}
 
 	// check authorisation
-	if !IsAuthorized(issuer, "update", updateRequest.Entity) {
+	if !IsAuthorized(issuer, "update", updateRequest.Entity) || !IsKeyAuthorized(ctx, "update", updateRequest.Entity) {
 		errMsg := "authorization failed"
 		log.Error(errMsg,
 			zap.Error(err),
@@ -82,9 +86,18 @@ func UpdateHandler(ctx *gin.Context) {
 		return
 	}
 
+	// check sales channel scope of the api key, the row must stay in one of its channels
+	if !isUpdateInSalesChannels(ctx, updateRequest, log) {
+		err = fmt.Errorf("api key is not authorized for this sales channel")
+		fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
 	// run prerun functions if they exist
 	if f, exist := prerun.UpdateFunctions[updateRequest.Entity]; exist {
+		_, span := startHook(ctx, "prerun.update", updateRequest.Entity)
 		err = f(&updateRequest, issuer)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed to run prerun function",
 				zap.Error(err),
@@ -126,26 +139,33 @@ func UpdateHandler(ctx *gin.Context) {
 		return
 	}
 
-	err = UpdateTransaction(updateRequest, database.DB, row, id)
+	err = UpdateTransaction(updateRequest, database.DB.WithContext(ctx), row, id)
 	if err != nil {
 		log.Warn("Update failed",
 			zap.String("id", id),
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 400, log)
+		if isRequestError(err) {
+			fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 400, log)
+			return
+		}
+
+		fail.ReturnError(ctx, updateResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 		return
 	}
 
 	// run postrun functions if they exist
 	if f, exist := postrun.UpdateFunctions[updateRequest.Entity]; exist {
-		err = f(&updateRequest, issuer, log)
+		hookCtx, span := startHook(ctx, "postrun.update", updateRequest.Entity)
+		err = f(hookCtx, &updateRequest, issuer, log)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed to run postrun function",
 				zap.Error(err),
 			)
 
-			fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 400, log)
+			fail.ReturnError(ctx, updateResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 			return
 		}
 	}
@@ -156,13 +176,15 @@ func UpdateHandler(ctx *gin.Context) {
 
 	// run postrun cache functions if they exist
 	if f, exist := postrun.CacheUpdateFunctions[updateRequest.Entity]; exist {
+		_, span := startHook(ctx, "postrun.cache_update", updateRequest.Entity)
 		err = f(updateRequest, updateResponse, issuer, log)
+		tracing.End(span, err)
 		if err != nil {
 			log.Error("failed to run postrun function",
 				zap.Error(err),
 			)
 
-			fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 400, log)
+			fail.ReturnError(ctx, updateResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 			return
 		}
 	}
@@ -170,6 +192,42 @@ func UpdateHandler(ctx *gin.Context) {
 	ctx.JSON(200, updateResponse)
 }
 
+// isUpdateInSalesChannels checks that the row to update belongs to the sales channels of the
+// api key and isn't moved to another channel
+func isUpdateInSalesChannels(ctx *gin.Context, updateRequest request.Request, log *zap.Logger) bool {
+	field, channels, ok := salesChannelScope(ctx, "update", updateRequest.Entity)
+	if !ok {
+		log.Error("authorization failed, entity is not in a sales channel")
+		return false
+	}
+
+	if channels == nil {
+		return true
+	}
+
+	if value, found := updateRequest.Data[field.key]; found {
+		salesChannelID, _ := value.(string)
+		if !middlewares.GetAPIKey(ctx).AllowsSalesChannel(salesChannelID) {
+			log.Error("authorization failed, row is moved to another sales channel",
+				zap.Any("salesChannelId", value),
+			)
+			return false
+		}
+	}
+
+	id, _ := updateRequest.Data["id"].(string)
+	inScope, err := isInSalesChannels(ctx, updateRequest.Entity, id, field, channels)
+	if err != nil || !inScope {
+		log.Error("authorization failed, row is not in the sales channels of the api key",
+			zap.String("id", id),
+			zap.Error(err),
+		)
+		return false
+	}
+
+	return true
+}
+
 var (
 	dataCart = map[string]string{
 		"cart_items": "cart_items",
@@ -217,14 +275,14 @@ func Mapper(entity, val string) (string, error) {
 		}
 
 	default:
-		return "", fmt.Errorf("entity not supported for override to update")
+		return "", requestError("entity not supported for override to update")
 	}
 
-	return "", fmt.Errorf("override to update does not exist")
+	return "", requestError("override to update does not exist")
 }
 
 func UpdateTransaction(updateRequest request.Request, db *gorm.DB, row any, id string) (err error) {
-	tx := database.DB.Begin()
+	tx := db.Begin()
 	defer func() {
 		if err != nil {
 			tx.Rollback()
@@ -235,7 +293,7 @@ func UpdateTransaction(updateRequest request.Request, db *gorm.DB, row any, id s
 	var count int64
 	tx.Model(row).Where("id = ?", id).Count(&count)
 	if count == 0 {
-		return fmt.Errorf("entity with specified id does not exist")
+		return requestError("entity with specified id does not exist")
 	}
 
 	// remove previous relationships of specified entities
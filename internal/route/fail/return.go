@@ -2,6 +2,7 @@ package fail
 
 import (
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"encoding/json"
 	"fmt"
 
@@ -14,7 +15,8 @@ type Error struct {
 	Label   string `json:"label"`
 }
 
-func SystemError(requestID any) string {
+// SystemError is returned instead of internal errors, which are only logged
+func SystemError(requestID string) string {
 	return fmt.Sprintf("System error happened. Request ID: %s", requestID)
 }
 
@@ -26,6 +28,7 @@ func ReturnError(ctx *gin.Context, response request.Response, errors []string, s
 
 	response.Errors = errors
 	response.Status = false
+	response.RequestID = ctx.GetString(requestid.ContextKey)
 
 	raw, err = json.Marshal(response)
 	if err != nil {
@@ -0,0 +1,98 @@
+package monitoring
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/server/router"
+	"bookbox-backend/pkg/redis"
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	checkTimeout = 2 * time.Second
+)
+
+// Healthz is the liveness check, it only tells that the server answers. A stopped sync worker
+// is reported by the status route, restarting the process would not fix its cause.
+func Healthz(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{
+		"status": "ok",
+	})
+}
+
+// Readyz is the readiness check of the database, Redis and the migration. The route is public,
+// it only returns ok or fail per check, the errors are logged.
+func Readyz(ctx *gin.Context) {
+	status, code := "ok", 200
+	checks := map[string]string{}
+
+	for name, result := range dependencyChecks(ctx) {
+		if result == "ok" {
+			checks[name] = "ok"
+			continue
+		}
+
+		checks[name] = "fail"
+		status, code = "unavailable", 503
+
+		requestid.Logger(ctx).Warn("readiness check failed",
+			zap.String("check", name),
+			zap.String("error", result),
+		)
+	}
+
+	ctx.JSON(code, gin.H{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// dependencyChecks returns "ok" or the error per dependency
+func dependencyChecks(ctx context.Context) map[string]string {
+	checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	checks := map[string]string{
+		"database":  "ok",
+		"redis":     "ok",
+		"migration": "ok",
+	}
+
+	if err := database.Ping(checkCtx); err != nil {
+		checks["database"] = err.Error()
+	}
+
+	if err := pingRedis(checkCtx); err != nil {
+		checks["redis"] = err.Error()
+	}
+
+	if err := database.MigrationError(); err != nil {
+		checks["migration"] = err.Error()
+	}
+
+	return checks
+}
+
+// pingRedis waits for the ping until ctx is done, the client itself doesn't take a context
+func pingRedis(ctx context.Context) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- redis.Client.Ping().Err()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func init() {
+	router.Router.Handle("GET", "healthz", Healthz)
+	router.Router.Handle("GET", "readyz", Readyz)
+}
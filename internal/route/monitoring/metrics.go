@@ -0,0 +1,40 @@
+package monitoring
+
+import (
+	"bookbox-backend/internal/config"
+	"bookbox-backend/internal/server/router"
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// liveConfig provides METRICS_TOKEN, which protects /metrics with
+	// "Authorization: Bearer <token>". It is always set in production and read on every scrape.
+	liveConfig *config.Live
+
+	metricsHandler = promhttp.Handler()
+)
+
+// Metrics serves the Prometheus metrics, scrapers use the metrics token instead of an api key
+func Metrics(ctx *gin.Context) {
+	if metricsToken := liveConfig.Get().Metrics.Token; metricsToken != "" {
+		given := ctx.GetHeader("Authorization")
+		if subtle.ConstantTimeCompare([]byte(given), []byte("Bearer "+metricsToken)) != 1 {
+			ctx.AbortWithStatus(401)
+			return
+		}
+	}
+
+	metricsHandler.ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+// Setup applies the configuration of the metrics route
+func Setup(live *config.Live) {
+	liveConfig = live
+}
+
+func init() {
+	router.Router.Handle("GET", "metrics", Metrics)
+}
@@ -0,0 +1,163 @@
+package monitoring
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/auth"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/ca"
+	"bookbox-backend/internal/server/processor"
+	"bookbox-backend/internal/server/router"
+	"bookbox-backend/internal/sync"
+	"bookbox-backend/internal/version"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Status is the operational state of the service for admins
+type Status struct {
+	Version      version.Info      `json:"version"`
+	Dependencies map[string]string `json:"dependencies"`
+	Sync         SyncStatus        `json:"sync"`
+	EbookQueue   QueueStatus       `json:"ebook_queue"`
+	Certificate  CertificateStatus `json:"certificate"`
+}
+
+type SyncStatus struct {
+	Worker            sync.WorkerStatus `json:"worker"`
+	IsFullSynced      bool              `json:"is_full_synced"`
+	LastOnixSyncDate  *time.Time        `json:"last_onix_sync_date"`
+	LastAnnotSyncDate *time.Time        `json:"last_annot_sync_date"`
+	LastSuccess       *time.Time        `json:"last_success"`
+	Error             string            `json:"error,omitempty"`
+}
+
+type QueueStatus struct {
+	Pending    int    `json:"pending"`
+	DeadLetter int    `json:"dead_letter"`
+	Error      string `json:"error,omitempty"`
+}
+
+type CertificateStatus struct {
+	File      string     `json:"file,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn string     `json:"expires_in,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// GetStatus reports dependencies, sync progress, ebook queue backlog, certificate expiry and build version
+func GetStatus(ctx *gin.Context) {
+	var (
+		statusResponse = request.Response{}
+	)
+
+	log := requestid.Logger(ctx)
+
+	log.Info("status started")
+
+	issuer, err := auth.GetIssuer(ctx)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, statusResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("only admins can call this route")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, statusResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	status := Status{
+		Version:      version.Get(),
+		Dependencies: dependencyChecks(ctx),
+		Sync:         syncStatus(),
+		EbookQueue:   queueStatus(),
+		Certificate:  certificateStatus(),
+	}
+
+	log.Info("status finished")
+
+	statusResponse.Data = status
+	statusResponse.Status = true
+	ctx.JSON(200, statusResponse)
+}
+
+func syncStatus() (status SyncStatus) {
+	status.Worker = sync.GetWorkerStatus()
+
+	syncData := model.Sync{}
+	res := database.DB.Where("id = ?", "1").Limit(1).Find(&syncData)
+	if res.Error != nil {
+		status.Error = res.Error.Error()
+		return
+	}
+
+	if res.RowsAffected == 0 {
+		status.Error = "sync data does not exist"
+		return
+	}
+
+	status.IsFullSynced = syncData.IsFullSynced
+	status.LastOnixSyncDate = unixTime(syncData.LastOnixSyncDate)
+	status.LastAnnotSyncDate = unixTime(syncData.LastAnnotSyncDate)
+
+	// the row is only written after a successful run
+	if syncData.IsFullSynced {
+		status.LastSuccess = &syncData.UpdatedAt
+	}
+
+	return
+}
+
+func queueStatus() (status QueueStatus) {
+	var err error
+
+	status.Pending, status.DeadLetter, err = processor.QueueDepth()
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	return
+}
+
+func certificateStatus() (status CertificateStatus) {
+	file, notAfter, err := ca.CertificateExpiry()
+	status.File = file
+	if err != nil {
+		status.Error = err.Error()
+		return
+	}
+
+	status.ExpiresAt = &notAfter
+	status.ExpiresIn = time.Until(notAfter).Round(time.Hour).String()
+
+	return
+}
+
+// unixTime returns nil for dates which were never set
+func unixTime(seconds int64) *time.Time {
+	if seconds == 0 {
+		return nil
+	}
+
+	t := time.Unix(seconds, 0).UTC()
+	return &t
+}
+
+func init() {
+	router.Router.Handle("GET", "status", GetStatus)
+}
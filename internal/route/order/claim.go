@@ -0,0 +1,75 @@
+package order
+
+import (
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/auth"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ClaimHandler links the guest orders placed with the verified email of the customer,
+// orders are also linked automatically when the email gets verified
+func ClaimHandler(ctx *gin.Context) {
+	var (
+		claimResponse = request.Response{}
+	)
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("order claim started")
+
+	issuer, err := auth.GetIssuer(ctx)
+	if err != nil || issuer.ID == "" {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("user auth is incorrect")
+		fail.ReturnError(ctx, claimResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", issuer.ID),
+	))
+
+	if issuer.Role != model.UserCustomerRole || !issuer.EmailVerified {
+		err = fmt.Errorf("email must be verified before claiming orders")
+		log.Error("order/claim failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, claimResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	linked, err := auth.LinkGuestOrders(issuer.ID, issuer.Email)
+	if err != nil {
+		log.Error("failed to link guest orders",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, claimResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("order claim finished",
+		zap.Int64("linked", linked),
+	)
+
+	claimResponse.Data = map[string]any{
+		"linked": linked,
+	}
+	claimResponse.Status = true
+	ctx.JSON(200, claimResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "order/claim", ClaimHandler)
+}
@@ -0,0 +1,90 @@
+package order
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/orderaccess"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// StatusHandler returns the order of a signed access token from the confirmation email,
+// guests can't read orders by id
+func StatusHandler(ctx *gin.Context) {
+	var (
+		statusRequest  = request.Request{}
+		statusResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&statusRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, statusResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("order status started")
+
+	token, _ := statusRequest.Data["token"].(string)
+	orderID, err := orderaccess.Verify(token)
+	if err != nil {
+		log.Error("order/status failed",
+			zap.Error(err),
+		)
+
+		err = fmt.Errorf("token is invalid or expired")
+		fail.ReturnError(ctx, statusResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("orderId", orderID),
+	))
+
+	order := model.Order{}
+	res := database.DB.Preload("Products.Product").Where("id = ?", orderID).Limit(1).Find(&order)
+	if res.Error != nil {
+		log.Error("order/status failed",
+			zap.Error(res.Error),
+		)
+
+		fail.ReturnError(ctx, statusResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	if res.RowsAffected == 0 {
+		err = fmt.Errorf("order with specified id does not exist")
+		log.Error("order/status failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, statusResponse, []string{err.Error()}, 404, log)
+		return
+	}
+
+	// the price at the time of the order
+	for i := range order.Products {
+		order.Products[i].Product.SellingPrice = order.Products[i].CurrentPrice
+	}
+
+	log.Info("order status finished")
+
+	statusResponse.Data = order
+	statusResponse.Status = true
+	ctx.JSON(200, statusResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "order/status", StatusHandler)
+}
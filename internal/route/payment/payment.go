@@ -2,10 +2,11 @@ package payment
 
 import (
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/route/auth"
 	"bookbox-backend/internal/route/fail"
 	"bookbox-backend/internal/server/router"
-	"bookbox-backend/pkg/logger"
+	"bookbox-backend/internal/tracing"
 	"bookbox-backend/pkg/payment"
 	"fmt"
 
@@ -19,7 +20,7 @@ func Initialize(ctx *gin.Context) {
 		paymentRequest  = request.Request{}
 	)
 
-	log := logger.Log.WithOptions(zap.Fields())
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
 	log.Info("payment initialize started")
 
 	// check authentication
@@ -38,21 +39,23 @@ func Initialize(ctx *gin.Context) {
 	// Parse the posted JSON data.
 	err = ctx.ShouldBind(&paymentRequest)
 	if err != nil {
-		logger.Log.Error("Failed to bind input data",
+		requestid.Logger(ctx).Error("Failed to bind input data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, paymentResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, paymentResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
-	paymentURL, err := payment.PaymentPageInitialize(paymentRequest)
+	// the call to the payment provider is cancelled with the request and traced like the other
+	// outbound calls
+	paymentURL, err := payment.PaymentPageInitialize(ctx.Request.Context(), tracing.HTTPClient, paymentRequest)
 	if err != nil {
-		logger.Log.Error("payment initialize failed",
+		requestid.Logger(ctx).Error("payment initialize failed",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, paymentResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, paymentResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
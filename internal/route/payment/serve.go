@@ -5,11 +5,12 @@ import (
 	"bookbox-backend/internal/execute/postrun"
 	"bookbox-backend/internal/model"
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/route/crud"
 	"bookbox-backend/internal/route/fail"
 	"bookbox-backend/internal/server/processor"
 	"bookbox-backend/internal/server/router"
-	"bookbox-backend/pkg/logger"
+	"bookbox-backend/internal/tracing"
 	"bookbox-backend/pkg/payment"
 	"bookbox-backend/pkg/redis"
 	"fmt"
@@ -26,7 +27,7 @@ func ServeStatic(ctx *gin.Context) {
 		serveResponse request.Response
 	)
 
-	log := logger.Log.WithOptions(zap.Fields())
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
 
 	log.Info("payment serve started")
 
@@ -102,11 +103,11 @@ func ServeStatic(ctx *gin.Context) {
 		return
 	}
 
-	log = logger.Log.WithOptions(zap.Fields(
+	log = requestid.Logger(ctx).WithOptions(zap.Fields(
 		zap.String("orderId", stored.OrderID),
 	))
 
-	isPaid, err := payment.PaymentAuthorize(stored, log)
+	isPaid, err := payment.PaymentAuthorize(ctx.Request.Context(), tracing.HTTPClient, stored, log)
 	if err != nil {
 		log.Error("error while authorizing",
 			zap.Error(err),
@@ -161,13 +162,13 @@ func ServeStatic(ctx *gin.Context) {
 	}
 
 	id := row.ID
-	err = processor.ProcessOrder(id, logger.Log)
+	err = processor.ProcessOrder(ctx, id, requestid.Logger(ctx))
 	if err != nil {
 		ctx.Redirect(302, stored.ReturnURL)
 		return
 	}
 
-	err = postrun.ProcessPaidOrder(row)
+	err = postrun.ProcessPaidOrder(ctx, row)
 	if err != nil {
 		ctx.Redirect(302, stored.ReturnURL)
 		return
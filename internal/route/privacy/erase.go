@@ -0,0 +1,167 @@
+package privacy
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/auth"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	erasedValue = "erased"
+)
+
+// EraseHandler deletes the account and its personal data. Orders are kept for accounting,
+// with the personal fields anonymized and the user unlinked.
+func EraseHandler(ctx *gin.Context) {
+	var (
+		eraseRequest  = request.Request{}
+		eraseResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&eraseRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, eraseResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("privacy erase started")
+
+	issuer, user, err := subject(ctx, eraseRequest.Data)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, eraseResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", user.ID),
+	))
+
+	if confirm, _ := eraseRequest.Data["confirm"].(bool); !confirm {
+		err = fmt.Errorf("erasure must be confirmed with confirm: true")
+		log.Error("privacy/erase failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, eraseResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	// customers confirm with their password, accounts created through a login provider have none
+	if issuer.ID == user.ID && user.Password != "" {
+		password, _ := eraseRequest.Data["password"].(string)
+		_, err = auth.CheckPassword(user.Email, password)
+		if err != nil {
+			log.Error("privacy/erase failed",
+				zap.Error(err),
+			)
+
+			fail.ReturnError(ctx, eraseResponse, []string{err.Error()}, 403, log)
+			return
+		}
+	}
+
+	err = erase(user)
+	if err != nil {
+		log.Error("privacy/erase failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, eraseResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	err = auth.DestroyAllTokens(user.ID)
+	if err != nil {
+		log.Error("failed to revoke token pairs",
+			zap.Error(err),
+		)
+	}
+
+	audit(ctx, model.AuditDataErasure, user.ID, fmt.Sprintf("requested by %s", issuer.ID))
+
+	log.Info("privacy erase finished")
+
+	eraseResponse.Status = true
+	ctx.JSON(200, eraseResponse)
+}
+
+func erase(user model.User) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// orders cascade with the user, so they are unlinked before it is deleted; guest orders
+		// placed with the email are anonymized too
+		err := tx.Model(&model.Order{}).
+			Where("user_id = ? OR (user_id IS NULL AND LOWER(email) = LOWER(?))", user.ID, user.Email).
+			UpdateColumns(map[string]any{
+				"user_id":          nil,
+				"first_name":       erasedValue,
+				"last_name":        erasedValue,
+				"email":            "",
+				"invoice_address":  erasedValue,
+				"delivery_address": erasedValue,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, row := range []any{&model.Review{}, &model.Favorite{}, &model.UserIdentity{}, &model.UserTwoFactor{}} {
+			err = tx.Where("user_id = ?", user.ID).Delete(row).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// earlier audit entries keep the user id only
+		err = tx.Model(&model.AuditLog{}).
+			Where("user_id = ? OR LOWER(email) = LOWER(?)", user.ID, user.Email).
+			UpdateColumns(map[string]any{
+				"email":      "",
+				"ip":         "",
+				"user_agent": "",
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Delete(&model.User{}, "id = ?", user.ID).Error
+		if err != nil {
+			return err
+		}
+
+		// addresses are referenced by the user, so they go after it
+		for _, addressID := range []*string{user.BillingAddressID, user.DeliveryAddressID} {
+			if addressID == nil {
+				continue
+			}
+
+			err = tx.Delete(&model.Address{}, "id = ?", *addressID).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func init() {
+	router.Router.Handle("POST", "privacy/erase", EraseHandler)
+}
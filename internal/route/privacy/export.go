@@ -0,0 +1,140 @@
+package privacy
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Export is the machine-readable copy of the data stored about a user. Carts are not part of
+// it, they are anonymous and not linked to accounts.
+type Export struct {
+	ExportedAt int64                `json:"exported_at"`
+	User       model.User           `json:"user"`
+	Addresses  []model.Address      `json:"addresses"`
+	Orders     []model.Order        `json:"orders"`
+	Reviews    []model.Review       `json:"reviews"`
+	Favorites  []model.Favorite     `json:"favorites"`
+	Discounts  []model.Discount     `json:"discounts"`
+	Identities []model.UserIdentity `json:"identities"`
+	TwoFactor  bool                 `json:"two_factor_enabled"`
+}
+
+func ExportHandler(ctx *gin.Context) {
+	var (
+		exportRequest  = request.Request{}
+		exportResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBind(&exportRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, exportResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info("privacy export started")
+
+	issuer, user, err := subject(ctx, exportRequest.Data)
+	if err != nil {
+		log.Error("authentication failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, exportResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	log = log.WithOptions(zap.Fields(
+		zap.String("userId", user.ID),
+	))
+
+	export, err := collect(user.ID)
+	if err != nil {
+		log.Error("privacy/export failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, exportResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	audit(ctx, model.AuditDataExport, user.ID, fmt.Sprintf("requested by %s", issuer.ID))
+
+	log.Info("privacy export finished")
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"export-%s.json\"", user.ID))
+	exportResponse.Data = export
+	exportResponse.Status = true
+	ctx.JSON(200, exportResponse)
+}
+
+func collect(userID string) (export Export, err error) {
+	export.ExportedAt = time.Now().Unix()
+
+	err = database.DB.
+		Preload("DeliveryAddress").
+		Preload("BillingAddress").
+		Omit("password").
+		Where("id = ?", userID).
+		First(&export.User).Error
+	if err != nil {
+		return
+	}
+
+	export.Addresses = []model.Address{}
+	for _, address := range []*model.Address{export.User.BillingAddress, export.User.DeliveryAddress} {
+		if address != nil {
+			export.Addresses = append(export.Addresses, *address)
+		}
+	}
+
+	err = database.DB.Preload("Products.Product").Where("user_id = ?", userID).Find(&export.Orders).Error
+	if err != nil {
+		return
+	}
+
+	err = database.DB.Where("user_id = ?", userID).Find(&export.Reviews).Error
+	if err != nil {
+		return
+	}
+
+	err = database.DB.Preload("Product").Where("user_id = ?", userID).Find(&export.Favorites).Error
+	if err != nil {
+		return
+	}
+
+	// discounts issued to the user, general ones are not personal data
+	err = database.DB.Preload("SalesChannels").Where("user_id = ?", userID).Find(&export.Discounts).Error
+	if err != nil {
+		return
+	}
+
+	err = database.DB.Where("user_id = ?", userID).Find(&export.Identities).Error
+	if err != nil {
+		return
+	}
+
+	var count int64
+	err = database.DB.Model(&model.UserTwoFactor{}).Where("user_id = ? AND enabled", userID).Count(&count).Error
+	export.TwoFactor = count > 0
+
+	return
+}
+
+func init() {
+	router.Router.Handle("POST", "privacy/export", ExportHandler)
+}
@@ -0,0 +1,59 @@
+package privacy
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/auth"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// subject returns the user the request is about, customers can only act for themselves
+// while admins pass user_id
+func subject(ctx *gin.Context, data map[string]any) (issuer *model.User, user model.User, err error) {
+	issuer, err = auth.GetIssuer(ctx)
+	if err != nil || issuer.ID == "" {
+		err = fmt.Errorf("user auth is incorrect")
+		return
+	}
+
+	userID, _ := data["user_id"].(string)
+	if userID == "" || userID == issuer.ID {
+		user = *issuer
+		return
+	}
+
+	if issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("only admins can specify user_id")
+		return
+	}
+
+	res := database.DB.Where("id = ?", userID).Limit(1).Find(&user)
+	if res.Error != nil || res.RowsAffected == 0 {
+		err = fmt.Errorf("user with specified id does not exist")
+	}
+
+	return
+}
+
+// audit records a data subject request, the log keeps no personal data besides the user id
+func audit(ctx *gin.Context, action string, userID string, details string) {
+	entry := model.AuditLog{
+		Action:    action,
+		UserID:    userID,
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		Details:   details,
+	}
+
+	err := database.DB.Create(&entry).Error
+	if err != nil {
+		requestid.Logger(ctx).Error("failed to write audit log",
+			zap.String("action", action),
+			zap.Error(err),
+		)
+	}
+}
@@ -10,10 +10,11 @@ import (
 	"bookbox-backend/internal/model"
 	"bookbox-backend/internal/query"
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/route/auth"
 	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/middlewares"
 	"bookbox-backend/internal/server/router"
-	"bookbox-backend/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -28,15 +29,15 @@ func ReadSCProductsHandler(ctx *gin.Context) {
 	// bind input data to request format
 	err := ctx.ShouldBind(&readRequest)
 	if err != nil {
-		logger.Log.Error("Failed to bind input data",
+		requestid.Logger(ctx).Error("Failed to bind input data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
-	log := logger.Log.WithOptions(zap.Fields(
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
 		zap.Any("data", readRequest.Data),
 	))
 
@@ -75,6 +76,17 @@ func ReadSCProductsHandler(ctx *gin.Context) {
 		return
 	}
 
+	// check api key scope
+	if !middlewares.GetAPIKey(ctx).Allows("read", "product") || !middlewares.GetAPIKey(ctx).AllowsSalesChannel(readRequest.Data.SalesChannelID) {
+		err = fmt.Errorf("api key is not authorized for this request")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
 	// if authorized, add universal filter
 	prerun.UniversalFilter(&readRequest, issuer)
 
@@ -89,7 +101,7 @@ func ReadSCProductsHandler(ctx *gin.Context) {
 			zap.Error(res.Error),
 		)
 
-		fail.ReturnError(ctx, readResponse, []string{fail.SystemError(res.Error)}, 400, log)
+		fail.ReturnError(ctx, readResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 		return
 	}
 
@@ -108,7 +120,7 @@ func ReadSCProductsHandler(ctx *gin.Context) {
 			zap.Error(res.Error),
 		)
 
-		fail.ReturnError(ctx, readResponse, []string{fail.SystemError(res.Error)}, 400, log)
+		fail.ReturnError(ctx, readResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 		return
 	}
 
@@ -117,7 +129,18 @@ func ReadSCProductsHandler(ctx *gin.Context) {
 			zap.Error(res.Error),
 		)
 
-		fail.ReturnError(ctx, readResponse, []string{fail.SystemError(res.Error)}, 400, log)
+		fail.ReturnError(ctx, readResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	if scProducts.Hidden && issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("product with specified id does not exist")
+		log.Warn("product is hidden in the sales channel",
+			zap.String("id", readRequest.Data.ID),
+			zap.String("reason", scProducts.HiddenReason),
+		)
+
+		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 404, log)
 		return
 	}
 
@@ -151,16 +174,16 @@ func ListSCProductsHandler(ctx *gin.Context) {
 	// bind input data to request format
 	err := ctx.ShouldBind(&listRequest)
 	if err != nil {
-		logger.Log.Error("Failed to bind input data",
+		requestid.Logger(ctx).Error("Failed to bind input data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
 	listRequest.Entity = "product"
-	log := logger.Log.WithOptions(zap.Fields(
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
 		zap.String("entity", listRequest.Entity),
 		zap.Any("metadata", listRequest.Metadata),
 	))
@@ -200,6 +223,17 @@ func ListSCProductsHandler(ctx *gin.Context) {
 		return
 	}
 
+	// check api key scope
+	if !middlewares.GetAPIKey(ctx).Allows("list", "product") || !middlewares.GetAPIKey(ctx).AllowsSalesChannel(listRequest.Data.SalesChannelID) {
+		err = fmt.Errorf("api key is not authorized for this request")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
 	// if authorized, add universal filter
 	prerun.UniversalFilter(&listRequest, issuer)
 
@@ -246,7 +280,7 @@ func ListSCProductsHandler(ctx *gin.Context) {
 			zap.Error(res.Error),
 		)
 
-		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(res.Error)}, 400, log)
+		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 		return
 	}
 
@@ -267,7 +301,7 @@ func ListSCProductsHandler(ctx *gin.Context) {
 			zap.Error(res.Error),
 		)
 
-		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(res.Error)}, 400, log)
+		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 		return
 	}
 
@@ -296,7 +330,7 @@ func ListSCProductsHandler(ctx *gin.Context) {
 				zap.Error(res.Error),
 			)
 
-			fail.ReturnError(ctx, listResponse, []string{fail.SystemError(res.Error)}, 400, log)
+			fail.ReturnError(ctx, listResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 			return
 		}
 
@@ -305,7 +339,7 @@ func ListSCProductsHandler(ctx *gin.Context) {
 				zap.Error(res.Error),
 			)
 
-			fail.ReturnError(ctx, listResponse, []string{fail.SystemError(res.Error)}, 400, log)
+			fail.ReturnError(ctx, listResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 			return
 		}
 
@@ -4,10 +4,11 @@ import (
 	"bookbox-backend/internal/database"
 	"bookbox-backend/internal/model"
 	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
 	"bookbox-backend/internal/route/auth"
 	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/middlewares"
 	"bookbox-backend/internal/server/router"
-	"bookbox-backend/pkg/logger"
 	"fmt"
 
 	"github.com/gin-gonic/gin"
@@ -26,15 +27,15 @@ func UpdateSCProductsHandler(ctx *gin.Context) {
 	// bind input data to request format
 	err := ctx.ShouldBindJSON(&updateSCProductsRequest)
 	if err != nil {
-		logger.Log.Error("Failed to bind input data",
+		requestid.Logger(ctx).Error("Failed to bind input data",
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 400, logger.Log)
+		fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
 		return
 	}
 
-	log := logger.Log.WithOptions(zap.Fields(
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
 		zap.String("sales_channel_id", updateSCProductsRequest.SalesChannelID),
 		zap.String("product_id", updateSCProductsRequest.ProductID),
 		zap.Float64("changed_price", updateSCProductsRequest.ChangedPrice),
@@ -75,6 +76,17 @@ func UpdateSCProductsHandler(ctx *gin.Context) {
 		return
 	}
 
+	// check api key scope
+	if !middlewares.GetAPIKey(ctx).Allows("update", "product") || !middlewares.GetAPIKey(ctx).AllowsSalesChannel(updateSCProductsRequest.SalesChannelID) {
+		err = fmt.Errorf("api key is not authorized for this request")
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
 	if updateSCProductsRequest.ProductID == "" {
 		err = fmt.Errorf("product id is empty")
 		log.Error("Data missing fields",
@@ -111,7 +123,7 @@ func UpdateSCProductsHandler(ctx *gin.Context) {
 			zap.Error(err),
 		)
 
-		fail.ReturnError(ctx, updateResponse, []string{err.Error()}, 400, log)
+		fail.ReturnError(ctx, updateResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
 		return
 	}
 
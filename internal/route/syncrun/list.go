@@ -0,0 +1,86 @@
+package syncrun
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/query"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ListHandler returns the sync runs, newest first, without their files
+func ListHandler(ctx *gin.Context) {
+	var (
+		listRequest  = request.GetRequest{}
+		listResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBindJSON(&listRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.Int("limit", listRequest.Metadata.Limit),
+		zap.Int("offset", listRequest.Metadata.Offset),
+	))
+
+	log.Info("sync/runs/list started")
+
+	_, err = admin(ctx)
+	if err != nil {
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, listResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	runs := []model.SyncRun{}
+	err = database.DB.
+		Scopes(query.Paginate(listRequest.Metadata.Limit, listRequest.Metadata.Offset)).
+		Order("started_at desc").
+		Find(&runs).Error
+	if err != nil {
+		log.Error("failed to list sync runs",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, listResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	// Paginate loads one row more than the limit to tell whether there is a next page
+	if listRequest.Metadata.Limit != 0 && listRequest.Metadata.Offset != 0 {
+		if len(runs) > listRequest.Metadata.Limit {
+			listResponse.NextOffset = listRequest.Metadata.Offset + 1
+			runs = runs[:listRequest.Metadata.Limit]
+		} else {
+			listResponse.NextOffset = -1
+		}
+	}
+
+	log.Info("sync/runs/list finished",
+		zap.Int("count", len(runs)),
+	)
+
+	listResponse.Data = runs
+	listResponse.Total = len(runs)
+	listResponse.Status = true
+	ctx.JSON(200, listResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "sync/runs/list", ListHandler)
+}
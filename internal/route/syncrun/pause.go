@@ -0,0 +1,67 @@
+package syncrun
+
+import (
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"bookbox-backend/internal/sync"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// PauseHandler stops the scheduled syncs, a running sync finishes first
+func PauseHandler(ctx *gin.Context) {
+	setPaused(ctx, "sync/pause", true)
+}
+
+// ResumeHandler starts the scheduled syncs again
+func ResumeHandler(ctx *gin.Context) {
+	setPaused(ctx, "sync/resume", false)
+}
+
+func setPaused(ctx *gin.Context, route string, paused bool) {
+	var (
+		pauseResponse = request.Response{}
+	)
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields())
+
+	log.Info(route + " started")
+
+	issuer, err := admin(ctx)
+	if err != nil {
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, pauseResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	err = sync.SetPaused(paused)
+	if err != nil {
+		log.Error("failed to update sync",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, pauseResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info(route+" finished",
+		zap.String("issuerId", issuer.ID),
+	)
+
+	pauseResponse.Data = map[string]any{
+		"paused": paused,
+	}
+	pauseResponse.Status = true
+	ctx.JSON(200, pauseResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "sync/pause", PauseHandler)
+	router.Router.Handle("POST", "sync/resume", ResumeHandler)
+}
@@ -0,0 +1,98 @@
+package syncrun
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ReadHandler returns one sync run with the files it wrote
+func ReadHandler(ctx *gin.Context) {
+	var (
+		readRequest  = request.GetRequest{}
+		readResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBindJSON(&readRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.String("syncRunId", readRequest.Data.ID),
+	))
+
+	log.Info("sync/runs/read started")
+
+	_, err = admin(ctx)
+	if err != nil {
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if readRequest.Data.ID == "" {
+		err = fmt.Errorf("id is empty")
+		log.Error("Data missing fields",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	run := model.SyncRun{}
+	res := database.DB.
+		Preload("Files", func(db *gorm.DB) *gorm.DB {
+			return db.Order("started_at")
+		}).
+		Where("id = ?", readRequest.Data.ID).
+		Limit(1).
+		Find(&run)
+	if res.Error != nil {
+		log.Error("failed to read sync run",
+			zap.Error(res.Error),
+		)
+
+		fail.ReturnError(ctx, readResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	if res.RowsAffected == 0 {
+		err = fmt.Errorf("sync run with specified id does not exist")
+		log.Error("sync/runs/read failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, readResponse, []string{err.Error()}, 404, log)
+		return
+	}
+
+	log.Info("sync/runs/read finished",
+		zap.Int("files", len(run.Files)),
+	)
+
+	readResponse.Data = run
+	readResponse.Status = true
+	ctx.JSON(200, readResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "sync/runs/read", ReadHandler)
+}
@@ -0,0 +1,25 @@
+package syncrun
+
+import (
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/route/auth"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// admin checks that the sync routes are called by an admin
+func admin(ctx *gin.Context) (issuer *model.User, err error) {
+	issuer, err = auth.GetIssuer(ctx)
+	if err != nil || issuer.ID == "" {
+		err = fmt.Errorf("user auth is incorrect")
+		return
+	}
+
+	if issuer.Role != model.UserAdminRole {
+		err = fmt.Errorf("only admins can call this route")
+		return
+	}
+
+	return
+}
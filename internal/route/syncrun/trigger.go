@@ -0,0 +1,93 @@
+package syncrun
+
+import (
+	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/request"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/server/router"
+	"bookbox-backend/internal/sync"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TriggerHandler asks the worker to start a full or partial sync now, also while the sync is
+// paused. The runs show up in sync/runs/list once an instance picked them up.
+func TriggerHandler(ctx *gin.Context) {
+	var (
+		triggerRequest  = request.Request{}
+		triggerResponse = request.Response{}
+	)
+
+	err := ctx.ShouldBindJSON(&triggerRequest)
+	if err != nil {
+		requestid.Logger(ctx).Error("Failed to bind input data",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, triggerResponse, []string{err.Error()}, 400, requestid.Logger(ctx))
+		return
+	}
+
+	mode, _ := triggerRequest.Data["mode"].(string)
+	log := requestid.Logger(ctx).WithOptions(zap.Fields(
+		zap.String("mode", mode),
+	))
+
+	log.Info("sync/trigger started")
+
+	issuer, err := admin(ctx)
+	if err != nil {
+		log.Error("authorization failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, triggerResponse, []string{err.Error()}, 403, log)
+		return
+	}
+
+	if mode != model.SyncModeFull && mode != model.SyncModePartial {
+		err = fmt.Errorf("mode must be %s or %s", model.SyncModeFull, model.SyncModePartial)
+		log.Error("Data missing fields",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, triggerResponse, []string{err.Error()}, 400, log)
+		return
+	}
+
+	err = sync.RequestSync(mode)
+	if errors.Is(err, sync.ErrSyncRequested) {
+		log.Error("sync/trigger failed",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, triggerResponse, []string{err.Error()}, 409, log)
+		return
+	}
+	if err != nil {
+		log.Error("failed to request sync",
+			zap.Error(err),
+		)
+
+		fail.ReturnError(ctx, triggerResponse, []string{fail.SystemError(ctx.GetString("id"))}, 500, log)
+		return
+	}
+
+	log.Info("sync/trigger finished",
+		zap.String("issuerId", issuer.ID),
+	)
+
+	triggerResponse.Data = map[string]any{
+		"mode": mode,
+	}
+	triggerResponse.Status = true
+	ctx.JSON(200, triggerResponse)
+}
+
+func init() {
+	router.Router.Handle("POST", "sync/trigger", TriggerHandler)
+}
@@ -0,0 +1,207 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchLimit bounds the search for the next window, e.g. "0 0 30 2 *" never matches
+const searchLimit = 5
+
+// Spec is a parsed cron expression with the five fields minute, hour, day of month, month and
+// day of week. Every field is a bit set of the values it matches.
+type Spec struct {
+	expr string
+
+	minute, hour, dom, month, dow uint64
+
+	// a restricted day of month and day of week match when either matches, like cron does
+	domAny, dowAny bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is Sunday as well
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// Parse reads a cron expression like "30 2 * * *" or "0 */6 * * mon-fri". Fields take values,
+// ranges (1-5), steps (*/15, 1-30/2) and lists (1,15); months and weekdays also take names.
+// The descriptors @hourly, @daily, @weekly, @monthly and @yearly are accepted too.
+func Parse(expr string) (spec Spec, err error) {
+	spec.expr = strings.TrimSpace(expr)
+
+	fields := strings.Fields(spec.expr)
+	if len(fields) == 1 {
+		if descriptor, ok := descriptors[strings.ToLower(fields[0])]; ok {
+			fields = strings.Fields(descriptor)
+		}
+	}
+
+	if len(fields) != 5 {
+		err = fmt.Errorf("cron expression %q: expected 5 fields, got %d", expr, len(fields))
+		return
+	}
+
+	values := []*uint64{&spec.minute, &spec.hour, &spec.dom, &spec.month, &spec.dow}
+	for i, f := range []field{minuteField, hourField, domField, monthField, dowField} {
+		*values[i], err = f.parse(fields[i])
+		if err != nil {
+			err = fmt.Errorf("cron expression %q: %w", expr, err)
+			return
+		}
+	}
+
+	// Sunday is 0 and 7
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+
+	spec.domAny = fields[2] == "*" || fields[2] == "?"
+	spec.dowAny = fields[4] == "*" || fields[4] == "?"
+	return
+}
+
+func (f field) parse(text string) (bits uint64, err error) {
+	for _, part := range strings.Split(text, ",") {
+		rangeAndStep := strings.SplitN(part, "/", 2)
+
+		start, end := f.min, f.max
+		switch r := rangeAndStep[0]; {
+		case r == "*" || r == "?":
+		case strings.Contains(r, "-"):
+			bounds := strings.SplitN(r, "-", 2)
+			start, err = f.value(bounds[0])
+			if err == nil {
+				end, err = f.value(bounds[1])
+			}
+		default:
+			start, err = f.value(r)
+			end = start
+			// "5/15" counts from 5 to the end of the field
+			if len(rangeAndStep) == 2 {
+				end = f.max
+			}
+		}
+		if err != nil {
+			return
+		}
+
+		if start > end {
+			err = fmt.Errorf("%s: range %q goes backwards", f.name, part)
+			return
+		}
+
+		step := 1
+		if len(rangeAndStep) == 2 {
+			step, err = strconv.Atoi(rangeAndStep[1])
+			if err != nil || step <= 0 {
+				err = fmt.Errorf("%s: invalid step in %q", f.name, part)
+				return
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return
+}
+
+func (f field) value(text string) (v int, err error) {
+	if named, ok := f.names[strings.ToLower(text)]; ok {
+		return named, nil
+	}
+
+	v, err = strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid value %q", f.name, text)
+	}
+
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: %d is not between %d and %d", f.name, v, f.min, f.max)
+	}
+
+	return
+}
+
+// String returns the expression as it was parsed
+func (s Spec) String() string {
+	return s.expr
+}
+
+// Next returns the first window after t, in the location of t. It is zero when the expression
+// matches no date within the next years.
+func (s Spec) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(searchLimit, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s Spec) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+
+	return dom || dow
+}
@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{name: "empty", expr: ""},
+		{name: "four fields", expr: "* * * *"},
+		{name: "six fields", expr: "0 * * * * *"},
+		{name: "unknown descriptor", expr: "@every"},
+		{name: "minute out of range", expr: "60 * * * *"},
+		{name: "hour out of range", expr: "* 24 * * *"},
+		{name: "day of month zero", expr: "* * 0 * *"},
+		{name: "month out of range", expr: "* * * 13 *"},
+		{name: "day of week out of range", expr: "* * * * 8"},
+		{name: "backwards range", expr: "5-1 * * * *"},
+		{name: "zero step", expr: "*/0 * * * *"},
+		{name: "invalid step", expr: "*/x * * * *"},
+		{name: "invalid value", expr: "x * * * *"},
+		{name: "double range", expr: "1-2-3 * * * *"},
+		{name: "weekday name as month", expr: "* * * mon *"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Parse(test.expr); err == nil {
+				t.Errorf("%q is accepted", test.expr)
+			}
+		})
+	}
+}
+
+func TestSpecNext(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	// 2024-01-01 is a Monday
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{name: "later the same day", expr: "30 2 * * *", from: at(2024, 1, 1, 0, 0), want: at(2024, 1, 1, 2, 30)},
+		{name: "window itself is excluded", expr: "30 2 * * *", from: at(2024, 1, 1, 2, 30), want: at(2024, 1, 2, 2, 30)},
+		{name: "seconds are truncated", expr: "30 2 * * *", from: at(2024, 1, 1, 2, 29).Add(59 * time.Second), want: at(2024, 1, 1, 2, 30)},
+		{name: "range", expr: "0 9-11 * * *", from: at(2024, 1, 1, 10, 0), want: at(2024, 1, 1, 11, 0)},
+		{name: "range wraps to the next day", expr: "0 9-11 * * *", from: at(2024, 1, 1, 11, 0), want: at(2024, 1, 2, 9, 0)},
+		{name: "step", expr: "*/15 * * * *", from: at(2024, 1, 1, 10, 7), want: at(2024, 1, 1, 10, 15)},
+		{name: "step from a value", expr: "5/20 * * * *", from: at(2024, 1, 1, 10, 26), want: at(2024, 1, 1, 10, 45)},
+		{name: "step in a range", expr: "1-30/10 * * * *", from: at(2024, 1, 1, 10, 12), want: at(2024, 1, 1, 10, 21)},
+		{name: "step in a range wraps", expr: "1-30/10 * * * *", from: at(2024, 1, 1, 10, 22), want: at(2024, 1, 1, 11, 1)},
+		{name: "list", expr: "0 0 1,15 * *", from: at(2024, 1, 2, 0, 0), want: at(2024, 1, 15, 0, 0)},
+		{name: "weekday names", expr: "0 8 * * mon-fri", from: at(2024, 1, 6, 0, 0), want: at(2024, 1, 8, 8, 0)},
+		{name: "upper case names", expr: "0 0 * * SUN", from: at(2024, 1, 1, 0, 0), want: at(2024, 1, 7, 0, 0)},
+		{name: "month name", expr: "0 0 1 jun *", from: at(2024, 1, 1, 0, 0), want: at(2024, 6, 1, 0, 0)},
+		{name: "day of month only", expr: "0 0 13 * *", from: at(2024, 1, 1, 0, 0), want: at(2024, 1, 13, 0, 0)},
+		{name: "day of month or weekday, weekday first", expr: "0 0 13 * fri", from: at(2024, 1, 1, 0, 0), want: at(2024, 1, 5, 0, 0)},
+		{name: "day of month or weekday, day first", expr: "0 0 13 * fri", from: at(2024, 1, 12, 0, 0), want: at(2024, 1, 13, 0, 0)},
+		{name: "sunday as 7", expr: "0 0 * * 7", from: at(2024, 1, 1, 0, 0), want: at(2024, 1, 7, 0, 0)},
+		{name: "range up to sunday as 7", expr: "0 0 * * 5-7", from: at(2024, 1, 6, 0, 0), want: at(2024, 1, 7, 0, 0)},
+		{name: "month without the day", expr: "0 0 31 * *", from: at(2024, 1, 31, 0, 0), want: at(2024, 3, 31, 0, 0)},
+		{name: "leap day", expr: "0 0 29 2 *", from: at(2024, 3, 1, 0, 0), want: at(2028, 2, 29, 0, 0)},
+		{name: "hourly", expr: "@hourly", from: at(2024, 1, 1, 10, 30), want: at(2024, 1, 1, 11, 0)},
+		{name: "weekly", expr: "@weekly", from: at(2024, 1, 1, 0, 0), want: at(2024, 1, 7, 0, 0)},
+		{name: "yearly", expr: "@yearly", from: at(2024, 1, 1, 0, 0), want: at(2025, 1, 1, 0, 0)},
+		{name: "day that never comes", expr: "0 0 30 2 *", from: at(2024, 1, 1, 0, 0), want: time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec, err := Parse(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := spec.Next(test.from); !got.Equal(test.want) {
+				t.Errorf("next window of %q after %s is %s, want %s", test.expr, test.from, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package schedule
+
+import (
+	"bookbox-backend/internal/database"
+	"context"
+	"database/sql"
+	"hash/fnv"
+)
+
+// lock is a Postgres advisory lock. It belongs to the session, so it is held on a connection
+// taken out of the pool until it is released; if the instance dies the lock goes with the session.
+type lock struct {
+	key  int64
+	conn *sql.Conn
+}
+
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("bookbox:schedule:" + name))
+
+	return int64(h.Sum64())
+}
+
+// tryLock takes the lock without waiting, ok is false when another instance holds it
+func tryLock(ctx context.Context, name string) (l *lock, ok bool, err error) {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return
+	}
+
+	key := lockKey(name)
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok)
+	if err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+
+	return &lock{key: key, conn: conn}, true, nil
+}
+
+// release unlocks and returns the connection to the pool, also after the shutdown started
+func (l *lock) release() error {
+	defer l.conn.Close()
+
+	_, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key)
+	return err
+}
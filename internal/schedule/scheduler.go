@@ -0,0 +1,261 @@
+package schedule
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// retryInterval is the wait after a failed run before the window is tried again
+	retryInterval = 5 * time.Minute
+)
+
+// Job is a task which runs on the windows of its schedule
+type Job struct {
+	Name string
+	Spec Spec
+
+	// Lock is the advisory lock held while the job runs, jobs with the same lock never run at
+	// the same time on any instance. It defaults to the name.
+	Lock string
+
+	Run func(ctx context.Context, run Run) error
+}
+
+// Run tells a job why it runs
+type Run struct {
+	// Window is the latest due window, zero for a run which was only requested
+	Window time.Time
+
+	// Missed counts the due windows before Window, they are caught up by this run
+	Missed int
+
+	// Requested is set for runs requested outside of the schedule, also when a window is due
+	// at the same time
+	Requested bool
+}
+
+// Scheduler runs jobs on their cron schedules. The state of every job is kept in the
+// scheduled_jobs table and a job only runs on the instance which holds its lock, so any number
+// of instances can run the scheduler.
+type Scheduler struct {
+	log  *zap.Logger
+	jobs []Job
+	wake chan struct{}
+}
+
+func New(log *zap.Logger) *Scheduler {
+	return &Scheduler{
+		log:  log,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Add registers a job, the jobs are checked in the order they were added
+func (s *Scheduler) Add(job Job) {
+	if job.Lock == "" {
+		job.Lock = job.Name
+	}
+
+	s.jobs = append(s.jobs, job)
+}
+
+// Run checks the jobs at the start of every minute and runs the due ones, one after the other,
+// until ctx is cancelled. A job whose lock is held by another instance is checked again in the
+// next minute.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		for _, job := range s.jobs {
+			if ctx.Err() != nil {
+				return
+			}
+
+			s.check(ctx, job)
+		}
+
+		now := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+		}
+	}
+}
+
+// Trigger requests a run of the jobs outside of their schedules, it starts on whichever
+// instance checks the job first
+func (s *Scheduler) Trigger(names ...string) error {
+	for _, name := range names {
+		err := database.DB.
+			Where(model.ScheduledJob{Name: name}).
+			Assign(model.ScheduledJob{Requested: true}).
+			FirstOrCreate(&model.ScheduledJob{}).
+			Error
+		if err != nil {
+			return err
+		}
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (s *Scheduler) check(ctx context.Context, job Job) {
+	log := s.log.WithOptions(zap.Fields(
+		zap.String("job", job.Name),
+	))
+
+	state, err := loadState(job)
+	if err != nil {
+		log.Error("failed to load job state", zap.Error(err))
+		return
+	}
+
+	if _, ok := job.due(state, time.Now()); !ok {
+		return
+	}
+
+	l, ok, err := tryLock(ctx, job.Lock)
+	if err != nil {
+		log.Error("failed to lock job", zap.Error(err))
+		return
+	}
+	if !ok {
+		log.Debug("job is locked by another instance")
+		return
+	}
+	defer func() {
+		err := l.release()
+		if err != nil {
+			log.Warn("failed to unlock job", zap.Error(err))
+		}
+	}()
+
+	// another instance may have run the job between the check and the lock
+	state, err = loadState(job)
+	if err != nil {
+		log.Error("failed to load job state", zap.Error(err))
+		return
+	}
+
+	run, ok := job.due(state, time.Now())
+	if !ok {
+		return
+	}
+
+	s.run(ctx, job, state, run, log)
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job, state model.ScheduledJob, run Run, log *zap.Logger) {
+	started := time.Now()
+	err := database.DB.Model(&state).Updates(map[string]any{
+		"schedule":        job.Spec.String(),
+		"last_started_at": started,
+		"requested":       false,
+	}).Error
+	if err != nil {
+		log.Error("failed to start job", zap.Error(err))
+		return
+	}
+
+	log.Info("job started",
+		zap.Time("window", run.Window),
+		zap.Int("missedWindows", run.Missed),
+		zap.Bool("requested", run.Requested),
+	)
+
+	err = job.Run(ctx, run)
+	if ctx.Err() != nil {
+		// the window stays due and runs after the restart, as does the request
+		log.Warn("job interrupted by shutdown", zap.Error(err))
+
+		if run.Requested {
+			err = database.DB.Model(&state).Update("requested", true).Error
+			if err != nil {
+				log.Error("failed to keep job request", zap.Error(err))
+			}
+		}
+		return
+	}
+
+	finished := time.Now()
+	fields := map[string]any{
+		"last_finished_at": finished,
+		"last_error":       "",
+	}
+
+	if err != nil {
+		fields["last_error"] = err.Error()
+		log.Error("job failed, will try again",
+			zap.Duration("retryIn", retryInterval),
+			zap.Error(err),
+		)
+	} else {
+		// a run which was only requested doesn't replace the scheduled one
+		if !run.Window.IsZero() {
+			fields["last_window"] = run.Window
+		}
+
+		log.Info("job finished",
+			zap.Duration("duration", finished.Sub(started)),
+		)
+	}
+
+	err = database.DB.Model(&state).Updates(fields).Error
+	if err != nil {
+		log.Error("failed to save job state", zap.Error(err))
+	}
+}
+
+// due tells whether the job runs now. Windows missed while no instance was up run once, as
+// the latest of them; a job which never ran starts right away. A request is kept in the run
+// when a window is due as well.
+func (job Job) due(state model.ScheduledJob, now time.Time) (run Run, ok bool) {
+	if state.LastError != "" && !state.Requested &&
+		state.LastFinishedAt != nil && now.Before(state.LastFinishedAt.Add(retryInterval)) {
+		return
+	}
+
+	run.Requested = state.Requested
+
+	if state.LastWindow.IsZero() {
+		run.Window = now.Truncate(time.Minute)
+		return run, true
+	}
+
+	next := job.Spec.Next(state.LastWindow.In(now.Location()))
+	if next.IsZero() || next.After(now) {
+		return run, state.Requested
+	}
+
+	for {
+		following := job.Spec.Next(next)
+		if following.IsZero() || following.After(now) {
+			break
+		}
+
+		next = following
+		run.Missed++
+	}
+
+	run.Window = next
+	return run, true
+}
+
+func loadState(job Job) (state model.ScheduledJob, err error) {
+	err = database.DB.
+		Where(model.ScheduledJob{Name: job.Name}).
+		Attrs(model.ScheduledJob{Schedule: job.Spec.String()}).
+		FirstOrCreate(&state).
+		Error
+	return
+}
@@ -0,0 +1,130 @@
+package schedule
+
+import (
+	"bookbox-backend/internal/model"
+	"testing"
+	"time"
+)
+
+func TestJobDue(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	ago := func(d time.Duration) *time.Time {
+		finished := at(10, 12, 0).Add(-d)
+		return &finished
+	}
+
+	daily, err := Parse("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	never, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := at(10, 12, 0)
+
+	tests := []struct {
+		name   string
+		spec   Spec
+		state  model.ScheduledJob
+		want   Run
+		wantOK bool
+	}{
+		{
+			name:   "never run",
+			spec:   daily,
+			want:   Run{Window: now},
+			wantOK: true,
+		},
+		{
+			name:   "never run and requested",
+			spec:   daily,
+			state:  model.ScheduledJob{Requested: true},
+			want:   Run{Window: now, Requested: true},
+			wantOK: true,
+		},
+		{
+			name:  "latest window ran",
+			spec:  daily,
+			state: model.ScheduledJob{LastWindow: at(10, 2, 0)},
+		},
+		{
+			name:   "one window due",
+			spec:   daily,
+			state:  model.ScheduledJob{LastWindow: at(9, 2, 0)},
+			want:   Run{Window: at(10, 2, 0)},
+			wantOK: true,
+		},
+		{
+			name:   "missed windows run once as the latest",
+			spec:   daily,
+			state:  model.ScheduledJob{LastWindow: at(6, 2, 0)},
+			want:   Run{Window: at(10, 2, 0), Missed: 3},
+			wantOK: true,
+		},
+		{
+			name:   "requested without a due window",
+			spec:   daily,
+			state:  model.ScheduledJob{LastWindow: at(10, 2, 0), Requested: true},
+			want:   Run{Requested: true},
+			wantOK: true,
+		},
+		{
+			name:   "requested with a due window",
+			spec:   daily,
+			state:  model.ScheduledJob{LastWindow: at(9, 2, 0), Requested: true},
+			want:   Run{Window: at(10, 2, 0), Requested: true},
+			wantOK: true,
+		},
+		{
+			name:  "failed run waits for the retry",
+			spec:  daily,
+			state: model.ScheduledJob{LastWindow: at(9, 2, 0), LastError: "failed", LastFinishedAt: ago(2 * time.Minute)},
+		},
+		{
+			name:   "failed run is retried",
+			spec:   daily,
+			state:  model.ScheduledJob{LastWindow: at(9, 2, 0), LastError: "failed", LastFinishedAt: ago(retryInterval)},
+			want:   Run{Window: at(10, 2, 0)},
+			wantOK: true,
+		},
+		{
+			name:   "request skips the retry wait",
+			spec:   daily,
+			state:  model.ScheduledJob{LastWindow: at(9, 2, 0), LastError: "failed", LastFinishedAt: ago(2 * time.Minute), Requested: true},
+			want:   Run{Window: at(10, 2, 0), Requested: true},
+			wantOK: true,
+		},
+		{
+			name:  "schedule without windows",
+			spec:  never,
+			state: model.ScheduledJob{LastWindow: at(1, 0, 0)},
+		},
+		{
+			name:   "schedule without windows and requested",
+			spec:   never,
+			state:  model.ScheduledJob{LastWindow: at(1, 0, 0), Requested: true},
+			want:   Run{Requested: true},
+			wantOK: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := Job{Name: "test", Spec: test.spec}
+
+			got, ok := job.due(test.state, now)
+			if ok != test.wantOK {
+				t.Fatalf("due is %v, want %v", ok, test.wantOK)
+			}
+
+			if !got.Window.Equal(test.want.Window) || got.Missed != test.want.Missed || got.Requested != test.want.Requested {
+				t.Errorf("run is %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
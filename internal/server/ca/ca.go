@@ -2,6 +2,8 @@ package ca
 
 import (
 	"bytes"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,6 +11,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	libIO "bookbox-backend/pkg/io"
 
@@ -98,6 +101,34 @@ func GetPrivateKey() (loc string) {
 	return
 }
 
+// CertificateExpiry returns the end of validity of the server certificate
+func CertificateExpiry() (certFile string, notAfter time.Time, err error) {
+	certFile = GetCertificate()
+	if certFile == "" {
+		err = fmt.Errorf("certificate does not exist")
+		return
+	}
+
+	raw, err := os.ReadFile(certFile)
+	if err != nil {
+		return
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		err = fmt.Errorf("certificate is not PEM encoded")
+		return
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return
+	}
+
+	notAfter = cert.NotAfter
+	return
+}
+
 // AutoInjectCA generates and inserts Root CA into trust store
 func AutoInjectCA() {
 	args := []string{
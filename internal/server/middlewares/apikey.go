@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/pkg/crypto"
+	"bookbox-backend/pkg/logger"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	APIKeyHeader     = "x-api-key"
+	apiKeyContextKey = "apiKey"
+
+	// last_used_at is only written once per interval to avoid a write on every request
+	lastUsedInterval = time.Minute
+)
+
+// apiKeyRequired is API_KEY_REQUIRED, without it and AUTH_KEY requests need no api key
+var apiKeyRequired bool
+
+// PublicPaths are served without an api key, e.g. for clients that only verify tokens
+var PublicPaths = map[string]bool{
+	"/.well-known/jwks.json": true,
+	"/metrics":               true,
+	"/healthz":               true,
+	"/readyz":                true,
+}
+
+// HashAPIKey returns the hash under which an API key is stored
+func HashAPIKey(key string) string {
+	return crypto.SHA256(key)
+}
+
+// CheckAuthKey validates the x-api-key header against the stored API keys
+func CheckAuthKey() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer middlewareRecovery()
+
+		// the legacy global key from AUTH_KEY, it is accepted with unrestricted scope
+		authKey := liveConfig.Get().API.AuthKey
+
+		if PublicPaths[ctx.Request.URL.Path] || (authKey == "" && !apiKeyRequired) {
+			ctx.Next()
+			return
+		}
+
+		key := ctx.GetHeader(APIKeyHeader)
+		if key == "" {
+			ctx.AbortWithError(401, fmt.Errorf("api key is missing"))
+			return
+		}
+
+		if authKey != "" && key == authKey {
+			ctx.Next()
+			return
+		}
+
+		if !strings.HasPrefix(key, model.APIKeyPrefix) {
+			ctx.AbortWithError(401, fmt.Errorf("api key provided is incorrect"))
+			return
+		}
+
+		apiKey := model.APIKey{}
+		res := database.DB.Where("key_hash = ?", HashAPIKey(key)).Limit(1).Find(&apiKey)
+		if res.Error != nil || res.RowsAffected == 0 || !apiKey.IsUsable() {
+			ctx.AbortWithError(401, fmt.Errorf("api key provided is incorrect"))
+			return
+		}
+
+		now := time.Now()
+		if now.Unix()-apiKey.LastUsedAt > int64(lastUsedInterval.Seconds()) {
+			err := database.DB.Model(&apiKey).UpdateColumn("last_used_at", now.Unix()).Error
+			if err != nil {
+				logger.Log.Warn("failed to update api key last used",
+					zap.String("apiKeyId", apiKey.ID),
+					zap.Error(err),
+				)
+			}
+		}
+
+		ctx.Set(apiKeyContextKey, &apiKey)
+		ctx.Next()
+	}
+}
+
+// GetAPIKey returns the API key used for the request, nil means unrestricted
+func GetAPIKey(ctx *gin.Context) *model.APIKey {
+	value, exists := ctx.Get(apiKeyContextKey)
+	if !exists {
+		return nil
+	}
+
+	apiKey, _ := value.(*model.APIKey)
+	return apiKey
+}
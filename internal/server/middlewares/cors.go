@@ -14,8 +14,8 @@ func CORS() gin.HandlerFunc {
 		defer middlewareRecovery()
 
 		ctx.Writer.Header().Set("Access-Control-Allow-Origin", Origin)
-		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Auth-Access-Token, Auth-Refresh-Token")
-		ctx.Writer.Header().Set("Access-Control-Expose-Headers", " Auth-Access-Token, Auth-Refresh-Token")
+		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Auth-Access-Token, Auth-Refresh-Token, X-Api-Key, X-Request-ID")
+		ctx.Writer.Header().Set("Access-Control-Expose-Headers", " Auth-Access-Token, Auth-Refresh-Token, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, X-Request-ID")
 		ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		ctx.Next()
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"bookbox-backend/internal/metrics"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records count and latency of every request, labeled with the registered route
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer middlewareRecovery()
+
+		start := time.Now()
+		ctx.Next()
+
+		metrics.ObserveHTTP(
+			ctx.FullPath(),
+			ctx.GetString(metrics.EntityKey),
+			ctx.Request.Method,
+			ctx.Writer.Status(),
+			start,
+		)
+	}
+}
@@ -0,0 +1,288 @@
+package middlewares
+
+import (
+	"bookbox-backend/internal/config"
+	"bookbox-backend/pkg/logger"
+	"bookbox-backend/pkg/redis"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	RateLimitByIP      = "ip"
+	RateLimitByAccount = "account"
+	RateLimitByAPIKey  = "apikey"
+
+	rateLimitKeyPrefix = "ratelimit:"
+)
+
+type RateLimitRule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// RateLimitResult describes the state of one counter after a request
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+}
+
+var (
+	// route groups by path prefix, the first match wins
+	rateLimitGroups = []struct {
+		prefix string
+		group  string
+	}{
+		{"/auth/", "auth"},
+		{"/payment/", "payment"},
+		{"/apikey/", "admin"},
+		{"/create", "crud"},
+		{"/read", "crud"},
+		{"/list", "crud"},
+		{"/update", "crud"},
+		{"/delete", "crud"},
+	}
+
+	// RateLimits per group and key kind, overridden by RATE_LIMIT_<GROUP>="ip=20/1m,account=60/1m"
+	RateLimits = map[string]map[string]RateLimitRule{
+		"auth": {
+			RateLimitByIP: {Limit: 20, Window: time.Minute},
+		},
+		"crud": {
+			RateLimitByIP:      {Limit: 300, Window: time.Minute},
+			RateLimitByAccount: {Limit: 600, Window: time.Minute},
+			RateLimitByAPIKey:  {Limit: 3000, Window: time.Minute},
+		},
+		"default": {
+			RateLimitByIP: {Limit: 120, Window: time.Minute},
+		},
+	}
+)
+
+// setRateLimits replaces the built-in limits of the configured groups
+func setRateLimits(settings config.RateLimitConfig) error {
+	for group, raw := range settings.Groups() {
+		if raw == "" {
+			continue
+		}
+
+		rules, err := parseRateLimitRules(raw)
+		if err != nil {
+			return fmt.Errorf("RATE_LIMIT_%s (%q): %w", strings.ToUpper(group), raw, err)
+		}
+
+		RateLimits[group] = rules
+	}
+
+	return nil
+}
+
+// parseRateLimitRules parses "ip=20/1m,account=60/1m", "off" disables the group
+func parseRateLimitRules(raw string) (rules map[string]RateLimitRule, err error) {
+	rules = make(map[string]RateLimitRule)
+	if raw == "off" {
+		return
+	}
+
+	for _, part := range strings.Split(raw, ",") {
+		kindAndRule := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kindAndRule) != 2 {
+			err = fmt.Errorf("expected kind=limit/window, got %q", part)
+			return
+		}
+
+		limitAndWindow := strings.SplitN(kindAndRule[1], "/", 2)
+		if len(limitAndWindow) != 2 {
+			err = fmt.Errorf("expected limit/window, got %q", kindAndRule[1])
+			return
+		}
+
+		var rule RateLimitRule
+		rule.Limit, err = strconv.Atoi(limitAndWindow[0])
+		if err != nil {
+			return
+		}
+
+		rule.Window, err = time.ParseDuration(limitAndWindow[1])
+		if err != nil {
+			return
+		}
+
+		if rule.Limit <= 0 || rule.Window <= 0 {
+			err = fmt.Errorf("limit and window must be positive in %q", part)
+			return
+		}
+
+		rules[kindAndRule[0]] = rule
+	}
+
+	return
+}
+
+func rateLimitGroup(path string) string {
+	for _, g := range rateLimitGroups {
+		if strings.HasPrefix(path, g.prefix) {
+			return g.group
+		}
+	}
+
+	return "default"
+}
+
+// RateLimit counts requests per IP, account and API key in fixed Redis windows
+func RateLimit() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer middlewareRecovery()
+
+		path := ctx.FullPath()
+		if path == "" {
+			path = ctx.Request.URL.Path
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+
+		group := rateLimitGroup(path)
+		rules := RateLimits[group]
+		if len(rules) == 0 {
+			ctx.Next()
+			return
+		}
+
+		identities := map[string]string{
+			RateLimitByIP: ctx.ClientIP(),
+		}
+
+		if apiKey := GetAPIKey(ctx); apiKey != nil {
+			identities[RateLimitByAPIKey] = apiKey.ID
+		}
+
+		if accountID := rateLimitAccount(ctx); accountID != "" {
+			identities[RateLimitByAccount] = accountID
+		}
+
+		var strictest *RateLimitResult
+		for kind, rule := range rules {
+			id, ok := identities[kind]
+			if !ok {
+				continue
+			}
+
+			result, err := Limit(fmt.Sprintf("%s:%s:%s", group, kind, id), rule)
+			if err != nil {
+				// fail open, an unavailable Redis shouldn't take the API down
+				logger.Log.Warn("rate limit check failed",
+					zap.String("group", group),
+					zap.String("kind", kind),
+					zap.Error(err),
+				)
+				continue
+			}
+
+			if strictest == nil || !result.Allowed || (strictest.Allowed && result.Remaining < strictest.Remaining) {
+				strictest = &result
+			}
+
+			if !result.Allowed {
+				break
+			}
+		}
+
+		if strictest == nil {
+			ctx.Next()
+			return
+		}
+
+		SetRateLimitHeaders(ctx, *strictest)
+		if !strictest.Allowed {
+			ctx.AbortWithStatusJSON(429, gin.H{
+				"status": false,
+				"errors": []string{"too many requests"},
+			})
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// rateLimitScript increments the counter and sets the expiry of a new one in one step, a lost
+// EXPIRE can't leave a counter which never expires
+const rateLimitScript = `
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`
+
+// Limit increments the counter of key in the current window of rule
+func Limit(key string, rule RateLimitRule) (result RateLimitResult, err error) {
+	now := time.Now()
+	windowStart := now.Truncate(rule.Window)
+	redisKey := fmt.Sprintf("%s%s:%d", rateLimitKeyPrefix, key, windowStart.Unix())
+
+	value, err := redis.Client.Eval(rateLimitScript, []string{redisKey}, rule.Window.Milliseconds()).Result()
+	if err != nil {
+		return
+	}
+
+	count, ok := value.(int64)
+	if !ok {
+		err = fmt.Errorf("unexpected rate limit count %v", value)
+		return
+	}
+
+	result = RateLimitResult{
+		Allowed:   count <= int64(rule.Limit),
+		Limit:     rule.Limit,
+		Remaining: rule.Limit - int(count),
+		Reset:     windowStart.Add(rule.Window).Sub(now),
+	}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+
+	return
+}
+
+// SetRateLimitHeaders writes the RateLimit-* headers and Retry-After when blocked
+func SetRateLimitHeaders(ctx *gin.Context, result RateLimitResult) {
+	reset := int(result.Reset.Seconds() + 0.5)
+	if reset < 1 {
+		reset = 1
+	}
+
+	ctx.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	ctx.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	ctx.Header("RateLimit-Reset", strconv.Itoa(reset))
+
+	if !result.Allowed {
+		ctx.Header("Retry-After", strconv.Itoa(reset))
+	}
+}
+
+// rateLimitAccount returns the user of a correctly signed access token, the session itself
+// is still validated by the handler
+func rateLimitAccount(ctx *gin.Context) string {
+	token := ctx.GetHeader("Auth-Access-Token")
+	if token == "" {
+		return ""
+	}
+
+	claims := jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, &claims, config.GetJWT().Access.Keyfunc)
+	if err != nil {
+		return ""
+	}
+
+	return claims.Issuer
+}
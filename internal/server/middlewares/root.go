@@ -3,30 +3,36 @@ package middlewares
 import (
 	"fmt"
 	"net"
-	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 
+	"bookbox-backend/internal/config"
 	"bookbox-backend/pkg/logger"
 
 	"go.uber.org/zap"
 )
 
 var (
-	environment  = os.Getenv("ENVIRONMENT")
-	serverDomain = os.Getenv("SERVER_DOMAIN")
+	environment  string
+	serverDomain string
+
+	// liveConfig provides AUTH_KEY, which is read on every request so a rotated key is
+	// accepted without a restart
+	liveConfig *config.Live
 )
 
-func init() {
-	environment = strings.ToLower(environment)
-	if environment == "" {
-		environment = "development"
-	}
+// Setup applies the configuration, the middlewares are installed before it is loaded and
+// only used once the server is started
+func Setup(live *config.Live) (err error) {
+	cfg := live.Get()
+	environment = cfg.Environment
+	serverDomain = cfg.Server.Domain
 
-	if serverDomain == "" {
-		serverDomain = "localhost"
-	}
+	liveConfig = live
+	apiKeyRequired = cfg.API.Required
+
+	return setRateLimits(cfg.RateLimit)
 }
 
 func SetOrigin(ip net.IP, port int) {
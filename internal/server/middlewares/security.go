@@ -1,8 +1,6 @@
 package middlewares
 
 import (
-	"fmt"
-
 	"github.com/gin-gonic/gin"
 )
 
@@ -20,19 +18,3 @@ func Security() gin.HandlerFunc {
 		ctx.Next()
 	}
 }
-
-var AuthKey = ""
-
-func CheckAuthKey() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		defer middlewareRecovery()
-
-		authKey := ctx.GetHeader("x-api-key")
-		if authKey != AuthKey {
-			ctx.AbortWithError(400, fmt.Errorf("api key provided is incorrect"))
-			return
-		}
-
-		ctx.Next()
-	}
-}
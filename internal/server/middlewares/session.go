@@ -1,16 +1,18 @@
 package middlewares
 
 import (
-	"fmt"
-	"time"
+	"bookbox-backend/internal/requestid"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Session assigns the request ID, taken from X-Request-ID or generated, and echoes it to the client
 func Session() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		id := fmt.Sprintf("%v", time.Now().UnixNano())
-		ctx.Set("id", id)
+		id := requestid.Accept(ctx.GetHeader(requestid.Header))
+		ctx.Set(requestid.ContextKey, id)
+		ctx.Request = ctx.Request.WithContext(requestid.WithID(ctx.Request.Context(), id))
+		ctx.Header(requestid.Header, id)
 
 		// default status
 		ctx.Status(200)
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/tracing"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// untracedPaths are polled often and would only add noise
+var untracedPaths = map[string]bool{
+	"/metrics": true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// Tracing opens a server span for every request and tags it with the request ID
+func Tracing() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		otelgin.Middleware(tracing.ServiceName,
+			otelgin.WithFilter(func(req *http.Request) bool {
+				return !untracedPaths[req.URL.Path]
+			}),
+		),
+		func(ctx *gin.Context) {
+			trace.SpanFromContext(ctx.Request.Context()).SetAttributes(
+				attribute.String("request.id", ctx.GetString(requestid.ContextKey)),
+			)
+
+			ctx.Next()
+		},
+	}
+}
@@ -1,25 +1,33 @@
 package processor
 
 import (
+	"bookbox-backend/internal/config"
 	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/orderaccess"
+	"bookbox-backend/internal/route/auth"
 	"bookbox-backend/internal/server/sendgrid"
+	"context"
 	"fmt"
-	"os"
 
 	"go.uber.org/zap"
 )
 
 var (
 	senderEmail, orderTemplateId, failedOrderTemplateId string
+
+	// page where guests look up their order with the access token
+	orderStatusURL string
 )
 
-func init() {
-	senderEmail = os.Getenv("SENDGRID_SENDER_EMAIL")
-	orderTemplateId = os.Getenv("SENDGRID_ORDER_TEMPLATE_ID")
-	failedOrderTemplateId = os.Getenv("SENDGRID_FAILED_TEMPLATE_ID")
+// Setup applies the order and SendGrid settings of the configuration
+func Setup(cfg *config.Config) {
+	orderStatusURL = cfg.Orders.StatusURL
+	senderEmail = cfg.SendGrid.SenderEmail
+	orderTemplateId = cfg.SendGrid.OrderTemplateID
+	failedOrderTemplateId = cfg.SendGrid.FailedTemplateID
 }
 
-func SendOrderNotification(order *model.OrderNotification, log *zap.Logger) (err error) {
+func SendOrderNotification(ctx context.Context, order *model.OrderNotification, log *zap.Logger) (err error) {
 	var (
 		notify sendgrid.SendGrid
 	)
@@ -41,13 +49,14 @@ func SendOrderNotification(order *model.OrderNotification, log *zap.Logger) (err
 		"order_id":    order.Details.ID,
 		"order":       orderDetails,
 		"total_price": fmt.Sprintf("%.2f€", order.Details.TotalPrice),
+		"status_url":  statusURL(order.Details.ID, log),
 	}
 
 	notify.From = senderEmail
 	notify.To = order.Details.Email
 	notify.TemplateID = orderTemplateId
 
-	err = notify.SendEmail()
+	err = notify.SendEmail(ctx)
 	if err != nil {
 		log.Error("failed to send email",
 			zap.Error(err),
@@ -58,19 +67,20 @@ func SendOrderNotification(order *model.OrderNotification, log *zap.Logger) (err
 	return
 }
 
-func SendOrderFailedNotification(order *model.OrderNotification, log *zap.Logger) (err error) {
+func SendOrderFailedNotification(ctx context.Context, order *model.OrderNotification, log *zap.Logger) (err error) {
 	var (
 		notify sendgrid.SendGrid
 	)
 
 	notify.DynamicTemplateData = map[string]interface{}{
-		"order_id": order.Details.ID,
+		"order_id":   order.Details.ID,
+		"status_url": statusURL(order.Details.ID, log),
 	}
 	notify.From = senderEmail
 	notify.To = order.Details.Email
 	notify.TemplateID = failedOrderTemplateId
 
-	err = notify.SendEmail()
+	err = notify.SendEmail(ctx)
 	if err != nil {
 		log.Error("failed to send email",
 			zap.Error(err),
@@ -80,3 +90,27 @@ func SendOrderFailedNotification(order *model.OrderNotification, log *zap.Logger
 
 	return
 }
+
+// statusURL links to the order status page with a signed access token, so guests can
+// follow their order without an account
+func statusURL(orderID string, log *zap.Logger) string {
+	token, err := orderaccess.NewToken(orderID)
+	if err != nil {
+		log.Warn("failed to create order access token",
+			zap.String("orderId", orderID),
+			zap.Error(err),
+		)
+		return ""
+	}
+
+	link, err := auth.TokenLink(orderStatusURL, token)
+	if err != nil {
+		log.Warn("failed to build order status link",
+			zap.String("orderId", orderID),
+			zap.Error(err),
+		)
+		return ""
+	}
+
+	return link
+}
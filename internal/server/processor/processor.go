@@ -2,28 +2,70 @@ package processor
 
 import (
 	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/metrics"
 	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/tracing"
 	"bookbox-backend/pkg/ebooks"
+	"context"
 	"os"
 	"path/filepath"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
-func Process(log *zap.Logger) {
+// notificationTimeout bounds a notification of the processor, the shutdown doesn't cancel it
+// so an order which was started is finished
+const notificationTimeout = 30 * time.Second
+
+// QueueDepth returns the number of orders waiting for ebooks and in the dead letter queue,
+// a queue directory which doesn't exist yet counts as empty
+func QueueDepth() (pending int, deadLetter int, err error) {
+	pending, err = countFiles(ebooks.QueuePath)
+	if err != nil {
+		return
+	}
+
+	deadLetter, err = countFiles(ebooks.DeadLetterQueuePath)
+	return
+}
+
+func countFiles(dir string) (int, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	return len(files), err
+}
+
+// Process works through the ebook order queue until ctx is cancelled, an order which was started
+// is finished first, the queue files are the checkpoint of the orders not processed yet
+func Process(ctx context.Context, log *zap.Logger) {
 	log.Info("ebooks order processor started")
 
 	for {
-		time.Sleep(time.Second * 5)
+		select {
+		case <-ctx.Done():
+			log.Info("ebooks order processor stopped")
+			return
+		case <-time.After(time.Second * 5):
+		}
 
 		files, err := os.ReadDir(ebooks.QueuePath)
 		if err != nil {
 			continue
 		}
 
+		metrics.QueueDepth.WithLabelValues("ebooks").Set(float64(len(files)))
+		if deadLetters, err := countFiles(ebooks.DeadLetterQueuePath); err == nil {
+			metrics.QueueDepth.WithLabelValues("dead_letter").Set(float64(deadLetters))
+		}
+
 		// process the orders
-		for i := 0; i < len(files); i++ {
+		for i := 0; i < len(files) && ctx.Err() == nil; i++ {
 			filePath := filepath.Join(ebooks.QueuePath, files[i].Name())
 			order, err := ebooks.ReadOrder(filePath)
 			if err != nil {
@@ -56,7 +98,7 @@ func Process(log *zap.Logger) {
 				)
 
 				// notify the user about the failed order notification
-				err = SendOrderFailedNotification(&order, log)
+				err = sendNotification(ctx, "processor.SendOrderFailedNotification", SendOrderFailedNotification, &order, log)
 				if err != nil {
 					log.Error("send failed order notification failed",
 						zap.String("id", order.Details.ID),
@@ -86,7 +128,7 @@ func Process(log *zap.Logger) {
 				zap.Any("items", order.Details.Products),
 			)
 
-			err = SendOrderNotification(&order, log)
+			err = sendNotification(ctx, "processor.SendOrderNotification", SendOrderNotification, &order, log)
 			if err != nil {
 				log.Error("send order notification failed",
 					zap.String("id", order.Details.ID),
@@ -113,10 +155,29 @@ func Process(log *zap.Logger) {
 	}
 }
 
-func ProcessOrder(id string, log *zap.Logger) (err error) {
+// sendNotification sends the notification of a queued order in its own span
+func sendNotification(ctx context.Context, name string, send func(context.Context, *model.OrderNotification, *zap.Logger) error,
+	order *model.OrderNotification, log *zap.Logger) (err error) {
+	ctx, cancel := context.WithTimeout(requestid.Detach(ctx), notificationTimeout)
+	defer cancel()
+
+	ctx, span := tracing.Start(ctx, name, attribute.String("order.id", order.Details.ID))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	return send(ctx, order, log)
+}
+
+func ProcessOrder(ctx context.Context, id string, log *zap.Logger) (err error) {
+	ctx, span := tracing.Start(ctx, "processor.ProcessOrder")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	order := model.Order{}
 
-	res := database.DB.Preload("Products.Product").Preload("User").Where("id = ?", id).First(&order)
+	res := database.DB.WithContext(ctx).Preload("Products.Product").Preload("User").Where("id = ?", id).First(&order)
 	if res.Error != nil {
 		errMsg := "failed to read order in postrun"
 		log.Error(errMsg,
@@ -145,13 +206,15 @@ func ProcessOrder(id string, log *zap.Logger) (err error) {
 
 	if len(eBookIds) != 0 {
 		// create eBook order with all id's at once
+		_, ebooksSpan := tracing.Start(ctx, "ebooks.CreateOrder")
 		saveOrder.Details.EBookOrderID, err = ebooks.CreateOrder(eBookIds)
+		tracing.End(ebooksSpan, err)
 		if err != nil {
 			log.Info("sending failed order notification",
 				zap.Any("items", saveOrder.Details.Products),
 			)
 
-			err = SendOrderFailedNotification(&saveOrder, log)
+			err = SendOrderFailedNotification(ctx, &saveOrder, log)
 			if err != nil {
 				log.Error("send failed order notification failed",
 					zap.String("id", saveOrder.Details.ID),
@@ -1,15 +1,5 @@
 package processor
 
-import (
-	"bookbox-backend/pkg/logger"
-)
-
 const (
 	DefaultRetryCount = 25
 )
-
-func init() {
-	log := logger.Log
-
-	go Process(log)
-}
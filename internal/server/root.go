@@ -1,28 +1,42 @@
 package server
 
 import (
-	"os"
-	"strconv"
+	"context"
+	"errors"
+	stdsync "sync"
+	"time"
 
 	"net"
 	"net/http"
 
-	_ "bookbox-backend/internal/config"
-	_ "bookbox-backend/internal/database"
-	_ "bookbox-backend/internal/route/auth"
+	"bookbox-backend/internal/config"
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/execute/postrun"
+	"bookbox-backend/internal/execute/prerun"
+	"bookbox-backend/internal/orderaccess"
+	"bookbox-backend/internal/passhash"
+	_ "bookbox-backend/internal/route/apikey"
+	"bookbox-backend/internal/route/auth"
 	_ "bookbox-backend/internal/route/crud"
 	_ "bookbox-backend/internal/route/fail"
+	"bookbox-backend/internal/route/monitoring"
+	_ "bookbox-backend/internal/route/order"
 	_ "bookbox-backend/internal/route/payment"
+	_ "bookbox-backend/internal/route/privacy"
 	_ "bookbox-backend/internal/route/subshop"
-	_ "bookbox-backend/internal/server/processor"
+	_ "bookbox-backend/internal/route/syncrun"
+	"bookbox-backend/internal/server/processor"
+	"bookbox-backend/internal/server/sendgrid"
 	"bookbox-backend/internal/sync"
 	_ "bookbox-backend/pkg/ebooks"
 
 	_ "bookbox-backend/pkg/redis"
 
+	"bookbox-backend/internal/lifecycle"
 	"bookbox-backend/internal/server/ca"
 	"bookbox-backend/internal/server/middlewares"
 	"bookbox-backend/internal/server/router"
+	"bookbox-backend/internal/tracing"
 	"bookbox-backend/pkg/logger"
 
 	"github.com/gin-gonic/gin"
@@ -33,26 +47,49 @@ var (
 	ip         = net.IPv4(127, 0, 0, 1)
 	port       = 4443
 	httpServer *http.Server
+
+	httpServerMutex stdsync.Mutex
+
+	// shutdownTimeout bounds draining requests and stopping the workers
+	shutdownTimeout time.Duration
 )
 
-func init() {
-	serverPort := os.Getenv("SERVER_PORT")
-	if serverPort != "" {
-		port, _ = strconv.Atoi(serverPort)
+// Start loads the configuration, sets up the packages with it and serves until a stop signal
+func Start() {
+	cfg, err := config.Load()
+	if err != nil {
+		problems := []string{err.Error()}
+		if configErr, ok := err.(*config.Error); ok {
+			problems = configErr.Problems
+		}
+
+		logger.Log.Fatal("invalid configuration",
+			zap.Strings("problems", problems),
+		)
 	}
 
-	serverIP := os.Getenv("SERVER_IP")
-	if serverIP != "" {
-		parsedIP, _, err := net.ParseCIDR(serverIP + "/32")
-		if err == nil {
-			ip = parsedIP
-		}
+	logger.Log.Info("configuration loaded",
+		zap.Any("config", cfg.Dump()),
+	)
+
+	// without keys no token could be issued or checked
+	err = config.ReloadJWT(cfg.JWT)
+	if err != nil {
+		logger.Log.Fatal("loading jwt keys failed",
+			zap.Error(err),
+		)
 	}
 
+	live := config.NewLive(cfg)
+
+	shutdownTimeout = cfg.Server.ShutdownTimeout
+	port = cfg.Server.Port
+
+	// the address was validated on load
+	ip = net.ParseIP(cfg.Server.IP)
+
 	middlewares.SetOrigin(ip, port)
-}
 
-func Start() {
 	log := logger.Log.WithOptions(zap.Fields(
 		zap.String("ip", ip.String()),
 	))
@@ -62,6 +99,37 @@ func Start() {
 	// Sets default size only if not set
 	gin.SetMode(gin.ReleaseMode)
 
+	err = tracing.Setup(cfg.Tracing, logger.Log)
+	if err != nil {
+		log.Error("failed to set up tracing, continuing without",
+			zap.Error(err),
+		)
+	}
+
+	err = database.Setup(live)
+	if err != nil {
+		log.Fatal("failed to start database",
+			zap.Error(err),
+		)
+	}
+
+	err = middlewares.Setup(live)
+	if err != nil {
+		log.Fatal("invalid middleware configuration",
+			zap.Error(err),
+		)
+	}
+
+	passhash.Setup(cfg.Password)
+	orderaccess.Setup(live)
+	sendgrid.Setup(live)
+	prerun.Setup(cfg.Orders)
+	postrun.Setup(live)
+	processor.Setup(cfg)
+	auth.Setup(cfg)
+	monitoring.Setup(live)
+	sync.Setup(live)
+
 	// Auto generate self signed certificate and private key
 	ca.Setup(logger.Log)
 	certFile := ca.GetCertificate()
@@ -72,7 +140,7 @@ func Start() {
 	))
 
 	// Start HTTPS server
-	httpServer = Initialize(ip, port, router.Router)
+	setHTTPServer(Initialize(ip, port, router.Router))
 	go func() {
 		errorsStartingUp := 0
 		var err error
@@ -83,9 +151,15 @@ func Start() {
 				zap.Int("errorsStartingUp", errorsStartingUp),
 			)
 
-			httpServer = Initialize(ip, port, router.Router)
-			//err = httpServer.ListenAndServeTLS(certFile, keyFile)
-			err = httpServer.ListenAndServe()
+			server := Initialize(ip, port, router.Router)
+			setHTTPServer(server)
+			//err = server.ListenAndServeTLS(certFile, keyFile)
+			err = server.ListenAndServe()
+			if errors.Is(err, http.ErrServerClosed) {
+				// shutdown started
+				return
+			}
+
 			if err != nil {
 				log.Info("retrying to start HTTP server, because of an error",
 					zap.Int("port", port),
@@ -107,6 +181,27 @@ func Start() {
 		)
 	}()
 
-	go sync.Worker()
-	Wait(httpServer, log)
+	manager := lifecycle.New(logger.Log)
+	manager.Go("secrets", live.WatchSecrets)
+	manager.Go("sync", sync.Worker)
+	manager.Go("ebook processor", func(ctx context.Context) {
+		processor.Process(ctx, logger.Log)
+	})
+
+	Wait(getHTTPServer, manager, live, log)
+}
+
+// setHTTPServer keeps the server which is currently listening, a failed start retries on the next port
+func setHTTPServer(server *http.Server) {
+	httpServerMutex.Lock()
+	defer httpServerMutex.Unlock()
+
+	httpServer = server
+}
+
+func getHTTPServer() *http.Server {
+	httpServerMutex.Lock()
+	defer httpServerMutex.Unlock()
+
+	return httpServer
 }
@@ -2,7 +2,6 @@ package router
 
 import (
 	"bookbox-backend/internal/server/middlewares"
-	"os"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,13 +11,20 @@ var (
 )
 
 func init() {
+	// gin contexts passed as context.Context resolve values like the current span from the request
+	Router.ContextWithFallback = true
+
 	Router.Use(middlewares.NoCache())
 	Router.Use(middlewares.Session())
+	Router.Use(middlewares.Metrics())
+	Router.Use(middlewares.Tracing()...)
 	Router.Use(middlewares.CORS())
 	Router.Use(middlewares.Security())
 
-	middlewares.AuthKey = os.Getenv("AUTH_KEY")
-	if middlewares.AuthKey != "" {
-		Router.Use(middlewares.CheckAuthKey())
-	}
+	// checks keys only with AUTH_KEY or API_KEY_REQUIRED set, the middleware is installed before
+	// the configuration is loaded because the routes are registered on import
+	Router.Use(middlewares.CheckAuthKey())
+
+	// after the api key check, so limits can be applied per key
+	Router.Use(middlewares.RateLimit())
 }
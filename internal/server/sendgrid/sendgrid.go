@@ -1,19 +1,20 @@
 package sendgrid
 
 import (
+	"bookbox-backend/internal/config"
+	"bookbox-backend/internal/metrics"
+	"bookbox-backend/internal/requestid"
+	"bookbox-backend/internal/tracing"
 	"bookbox-backend/pkg/logger"
+	"context"
 	"fmt"
-	"os"
+	"time"
 
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 	"go.uber.org/zap"
 )
 
-var (
-	apiKey string
-)
-
 type SendGrid struct {
 	From                string                 `json:"from"`
 	To                  string                 `json:"to"`
@@ -21,11 +22,21 @@ type SendGrid struct {
 	TemplateID          string                 `json:"templateId"`
 }
 
+// liveConfig holds the SendGrid settings with the latest API key
+var liveConfig *config.Live
+
 func init() {
-	apiKey = os.Getenv("SENDGRID_API_KEY_DEV")
+	// mails are sent through the instrumented client, so they show up in traces
+	sendgrid.DefaultClient.HTTPClient = tracing.HTTPClient
 }
 
-func (data *SendGrid) SendEmail() (err error) {
+// Setup hands the configuration to the mails
+func Setup(live *config.Live) {
+	liveConfig = live
+}
+
+// SendEmail sends the template mail, the request ID of ctx is forwarded to SendGrid
+func (data *SendGrid) SendEmail(ctx context.Context) (err error) {
 	from := mail.NewEmail("Bookbox", data.From)
 	to := mail.NewEmail("Recipient Name", data.To)
 
@@ -46,8 +57,18 @@ func (data *SendGrid) SendEmail() (err error) {
 		zap.String("from", data.From),
 		zap.String("to", data.To),
 	)
-	client := sendgrid.NewSendClient(apiKey)
-	response, err := client.Send(message)
+	// the key is read on every mail, so a rotated SENDGRID_API_KEY_DEV is used right away
+	client := sendgrid.NewSendClient(liveConfig.Get().SendGrid.APIKey)
+	if id := requestid.FromContext(ctx); id != "" {
+		client.Headers[requestid.Header] = id
+	}
+	start := time.Now()
+	response, err := client.SendWithContext(ctx, message)
+	statusCode := 0
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	metrics.ObserveExternal("sendgrid", "mail_send", start, statusCode, err)
 	if err != nil {
 		return err
 	}
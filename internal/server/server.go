@@ -1,6 +1,9 @@
 package server
 
 import (
+	"bookbox-backend/internal/config"
+	"bookbox-backend/internal/lifecycle"
+	"bookbox-backend/internal/tracing"
 	"context"
 	"crypto/tls"
 	"fmt"
@@ -77,14 +80,17 @@ func Initialize(ip net.IP, port int, router *gin.Engine) (server *http.Server) {
 	return server
 }
 
-// Wait will block processes and wait for signal to stop/shut-down given server
-func Wait(httpServer *http.Server, logger *zap.Logger) {
+// Wait will block processes and wait for signal to stop/shut-down given server, then drains
+// the HTTP server and the background workers together within shutdownTimeout. A second exit
+// signal exits right away.
+func Wait(httpServer func() *http.Server, manager *lifecycle.Manager, live *config.Live, logger *zap.Logger) {
+	server := httpServer()
 	log := logger.WithOptions(zap.Fields(
-		zap.String("address", httpServer.Addr),
-		zap.Duration("readTimeout", httpServer.ReadTimeout),
-		zap.Duration("writeTimeout", httpServer.WriteTimeout),
-		zap.Duration("readHeaderTimeout", httpServer.ReadHeaderTimeout),
-		zap.Duration("idleTimeout", httpServer.IdleTimeout),
+		zap.String("address", server.Addr),
+		zap.Duration("readTimeout", server.ReadTimeout),
+		zap.Duration("writeTimeout", server.WriteTimeout),
+		zap.Duration("readHeaderTimeout", server.ReadHeaderTimeout),
+		zap.Duration("idleTimeout", server.IdleTimeout),
 	))
 	log.Info("waiting for HTTPS server signals")
 
@@ -96,23 +102,52 @@ func Wait(httpServer *http.Server, logger *zap.Logger) {
 		syscall.SIGQUIT,
 	)
 
-	exitChannel := make(chan string)
+	exitChannel := make(chan string, 1)
 	go func() {
+		// the first exit signal starts the shutdown, a second one exits right away
+		stopping := false
+		exit := func(name string) {
+			if stopping {
+				logger.Error("received a second exit signal, exiting without waiting for the shutdown",
+					zap.String("signal", name),
+				)
+				os.Exit(1)
+			}
+
+			stopping = true
+			exitChannel <- name
+		}
+
 		for {
 			s := <-signalChannel
 			switch s {
 			case syscall.SIGHUP:
-				logger.Warn("received SIGHUP")
+				logger.Warn("received SIGHUP, reloading jwt keys and secrets")
 				// exitChannel <- "SIGHUP"
+				if err := config.ReloadJWT(live.Get().JWT); err != nil {
+					logger.Error("failed to reload jwt keys, keeping the previous keys",
+						zap.Error(err),
+					)
+				}
+
+				changed, err := live.ReloadSecrets()
+				if err != nil {
+					logger.Error("failed to reload secrets, keeping the previous values",
+						zap.Error(err),
+					)
+				}
+				logger.Info("secrets reloaded",
+					zap.Strings("secrets", changed),
+				)
 			case syscall.SIGINT:
 				logger.Warn("received SIGINT")
-				exitChannel <- "SIGINT"
+				exit("SIGINT")
 			case syscall.SIGTERM:
 				logger.Warn("received SIGTERM")
-				exitChannel <- "SIGTERM"
+				exit("SIGTERM")
 			case syscall.SIGQUIT:
 				logger.Warn("received SIGQUIT")
-				exitChannel <- "SIGQUIT"
+				exit("SIGQUIT")
 			default:
 				logger.Warn("received SIGHUP")
 				// exitChannel <- "UNKNOWN"
@@ -121,12 +156,51 @@ func Wait(httpServer *http.Server, logger *zap.Logger) {
 	}()
 
 	exitSignal := <-exitChannel
-	log.Warn("attempting to stop HTTPS server", zap.String("exitSignal", exitSignal))
+	log = log.WithOptions(zap.Fields(
+		zap.String("exitSignal", exitSignal),
+		zap.Duration("shutdownTimeout", shutdownTimeout),
+	))
+	log.Warn("attempting to stop HTTPS server")
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
-	log.Warn("HTTPS server stopped successfully", zap.String("exitSignal", exitSignal))
-	httpServer.Shutdown(ctx)
-	os.Exit(0)
+	exitCode := 0
+
+	// cancel the background workers right away, they stop after their current step and keep a
+	// checkpoint while the HTTP server drains
+	workersStopped := make(chan error, 1)
+	go func() {
+		workersStopped <- manager.Shutdown(ctx)
+	}()
+
+	// stop accepting requests and wait for the running ones
+	err := httpServer().Shutdown(ctx)
+	if err != nil {
+		log.Error("failed to drain HTTPS server",
+			zap.Error(err),
+		)
+		exitCode = 1
+	}
+
+	err = <-workersStopped
+	if err != nil {
+		log.Error("failed to stop background workers",
+			zap.Error(err),
+		)
+		exitCode = 1
+	}
+
+	err = tracing.Shutdown(ctx)
+	if err != nil {
+		log.Error("failed to flush traces",
+			zap.Error(err),
+		)
+	}
+
+	if exitCode == 0 {
+		log.Warn("HTTPS server stopped successfully")
+	}
+
+	os.Exit(exitCode)
 }
@@ -4,12 +4,12 @@ import (
 	"bookbox-backend/internal/database"
 	"bookbox-backend/internal/model"
 	"bookbox-backend/pkg/logger"
+	"context"
 	"encoding/base64"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -19,11 +19,12 @@ const (
 	annotPath = "/Annot"
 )
 
-func loadAnnotFiles(syncData model.Sync, zipLocation, saveLocation string) (newestTime int64, err error) {
+func loadAnnotFiles(ctx context.Context, syncData model.Sync, zipLocation, saveLocation string, run *syncRun) (newestTime int64, err error) {
 	logger.Log.Info("started annot load files")
 
-	if !syncData.IsFullSynced {
-		syncData.LastAnnotSyncDate, err = loadAnnotsFull(zipLocation, saveLocation)
+	// annotations which were never synced, or reset by a full run, start with the full archives
+	if syncData.LastAnnotSyncDate == 0 {
+		syncData.LastAnnotSyncDate, err = loadAnnotsFull(ctx, zipLocation, saveLocation, run)
 		if err != nil {
 			logger.Log.Error("failed to load annots",
 				zap.Error(err),
@@ -35,7 +36,7 @@ func loadAnnotFiles(syncData model.Sync, zipLocation, saveLocation string) (newe
 
 	logger.Log.Info("started partial annot load files")
 
-	syncData.LastAnnotSyncDate, err = loadAnnotsPartial(zipLocation, saveLocation, syncData)
+	newestTime, err = loadAnnotsPartial(ctx, zipLocation, saveLocation, syncData, run)
 	if err != nil {
 		logger.Log.Error("failed to load partial annots",
 			zap.Error(err),
@@ -43,26 +44,30 @@ func loadAnnotFiles(syncData model.Sync, zipLocation, saveLocation string) (newe
 		return 0, err
 	}
 
-	return syncData.LastAnnotSyncDate, nil
+	// without new partial archives the date of the last one stays
+	if newestTime < syncData.LastAnnotSyncDate {
+		newestTime = syncData.LastAnnotSyncDate
+	}
+
+	return newestTime, nil
 }
 
-func loadAnnotsFull(zipLocation, saveLocation string) (newestTime int64, err error) {
+func loadAnnotsFull(ctx context.Context, zipLocation, saveLocation string, run *syncRun) (newestTime int64, err error) {
 	logger.Log.Info("started annots full sync")
-	// FTP server URL
-	ftpClient, err := ConnectToFtp(ftpUrl)
+	source, err := OpenCatalogSource()
 	if err != nil {
-		logger.Log.Error("failed to connect to ftp",
+		logger.Log.Error("failed to connect to catalog source",
 			zap.Error(err),
 		)
 		return
 	}
-	defer ftpClient.Close()
+	defer source.Close()
 
 	rootPath := annotPath
 
-	entries, err := ftpClient.ReadDir(rootPath)
+	entries, err := source.List(rootPath)
 	if err != nil {
-		logger.Log.Error("failed to list annot entries in ftp",
+		logger.Log.Error("failed to list annot entries in catalog source",
 			zap.Error(err),
 		)
 
@@ -76,14 +81,14 @@ func loadAnnotsFull(zipLocation, saveLocation string) (newestTime int64, err err
 		}
 
 		directoryPath := filepath.Join(rootPath, entry.Name())
-		entriesMonths, err := ftpClient.ReadDir(directoryPath)
+		entriesMonths, err := source.List(directoryPath)
 		if err != nil {
 			return 0, err
 		}
 
 		for _, entriesMonth := range entriesMonths {
 			zipPath := filepath.Join(directoryPath, entriesMonth.Name())
-			entriesAnnots, err := ftpClient.ReadDir(zipPath)
+			entriesAnnots, err := source.List(zipPath)
 			if err != nil {
 				return 0, err
 			}
@@ -106,12 +111,7 @@ func loadAnnotsFull(zipLocation, saveLocation string) (newestTime int64, err err
 					newestTime = t.Unix()
 				}
 
-				err = DownloadAndDecompress(ftpClient, annotPath, zipLocation, saveLocation)
-				if err != nil {
-					return 0, err
-				}
-
-				err = UploadAnnots(saveLocation)
+				err = loadAnnotArchive(ctx, source, annotPath, zipLocation, saveLocation, run)
 				if err != nil {
 					return 0, err
 				}
@@ -122,25 +122,24 @@ func loadAnnotsFull(zipLocation, saveLocation string) (newestTime int64, err err
 	return
 }
 
-func loadAnnotsPartial(zipLocation, saveLocation string, syncData model.Sync) (newestTime int64, err error) {
+func loadAnnotsPartial(ctx context.Context, zipLocation, saveLocation string, syncData model.Sync, run *syncRun) (newestTime int64, err error) {
 	logger.Log.Info("started annots partial sync",
 		zap.Int64("lastSyncDate", syncData.LastAnnotSyncDate),
 	)
 
-	// FTP server URL
-	ftpClient, err := ConnectToFtp(ftpUrl)
+	source, err := OpenCatalogSource()
 	if err != nil {
-		logger.Log.Error("failed to connect to ftp",
+		logger.Log.Error("failed to connect to catalog source",
 			zap.Error(err),
 		)
 		return
 	}
-	defer ftpClient.Close()
+	defer source.Close()
 
 	rootPath := annotPath
-	entries, err := ftpClient.ReadDir(rootPath)
+	entries, err := source.List(rootPath)
 	if err != nil {
-		logger.Log.Error("failed to list annot entries in ftp",
+		logger.Log.Error("failed to list annot entries in catalog source",
 			zap.Error(err),
 		)
 
@@ -168,12 +167,7 @@ func loadAnnotsPartial(zipLocation, saveLocation string, syncData model.Sync) (n
 		}
 
 		filePath := filepath.Join(rootPath, entry.Name())
-		err = DownloadAndDecompress(ftpClient, filePath, zipLocation, saveLocation)
-		if err != nil {
-			return 0, err
-		}
-
-		err = UploadAnnots(saveLocation)
+		err = loadAnnotArchive(ctx, source, filePath, zipLocation, saveLocation, run)
 		if err != nil {
 			return 0, err
 		}
@@ -182,12 +176,49 @@ func loadAnnotsPartial(zipLocation, saveLocation string, syncData model.Sync) (n
 	return
 }
 
-func UploadAnnots(saveLocation string) (err error) {
+// loadAnnotArchive downloads and writes one annotation archive, archives in the checkpoint are
+// skipped. The written archive is recorded in the sync run.
+func loadAnnotArchive(ctx context.Context, source CatalogSource, ftpLocation, zipLocation, saveLocation string, run *syncRun) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	info, err := source.Stat(ftpLocation)
+	if err != nil {
+		return
+	}
+
+	key := checkpointKey(annotPhase, archiveVersion(ftpLocation, info))
+	if run.progress.Done(key) {
+		logger.Log.Info("skipping annot archive, written before the sync was interrupted",
+			zap.String("ftpLocation", ftpLocation),
+		)
+		return
+	}
+
+	runFile := run.startFile(annotPhase, ftpLocation, filepath.Base(ftpLocation))
+	err = DownloadAndDecompress(ctx, source, ftpLocation, zipLocation, saveLocation)
+	if err != nil {
+		run.finishFile(runFile, model.SyncCounts{}, err)
+		return
+	}
+
+	counts, err := UploadAnnots(ctx, saveLocation)
+	run.finishFile(runFile, counts, err)
+	if err != nil {
+		return
+	}
+
+	return run.progress.Save(key)
+}
+
+// UploadAnnots writes the descriptions and covers of the extracted archive to the stored products
+func UploadAnnots(ctx context.Context, saveLocation string) (counts model.SyncCounts, err error) {
 	defer os.RemoveAll(saveLocation)
 
 	annotFiles, err := os.ReadDir(saveLocation)
 	if err != nil {
-		return err
+		return
 	}
 
 	var (
@@ -199,6 +230,10 @@ func UploadAnnots(saveLocation string) (err error) {
 	)
 
 	for _, annotFile := range annotFiles {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
 		count++
 		if count%1000 == 0 {
 			time.Sleep(time.Millisecond * 50)
@@ -218,7 +253,7 @@ func UploadAnnots(saveLocation string) (err error) {
 		case "ATX":
 			raw, err := os.ReadFile(filePath)
 			if err != nil {
-				return err
+				return counts, err
 			}
 
 			descCount++
@@ -230,7 +265,7 @@ func UploadAnnots(saveLocation string) (err error) {
 		case "COP":
 			raw, err := os.ReadFile(filePath)
 			if err != nil {
-				return err
+				return counts, err
 			}
 
 			product.CoverPicture = base64.StdEncoding.EncodeToString(raw)
@@ -249,17 +284,13 @@ func UploadAnnots(saveLocation string) (err error) {
 		zap.Int("descCount", descCount),
 	)
 
-	wg := sync.WaitGroup{}
-	wg.Add(2)
-	err = batchUpdate(productUpdate[0:len(productUpdate)/2], &wg)
-	if err != nil {
-		return err
-	}
+	counts.Skipped = skipped
 
-	err = batchUpdate(productUpdate[len(productUpdate)/2:], &wg)
+	counts.Failed, err = batchUpdate(ctx, productUpdate)
 	if err != nil {
-		return err
+		return
 	}
-	wg.Wait()
+
+	counts.Updated = len(productUpdate) - counts.Failed
 	return
 }
@@ -0,0 +1,242 @@
+package sync
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/metrics"
+	"bookbox-backend/internal/model"
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	onixNotificationDelete = "05"
+
+	withdrawnDeactivate = "deactivate"
+	withdrawnHide       = "hide"
+)
+
+// codes which mean the title will not come back, all other codes which stop the sale mean the
+// title is out of stock for now
+var (
+	// code list 64, PublishingStatus
+	unavailablePublishingStatus = map[string]string{
+		"01": "cancelled",
+		"05": "no longer our product",
+		"07": "out of print",
+		"11": "withdrawn from sale",
+		"15": "recalled",
+		"17": "permanently withdrawn from sale",
+	}
+
+	outOfStockPublishingStatus = map[string]bool{
+		"03": true, // postponed indefinitely
+		"06": true, // out of stock indefinitely
+		"08": true, // inactive
+		"16": true, // temporarily withdrawn from sale
+	}
+
+	// code list 54, AvailabilityCode of ONIX 2.1
+	unavailableAvailabilityCode = map[string]string{
+		"AB": "cancelled",
+		"EX": "no longer stocked",
+		"OP": "out of print",
+		"OR": "replaced by new edition",
+		"WS": "withdrawn from sale",
+	}
+
+	outOfStockAvailabilityCode = map[string]bool{
+		"OI": true, // out of stock indefinitely
+		"PP": true, // postponed indefinitely
+		"RP": true, // reprinting
+		"RU": true, // reprinting, undated
+		"TP": true, // temporarily out of stock, publisher cannot supply
+		"TU": true, // temporarily unavailable
+		"UR": true, // awaiting reissue
+	}
+
+	// code list 65, ProductAvailability
+	unavailableProductAvailability = map[string]string{
+		"01": "cancelled",
+		"40": "not available",
+		"41": "replaced by new product",
+		"42": "other format available",
+		"43": "no longer supplied",
+		"46": "withdrawn from sale",
+		"47": "remaindered",
+		"49": "recalled",
+		"51": "out of print",
+		"52": "not sold in this market",
+	}
+
+	outOfStockProductAvailability = map[string]bool{
+		"30": true, // temporarily unavailable
+		"31": true, // out of stock
+		"32": true, // reprinting
+		"33": true, // awaiting reissue
+		"34": true, // temporarily withdrawn from sale
+	}
+)
+
+// productAvailability reads the availability of the record from the notification type, the
+// publishing status and the supply details. A title is unavailable when every supplier says so.
+func productAvailability(input onixRecord) (availability string, reason string) {
+	if input.NotificationType == onixNotificationDelete {
+		reason = strings.TrimSpace("deleted " + input.DeletionCode + " " + input.DeletionText)
+		return model.ProductDeleted, reason
+	}
+
+	if reason, found := unavailablePublishingStatus[input.PublishingStatus]; found {
+		return model.ProductUnavailable, reason
+	}
+
+	availability = model.ProductAvailable
+	if outOfStockPublishingStatus[input.PublishingStatus] {
+		availability = model.ProductOutOfStock
+	}
+
+	unavailable := 0
+	for _, supply := range input.Supplies {
+		if r, found := unavailableAvailabilityCode[supply.AvailabilityCode]; found {
+			reason = r
+			unavailable++
+			continue
+		}
+
+		if r, found := unavailableProductAvailability[supply.ProductAvailability]; found {
+			reason = r
+			unavailable++
+			continue
+		}
+
+		if outOfStockAvailabilityCode[supply.AvailabilityCode] || outOfStockProductAvailability[supply.ProductAvailability] {
+			availability = model.ProductOutOfStock
+		}
+	}
+
+	if unavailable != 0 && unavailable == len(input.Supplies) {
+		return model.ProductUnavailable, reason
+	}
+
+	return availability, ""
+}
+
+// withdrawProducts deactivates the products or hides them in the sales channels, depending on
+// CATALOG_WITHDRAWN, and removes them from open carts. Favorites are only removed from deactivated
+// products, hidden ones keep them for when they are shown again. Only products which exist
+// are passed, a withdrawn title which was never imported is skipped. Products which fail are
+// logged and counted.
+func withdrawProducts(ctx context.Context, products []model.Product, log *zap.Logger) (failed int, err error) {
+	mode := liveConfig.Get().Catalog.Withdrawn
+
+	for i := range products {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			return withdrawProduct(tx, products[i], mode)
+		})
+		if err != nil {
+			log.Error("failed to withdraw product",
+				zap.String("productId", products[i].ID),
+				zap.Error(err),
+			)
+			metrics.SyncProducts.WithLabelValues("failed").Inc()
+			failed++
+			continue
+		}
+
+		log.Debug("withdrew product",
+			zap.String("productId", products[i].ID),
+			zap.String("availability", products[i].Availability),
+			zap.String("reason", products[i].UnavailableReason),
+		)
+		metrics.SyncProducts.WithLabelValues("withdrawn").Inc()
+	}
+
+	return failed, nil
+}
+
+func withdrawProduct(tx *gorm.DB, product model.Product, mode string) (err error) {
+	fields := map[string]any{
+		"stock":              0,
+		"availability":       product.Availability,
+		"unavailable_reason": product.UnavailableReason,
+	}
+	if mode == withdrawnDeactivate {
+		fields["active"] = false
+	}
+
+	err = tx.Model(&model.Product{}).Where("id = ?", product.ID).Updates(fields).Error
+	if err != nil {
+		return
+	}
+
+	if mode == withdrawnHide {
+		err = tx.Model(&model.SalesChannelProduct{}).
+			Where("product_id = ?", product.ID).
+			Updates(map[string]any{
+				"hidden":        true,
+				"hidden_reason": product.UnavailableReason,
+			}).Error
+		if err != nil {
+			return
+		}
+	}
+
+	err = tx.Where("product_id = ? AND cart_id IN (?)", product.ID,
+		tx.Model(&model.Cart{}).Select("id").Where("active = ?", true),
+	).Delete(&model.CartItem{}).Error
+	if err != nil {
+		return fmt.Errorf("cart items: %w", err)
+	}
+
+	// a hidden product is shown again when it comes back, so its favorites are kept
+	if mode == withdrawnHide {
+		return
+	}
+
+	err = tx.Where("product_id = ?", product.ID).Delete(&model.Favorite{}).Error
+	if err != nil {
+		return fmt.Errorf("favorites: %w", err)
+	}
+
+	return
+}
+
+// restoreAvailability writes the availability of a product which can be ordered. Zero values
+// like a stock of 0 are skipped by Updates, so they are written here. A product the sync
+// withdrew before is activated and shown again; products deactivated by an admin stay so.
+func restoreAvailability(tx *gorm.DB, product model.Product) (err error) {
+	// the annotation sync updates products as they are stored, withdrawn ones stay withdrawn
+	if product.IsWithdrawn() {
+		return nil
+	}
+
+	err = tx.Model(&model.Product{}).
+		Where("id = ? AND availability IN ?", product.ID, []string{model.ProductUnavailable, model.ProductDeleted}).
+		Update("active", true).Error
+	if err != nil {
+		return
+	}
+
+	err = tx.Model(&model.SalesChannelProduct{}).
+		Where("product_id = ? AND hidden AND hidden_reason <> ''", product.ID).
+		Updates(map[string]any{
+			"hidden":        false,
+			"hidden_reason": "",
+		}).Error
+	if err != nil {
+		return
+	}
+
+	return tx.Model(&model.Product{}).Where("id = ?", product.ID).Updates(map[string]any{
+		"stock":              product.Stock,
+		"availability":       product.Availability,
+		"unavailable_reason": "",
+	}).Error
+}
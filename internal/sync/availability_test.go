@@ -0,0 +1,143 @@
+package sync
+
+import (
+	"bookbox-backend/internal/model"
+	"testing"
+)
+
+func TestProductAvailability(t *testing.T) {
+	type test struct {
+		name             string
+		record           onixRecord
+		wantAvailability string
+		wantReason       string
+	}
+
+	supplies := func(supplies ...onixSupply) []onixSupply {
+		return supplies
+	}
+
+	tests := []test{
+		{
+			name:             "available",
+			record:           onixRecord{PublishingStatus: "04", Supplies: supplies(onixSupply{AvailabilityCode: "IP", ProductAvailability: "20"})},
+			wantAvailability: model.ProductAvailable,
+		},
+		{
+			name:             "no supply details",
+			record:           onixRecord{PublishingStatus: "04"},
+			wantAvailability: model.ProductAvailable,
+		},
+		{
+			name:             "deletion with code and text",
+			record:           onixRecord{NotificationType: onixNotificationDelete, DeletionCode: "01", DeletionText: "Dublette"},
+			wantAvailability: model.ProductDeleted,
+			wantReason:       "deleted 01 Dublette",
+		},
+		{
+			name:             "deletion wins over an available supply",
+			record:           onixRecord{NotificationType: onixNotificationDelete, PublishingStatus: "04", Supplies: supplies(onixSupply{ProductAvailability: "20"})},
+			wantAvailability: model.ProductDeleted,
+			wantReason:       "deleted",
+		},
+		{
+			name:             "publishing status wins over an available supply",
+			record:           onixRecord{PublishingStatus: "07", Supplies: supplies(onixSupply{AvailabilityCode: "IP", ProductAvailability: "20"})},
+			wantAvailability: model.ProductUnavailable,
+			wantReason:       "out of print",
+		},
+		{
+			name: "one of two suppliers unavailable",
+			record: onixRecord{PublishingStatus: "04", Supplies: supplies(
+				onixSupply{AvailabilityCode: "OP"},
+				onixSupply{AvailabilityCode: "IP", ProductAvailability: "20"},
+			)},
+			wantAvailability: model.ProductAvailable,
+		},
+		{
+			name: "one supplier unavailable, the other out of stock",
+			record: onixRecord{PublishingStatus: "04", Supplies: supplies(
+				onixSupply{ProductAvailability: "51"},
+				onixSupply{ProductAvailability: "31"},
+			)},
+			wantAvailability: model.ProductOutOfStock,
+		},
+		{
+			name: "every supplier unavailable",
+			record: onixRecord{PublishingStatus: "04", Supplies: supplies(
+				onixSupply{AvailabilityCode: "OP"},
+				onixSupply{ProductAvailability: "46"},
+			)},
+			wantAvailability: model.ProductUnavailable,
+			wantReason:       "withdrawn from sale",
+		},
+		{
+			name: "unknown codes are available",
+			record: onixRecord{PublishingStatus: "99", Supplies: supplies(
+				onixSupply{AvailabilityCode: "XX", ProductAvailability: "99"},
+			)},
+			wantAvailability: model.ProductAvailable,
+		},
+	}
+
+	// every code of the lists
+	for code, reason := range unavailablePublishingStatus {
+		tests = append(tests, test{
+			name:             "publishing status " + code,
+			record:           onixRecord{PublishingStatus: code},
+			wantAvailability: model.ProductUnavailable,
+			wantReason:       reason,
+		})
+	}
+
+	for code := range outOfStockPublishingStatus {
+		tests = append(tests, test{
+			name:             "publishing status " + code,
+			record:           onixRecord{PublishingStatus: code, Supplies: supplies(onixSupply{ProductAvailability: "20"})},
+			wantAvailability: model.ProductOutOfStock,
+		})
+	}
+
+	for code, reason := range unavailableAvailabilityCode {
+		tests = append(tests, test{
+			name:             "availability code " + code,
+			record:           onixRecord{PublishingStatus: "04", Supplies: supplies(onixSupply{AvailabilityCode: code})},
+			wantAvailability: model.ProductUnavailable,
+			wantReason:       reason,
+		})
+	}
+
+	for code := range outOfStockAvailabilityCode {
+		tests = append(tests, test{
+			name:             "availability code " + code,
+			record:           onixRecord{PublishingStatus: "04", Supplies: supplies(onixSupply{AvailabilityCode: code})},
+			wantAvailability: model.ProductOutOfStock,
+		})
+	}
+
+	for code, reason := range unavailableProductAvailability {
+		tests = append(tests, test{
+			name:             "product availability " + code,
+			record:           onixRecord{PublishingStatus: "04", Supplies: supplies(onixSupply{ProductAvailability: code})},
+			wantAvailability: model.ProductUnavailable,
+			wantReason:       reason,
+		})
+	}
+
+	for code := range outOfStockProductAvailability {
+		tests = append(tests, test{
+			name:             "product availability " + code,
+			record:           onixRecord{PublishingStatus: "04", Supplies: supplies(onixSupply{ProductAvailability: code})},
+			wantAvailability: model.ProductOutOfStock,
+		})
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			availability, reason := productAvailability(test.record)
+			if availability != test.wantAvailability || reason != test.wantReason {
+				t.Errorf("availability is %s (%q), want %s (%q)", availability, reason, test.wantAvailability, test.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+const (
+	onixPhase   = "onix"
+	onixDLPhase = "onix_dl"
+	annotPhase  = "annot"
+)
+
+// checkpoint tracks the files of the running syncs which are completely written, keys are
+// "<phase>:<archive>" or "<phase>:<archive>:<file>" with the archive named by archiveVersion, the
+// keys of a sync are cleared once it finished
+type checkpoint struct {
+	syncID string
+	keys   []string
+	done   map[string]bool
+}
+
+// checkpointKey names a written archive or a file in it. Files are scoped by their archive, the
+// archives of the partial syncs use the same file names.
+func checkpointKey(phase string, archive string, file ...string) string {
+	return strings.Join(append([]string{phase, archive}, file...), ":")
+}
+
+// archiveVersion names the archive by its path, size and modification time, so an archive which
+// is uploaded again under the same name while the sync is interrupted is written again
+func archiveVersion(location string, info fs.FileInfo) string {
+	return fmt.Sprintf("%s@%d-%d", location, info.Size(), info.ModTime().Unix())
+}
+
+func loadCheckpoint(syncData model.Sync) *checkpoint {
+	c := &checkpoint{
+		syncID: syncData.ID,
+		keys:   syncData.Checkpoint,
+		done:   make(map[string]bool, len(syncData.Checkpoint)),
+	}
+
+	for _, key := range syncData.Checkpoint {
+		c.done[key] = true
+	}
+
+	return c
+}
+
+// Done reports whether the file was written by an earlier, interrupted run
+func (c *checkpoint) Done(key string) bool {
+	return c.done[key]
+}
+
+// Save marks the file as written
+func (c *checkpoint) Save(key string) error {
+	c.keys = append(c.keys, key)
+	c.done[key] = true
+
+	return c.persist()
+}
+
+// Clear removes the keys of the phases after their sync finished, the ONIX and annotation
+// syncs share the checkpoint
+func (c *checkpoint) Clear(phases ...string) error {
+	keys := make([]string, 0, len(c.keys))
+	for _, key := range c.keys {
+		if inPhases(key, phases) {
+			delete(c.done, key)
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+	c.keys = keys
+
+	return c.persist()
+}
+
+// persist writes only the checkpoint column, updated_at stays the time of the last finished sync
+func (c *checkpoint) persist() error {
+	return database.DB.
+		Model(&model.Sync{Root: model.Root{ID: c.syncID}}).
+		Select("checkpoint").
+		UpdateColumns(model.Sync{Checkpoint: c.keys}).
+		Error
+}
+
+func inPhases(key string, phases []string) bool {
+	for _, phase := range phases {
+		if strings.HasPrefix(key, phase+":") {
+			return true
+		}
+	}
+
+	return false
+}
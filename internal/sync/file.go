@@ -1,46 +1,250 @@
 package sync
 
 import (
+	"archive/zip"
+	"context"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
+)
 
-	"github.com/jlaffaye/ftp"
+const (
+	// limits of a catalog archive, the full ONIX export is the largest with a few GB
+	maxArchiveEntries   = 200000
+	maxArchiveEntrySize = 4 << 30
+	maxArchiveSize      = 32 << 30
 )
 
-func RetrieveAndSave(ftpClient *ftp.ServerConn, inputPath, outputPath string) (err error) {
-	res, err := ftpClient.Retr(inputPath)
+// archive is an opened zip file of the catalog source
+type archive struct {
+	*zip.Reader
+	close func() error
+
+	// info is the entry of the archive in the source
+	info fs.FileInfo
+}
+
+func (a *archive) Close() error {
+	return a.close()
+}
+
+// ctxReader fails the copy once ctx is cancelled, so a shutdown doesn't wait for a download or
+// an extraction of a few GB
+type ctxReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.reader.Read(p)
+}
+
+// openArchive reads the zip from the source. Sources which support random access, like SFTP and
+// the local directory, are read in place; the others are downloaded to a temporary file in tmpDir.
+func openArchive(ctx context.Context, source CatalogSource, location string, tmpDir string) (opened *archive, err error) {
+	info, err := source.Stat(location)
 	if err != nil {
-		return err
+		return
 	}
-	defer res.Close()
 
-	dirPath := filepath.Dir(outputPath)
-	os.MkdirAll(dirPath, 0744)
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", location)
+	}
 
-	file, err := os.Create(outputPath)
+	res, err := source.Open(location)
 	if err != nil {
-		return err
+		return
 	}
-	defer file.Close()
 
-	_, err = io.Copy(file, res)
+	if readerAt, ok := res.(io.ReaderAt); ok {
+		var reader *zip.Reader
+		reader, err = zip.NewReader(readerAt, info.Size())
+		if err != nil {
+			res.Close()
+			return
+		}
+
+		opened = &archive{Reader: reader, close: res.Close}
+	} else {
+		opened, err = downloadArchive(ctx, res, tmpDir)
+		res.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	err = checkArchive(opened.Reader)
 	if err != nil {
-		return err
+		opened.Close()
+		return nil, fmt.Errorf("%s: %w", location, err)
 	}
 
+	opened.info = info
 	return
 }
 
-func DecompressToDisk(zipLocation string, resultLocation string) error {
-	// Open the zip file
-	cmd := exec.Command("unzip", "-o", zipLocation, "-d", resultLocation)
-	err := cmd.Run()
+// downloadArchive copies the zip to a temporary file, which is removed on Close
+func downloadArchive(ctx context.Context, res io.Reader, tmpDir string) (opened *archive, err error) {
+	err = os.MkdirAll(tmpDir, 0744)
+	if err != nil {
+		return
+	}
 
+	file, err := os.CreateTemp(tmpDir, "archive-*.zip")
 	if err != nil {
-		return err
+		return
+	}
+
+	cleanup := func() error {
+		file.Close()
+		return os.Remove(file.Name())
+	}
+
+	size, err := io.Copy(file, io.LimitReader(ctxReader{ctx, res}, maxArchiveSize+1))
+	if err == nil && size > maxArchiveSize {
+		err = fmt.Errorf("archive is larger than %d bytes", int64(maxArchiveSize))
+	}
+	if err != nil {
+		cleanup()
+		return
+	}
+
+	reader, err := zip.NewReader(file, size)
+	if err != nil {
+		cleanup()
+		return
+	}
+
+	return &archive{Reader: reader, close: cleanup}, nil
+}
+
+// checkArchive enforces the entry and size limits with the sizes declared in the archive,
+// openEntry makes sure no entry is larger than declared
+func checkArchive(reader *zip.Reader) error {
+	if len(reader.File) > maxArchiveEntries {
+		return fmt.Errorf("archive has %d entries, more than %d", len(reader.File), maxArchiveEntries)
+	}
+
+	var total uint64
+	for _, file := range reader.File {
+		if file.UncompressedSize64 > maxArchiveEntrySize {
+			return fmt.Errorf("entry %s has %d bytes, more than %d", file.Name, file.UncompressedSize64, int64(maxArchiveEntrySize))
+		}
+
+		total += file.UncompressedSize64
+		if total > maxArchiveSize {
+			return fmt.Errorf("archive has more than %d bytes uncompressed", int64(maxArchiveSize))
+		}
 	}
 
 	return nil
 }
+
+// isRegularEntry skips directories, symlinks and other special entries
+func isRegularEntry(file *zip.File) bool {
+	return file.Mode().IsRegular() && !strings.HasSuffix(file.Name, "/")
+}
+
+// openEntry returns the content of the entry, reading stops at its declared size
+func openEntry(file *zip.File) (io.ReadCloser, error) {
+	content, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(content, int64(file.UncompressedSize64)), content}, nil
+}
+
+// entryPath returns where the entry is written below dir, names which would leave dir
+// (zip slip) are rejected
+func entryPath(dir string, name string) (string, error) {
+	if name == "" || strings.Contains(name, "\\") || filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("invalid entry name %q", name)
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	relative, err := filepath.Rel(dir, target)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("entry %q leaves the target directory", name)
+	}
+
+	return target, nil
+}
+
+// extractArchive writes the regular files of the archive below dir
+func extractArchive(ctx context.Context, reader *zip.Reader, dir string) (err error) {
+	err = os.MkdirAll(dir, 0744)
+	if err != nil {
+		return
+	}
+
+	for _, file := range reader.File {
+		if !isRegularEntry(file) {
+			continue
+		}
+
+		var target string
+		target, err = entryPath(dir, file.Name)
+		if err != nil {
+			return
+		}
+
+		err = extractEntry(ctx, file, target)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+	}
+
+	return
+}
+
+func extractEntry(ctx context.Context, file *zip.File, target string) (err error) {
+	err = os.MkdirAll(filepath.Dir(target), 0744)
+	if err != nil {
+		return
+	}
+
+	content, err := openEntry(file)
+	if err != nil {
+		return
+	}
+	defer content.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return
+	}
+
+	_, err = io.Copy(out, ctxReader{ctx, content})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return
+}
+
+// DecompressToDisk extracts the zip file into resultLocation
+func DecompressToDisk(ctx context.Context, zipLocation string, resultLocation string) error {
+	reader, err := zip.OpenReader(zipLocation)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = checkArchive(&reader.Reader)
+	if err != nil {
+		return err
+	}
+
+	return extractArchive(ctx, &reader.Reader, resultLocation)
+}
@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// buildZip writes the entries to an in-memory zip. The declared sizes are stored as given
+// without content, so archives of a few GB can be described in a few bytes.
+func buildZip(t *testing.T, entries []zip.FileHeader) *zip.Reader {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+
+	for i := range entries {
+		_, err := writer.CreateRaw(&entries[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return reader
+}
+
+func TestEntryPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "extract")
+
+	tests := []struct {
+		name    string
+		entry   string
+		want    string
+		wantErr bool
+	}{
+		{name: "file", entry: "onix.xml", want: filepath.Join(dir, "onix.xml")},
+		{name: "nested file", entry: "Annot/2024/annot.xml", want: filepath.Join(dir, "Annot", "2024", "annot.xml")},
+		{name: "dot dot inside the directory", entry: "a/../b.xml", want: filepath.Join(dir, "b.xml")},
+		{name: "parent", entry: "../x", wantErr: true},
+		{name: "parent after a directory", entry: "a/../../x", wantErr: true},
+		{name: "parent only", entry: "..", wantErr: true},
+		{name: "absolute path", entry: "/etc/passwd", wantErr: true},
+		{name: "backslash", entry: `..\x`, wantErr: true},
+		{name: "backslash inside the directory", entry: `a\b.xml`, wantErr: true},
+		{name: "empty", entry: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := entryPath(dir, test.entry)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("%q is accepted as %s", test.entry, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("%q is rejected: %v", test.entry, err)
+			}
+
+			if got != test.want {
+				t.Errorf("%q is written to %s, want %s", test.entry, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckArchive(t *testing.T) {
+	tooMany := make([]zip.FileHeader, maxArchiveEntries+1)
+	for i := range tooMany {
+		tooMany[i] = zip.FileHeader{Name: strconv.Itoa(i)}
+	}
+
+	tests := []struct {
+		name    string
+		entries []zip.FileHeader
+		wantErr bool
+	}{
+		{
+			name:    "within the limits",
+			entries: []zip.FileHeader{{Name: "onix.xml", UncompressedSize64: maxArchiveEntrySize}},
+		},
+		{
+			name:    "oversize entry",
+			entries: []zip.FileHeader{{Name: "onix.xml", UncompressedSize64: maxArchiveEntrySize + 1}},
+			wantErr: true,
+		},
+		{
+			name: "oversize archive",
+			entries: func() []zip.FileHeader {
+				entries := make([]zip.FileHeader, maxArchiveSize/maxArchiveEntrySize+1)
+				for i := range entries {
+					entries[i] = zip.FileHeader{Name: strconv.Itoa(i), UncompressedSize64: maxArchiveEntrySize}
+				}
+				return entries
+			}(),
+			wantErr: true,
+		},
+		{
+			name:    "too many entries",
+			entries: tooMany,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkArchive(buildZip(t, test.entries))
+			if test.wantErr && err == nil {
+				t.Error("archive is accepted")
+			}
+
+			if !test.wantErr && err != nil {
+				t.Errorf("archive is rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractArchiveZipSlip(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "extract")
+
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, name := range []string{"onix.xml", "../evil.xml"} {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write([]byte("<ONIXmessage/>"))
+	}
+	writer.Close()
+
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = extractArchive(context.Background(), reader, dir)
+	if err == nil {
+		t.Fatal("archive with ../evil.xml is extracted")
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "evil.xml")); !os.IsNotExist(err) {
+		t.Errorf("entry was written outside the target directory: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package sync
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+const (
+	onixVersion21 = "2.1"
+	onixVersion30 = "3.0"
+)
+
+// onixFormat is the ONIX version and tag style of a file
+type onixFormat struct {
+	Version string
+
+	// Reference is set for reference tags (<Product>, <RecordReference>), otherwise the file
+	// uses short tags (<product>, <a001>)
+	Reference bool
+}
+
+// detectOnixFormat reads the format from the first element of the file, which is the
+// <ONIXMessage> or <ONIXmessage> root. The release attribute or the namespace tell ONIX 3 apart,
+// ONIX 2.1 files usually have neither. When the root is missing the first product is used.
+func detectOnixFormat(start xml.StartElement) onixFormat {
+	format := onixFormat{
+		Version:   onixVersion21,
+		Reference: start.Name.Local == "ONIXMessage" || start.Name.Local == "Product",
+	}
+
+	if strings.Contains(start.Name.Space, "/onix/3.") {
+		format.Version = onixVersion30
+	}
+
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "release" && strings.HasPrefix(attr.Value, "3.") {
+			format.Version = onixVersion30
+		}
+	}
+
+	return format
+}
+
+// shortTag returns the short tag of the element, the product structs only declare those
+func (f onixFormat) shortTag(name string) string {
+	if !f.Reference {
+		return name
+	}
+
+	if f.Version == onixVersion30 {
+		if short, ok := referenceTags30[name]; ok {
+			return short
+		}
+	}
+
+	if short, ok := referenceTags[name]; ok {
+		return short
+	}
+
+	return name
+}
+
+// referenceTags are the reference names of the elements the importer reads. Other elements
+// keep their reference name and are ignored by the decoder.
+var referenceTags = map[string]string{
+	"Product":          "product",
+	"RecordReference":  "a001",
+	"NotificationType": "a002",
+	"DeletionCode":     "a198",
+	"DeletionText":     "a199",
+
+	"ProductIdentifier": "productidentifier",
+	"ProductIDType":     "b221",
+	"IDTypeName":        "b233",
+	"IDValue":           "b244",
+
+	"DescriptiveDetail": "descriptivedetail",
+	"ProductForm":       "b012",
+	"ProductFormDetail": "b333",
+
+	"Title":              "title",
+	"TitleDetail":        "titledetail",
+	"TitleElement":       "titleelement",
+	"TitleElementLevel":  "x409",
+	"TitleType":          "b202",
+	"TitleText":          "b203",
+	"TitlePrefix":        "b030",
+	"TitleWithoutPrefix": "b031",
+	"Subtitle":           "b029",
+
+	"Contributor":        "contributor",
+	"SequenceNumber":     "b034",
+	"ContributorRole":    "b035",
+	"PersonName":         "b036",
+	"PersonNameInverted": "b037",
+	"CorporateName":      "b047",
+
+	"EditionStatement": "b058",
+	"Language":         "language",
+	"LanguageRole":     "b253",
+	"LanguageCode":     "b252",
+
+	"MainSubject":                 "mainsubject",
+	"MainSubjectSchemeIdentifier": "b191",
+	"Subject":                     "subject",
+	"SubjectSchemeIdentifier":     "b067",
+	"SubjectCode":                 "b069",
+	"SubjectHeadingText":          "b070",
+
+	"Measure":         "measure",
+	"MeasureTypeCode": "c093",
+	"MeasureType":     "x315",
+	"Measurement":     "c094",
+	"MeasureUnitCode": "c095",
+
+	"PublishingDetail":     "publishingdetail",
+	"PublishingStatus":     "b394",
+	"PublishingStatusNote": "b395",
+	"PublicationDate":      "b003",
+	"PublishingDate":       "publishingdate",
+	"PublishingDateRole":   "x448",
+	"Date":                 "b306",
+
+	"RelatedMaterial":     "relatedmaterial",
+	"RelatedProduct":      "relatedproduct",
+	"RelationCode":        "h208",
+	"ProductRelationCode": "x455",
+
+	"ProductSupply":       "productsupply",
+	"SupplyDetail":        "supplydetail",
+	"AvailabilityCode":    "j141",
+	"ProductAvailability": "j396",
+	"Stock":               "stock",
+	"OnHand":              "j350",
+	"Price":               "price",
+	"PriceTypeCode":       "j148",
+	"PriceType":           "x462",
+	"PriceAmount":         "j151",
+	"CurrencyCode":        "j152",
+}
+
+// referenceTags30 are the reference names whose short tag changed in ONIX 3.0
+var referenceTags30 = map[string]string{
+	// the <mainsubject> composite of 2.1 became a flag inside <Subject>
+	"MainSubject": "x425",
+}
+
+// recordTokens feeds one record to a decoder with the element names translated to short
+// tags. It starts with the record start element, which the onixReader has already read.
+type recordTokens struct {
+	start   *xml.StartElement
+	decoder *xml.Decoder
+	format  onixFormat
+}
+
+func (r *recordTokens) Token() (xml.Token, error) {
+	var token xml.Token
+	if r.start != nil {
+		token, r.start = *r.start, nil
+	} else {
+		var err error
+		token, err = r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch t := token.(type) {
+	case xml.StartElement:
+		t.Name.Local = r.format.shortTag(t.Name.Local)
+		return t, nil
+	case xml.EndElement:
+		t.Name.Local = r.format.shortTag(t.Name.Local)
+		return t, nil
+	}
+
+	return token, nil
+}
+
+// decodeRecord decodes the record which starts with start into the common representation
+func decodeRecord(decoder *xml.Decoder, start xml.StartElement, format onixFormat) (record onixRecord, err error) {
+	records := xml.NewTokenDecoder(&recordTokens{start: &start, decoder: decoder, format: format})
+
+	if format.Version == onixVersion30 {
+		var product Product3
+		err = records.Decode(&product)
+		if err != nil {
+			return
+		}
+
+		return product.record(), nil
+	}
+
+	var product Product
+	err = records.Decode(&product)
+	if err != nil {
+		return
+	}
+
+	return product.record(), nil
+}
@@ -0,0 +1,212 @@
+package sync
+
+import (
+	"bookbox-backend/internal/model"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDetectOnixFormat(t *testing.T) {
+	release := func(value string) []xml.Attr {
+		return []xml.Attr{{Name: xml.Name{Local: "release"}, Value: value}}
+	}
+
+	tests := []struct {
+		name  string
+		start xml.StartElement
+		want  onixFormat
+	}{
+		{
+			name:  "2.1 short tags",
+			start: xml.StartElement{Name: xml.Name{Local: "ONIXmessage"}},
+			want:  onixFormat{Version: onixVersion21},
+		},
+		{
+			name:  "2.1 reference tags",
+			start: xml.StartElement{Name: xml.Name{Local: "ONIXMessage"}},
+			want:  onixFormat{Version: onixVersion21, Reference: true},
+		},
+		{
+			name:  "2.1 release attribute",
+			start: xml.StartElement{Name: xml.Name{Local: "ONIXMessage"}, Attr: release("2.1")},
+			want:  onixFormat{Version: onixVersion21, Reference: true},
+		},
+		{
+			name:  "3.0 short tags",
+			start: xml.StartElement{Name: xml.Name{Local: "ONIXmessage"}, Attr: release("3.0")},
+			want:  onixFormat{Version: onixVersion30},
+		},
+		{
+			name:  "3.0 reference tags",
+			start: xml.StartElement{Name: xml.Name{Local: "ONIXMessage"}, Attr: release("3.0")},
+			want:  onixFormat{Version: onixVersion30, Reference: true},
+		},
+		{
+			name:  "3.1 release",
+			start: xml.StartElement{Name: xml.Name{Local: "ONIXMessage"}, Attr: release("3.1")},
+			want:  onixFormat{Version: onixVersion30, Reference: true},
+		},
+		{
+			name:  "3.0 namespace without release",
+			start: xml.StartElement{Name: xml.Name{Space: "http://ns.editeur.org/onix/3.0/short", Local: "ONIXmessage"}},
+			want:  onixFormat{Version: onixVersion30},
+		},
+		{
+			name:  "short product without root",
+			start: xml.StartElement{Name: xml.Name{Local: "product"}},
+			want:  onixFormat{Version: onixVersion21},
+		},
+		{
+			name:  "reference product without root",
+			start: xml.StartElement{Name: xml.Name{Local: "Product"}},
+			want:  onixFormat{Version: onixVersion21, Reference: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := detectOnixFormat(test.start); got != test.want {
+				t.Errorf("format is %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOnixShortTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		format onixFormat
+		tag    string
+		want   string
+	}{
+		{name: "record", format: onixFormat{Version: onixVersion21, Reference: true}, tag: "Product", want: "product"},
+		{name: "element", format: onixFormat{Version: onixVersion21, Reference: true}, tag: "RecordReference", want: "a001"},
+		{name: "composite", format: onixFormat{Version: onixVersion30, Reference: true}, tag: "DescriptiveDetail", want: "descriptivedetail"},
+		{name: "main subject composite of 2.1", format: onixFormat{Version: onixVersion21, Reference: true}, tag: "MainSubject", want: "mainsubject"},
+		{name: "main subject flag of 3.0", format: onixFormat{Version: onixVersion30, Reference: true}, tag: "MainSubject", want: "x425"},
+		{name: "unused element keeps its name", format: onixFormat{Version: onixVersion21, Reference: true}, tag: "FromCompany", want: "FromCompany"},
+		{name: "short tags are not translated", format: onixFormat{Version: onixVersion21}, tag: "Product", want: "Product"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.format.shortTag(test.tag); got != test.want {
+				t.Errorf("short tag of %s is %s, want %s", test.tag, got, test.want)
+			}
+		})
+	}
+}
+
+// TestOnixReader reads the same two products from every version and tag style: a complete
+// record and an out of print title
+func TestOnixReader(t *testing.T) {
+	available := onixRecord{
+		RecordReference:  "1000001",
+		NotificationType: "03",
+		Identifiers: []onixIdentifier{
+			{Type: "01", TypeName: "01", Value: "1000001"},
+			{Type: "15", Value: "9783000000011"},
+		},
+		ProductForm: "BC",
+		Titles: []onixTitle{
+			{Type: "01", Text: "Der Testtitel", Subtitle: "Ein Untertitel"},
+		},
+		Contributors: []onixContributor{
+			{Role: "A01", NameInverted: "Muster, Max"},
+			{Role: "B01", NameInverted: "Beispiel, Berta"},
+		},
+		Edition:   "2. Auflage",
+		Languages: []string{"ger"},
+		MainSubjects: []onixSubject{
+			{SchemeIdentifier: "26", Code: "1110"},
+		},
+		Measures: []onixMeasure{
+			{Type: "01", Measurement: "205", Unit: "mm"},
+			{Type: "08", Measurement: "350", Unit: "gr"},
+		},
+		PublishingStatus:     "04",
+		PublishingStatusNote: "LZ:Lieferbar in 2 Tagen",
+		PublicationDate:      "20240101",
+		RelatedProducts: []onixRelatedProduct{
+			{RelationCode: "05", Identifiers: []onixIdentifier{{Type: "01", TypeName: "01", Value: "999999"}}},
+		},
+		Supplies: []onixSupply{
+			{
+				AvailabilityCode:    "IP",
+				ProductAvailability: "20",
+				OnHand:              "5",
+				Prices:              []onixPrice{{Type: "02", Amount: "24.90", Currency: "CHF"}},
+			},
+		},
+	}
+
+	// ONIX 3.0 dropped the AvailabilityCode of 2.1
+	available30 := available
+	available30.Supplies = []onixSupply{available.Supplies[0]}
+	available30.Supplies[0].AvailabilityCode = ""
+
+	tests := []struct {
+		file      string
+		format    onixFormat
+		available onixRecord
+	}{
+		{file: "onix21_short.xml", format: onixFormat{Version: onixVersion21}, available: available},
+		{file: "onix21_reference.xml", format: onixFormat{Version: onixVersion21, Reference: true}, available: available},
+		{file: "onix30_short.xml", format: onixFormat{Version: onixVersion30}, available: available30},
+		{file: "onix30_reference.xml", format: onixFormat{Version: onixVersion30, Reference: true}, available: available30},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			file, err := os.Open(filepath.Join("testdata", "onix", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			reader := newOnixReader(file, zap.NewNop())
+
+			record, err := reader.Next()
+			if err != nil {
+				t.Fatalf("first record: %v", err)
+			}
+
+			if reader.format != test.format {
+				t.Errorf("format is %+v, want %+v", reader.format, test.format)
+			}
+
+			if !reflect.DeepEqual(record, test.available) {
+				t.Errorf("record is\n%+v\nwant\n%+v", record, test.available)
+			}
+
+			if availability, reason := productAvailability(record); availability != model.ProductAvailable || reason != "" {
+				t.Errorf("availability is %s (%s), want available", availability, reason)
+			}
+
+			record, err = reader.Next()
+			if err != nil {
+				t.Fatalf("second record: %v", err)
+			}
+
+			if availability, reason := productAvailability(record); record.RecordReference != "1000002" || availability != model.ProductUnavailable || reason != "out of print" {
+				t.Errorf("%s is %s (%s), want unavailable because it is out of print", record.RecordReference, availability, reason)
+			}
+
+			_, err = reader.Next()
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("expected the end of the file, got %v", err)
+			}
+
+			if reader.Malformed != 0 {
+				t.Errorf("%d records are malformed", reader.Malformed)
+			}
+		})
+	}
+}
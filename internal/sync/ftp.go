@@ -1,29 +1,54 @@
 package sync
 
 import (
+	"bookbox-backend/internal/config"
 	"bookbox-backend/pkg/logger"
-	"time"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
 
 	"github.com/pkg/sftp"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
+// ErrHostKeyMismatch means the server presented a different key than known_hosts lists for it,
+// the connection is refused because someone may be intercepting it
+var ErrHostKeyMismatch = errors.New("sftp host key mismatch")
+
+// ConnectToFtp opens an SFTP session with the credentials of the configuration, they are read
+// on every connect so rotated secrets are picked up
 func ConnectToFtp(ftpUrl string) (sftpClient *sftp.Client, err error) {
+	settings := liveConfig.Get().SFTP
+
+	auth, err := sftpAuthMethods(settings)
+	if err != nil {
+		logger.Log.Error("failed to load sftp credentials", zap.Error(err))
+		return
+	}
+
+	hostKeyCallback, err := sftpHostKeyCallback(settings.KnownHosts)
+	if err != nil {
+		logger.Log.Error("failed to load sftp known hosts",
+			zap.String("knownHosts", settings.KnownHosts),
+			zap.Error(err),
+		)
+		return
+	}
+
 	// SSH client config
-	config := &ssh.ClientConfig{
-		User: "bztr7423",
-		Auth: []ssh.AuthMethod{
-			ssh.Password("ZZbwMcqg0h4rNcpeQGCI"),
-		},
-		// This is to accept any host key.
-		// Consider using ssh.FixedHostKey() for higher security
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         5 * time.Second,
+	sshConfig := &ssh.ClientConfig{
+		User:            settings.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         settings.Timeout,
 	}
 
 	// Connect to the SSH server
-	conn, err := ssh.Dial("tcp", ftpUrl, config)
+	conn, err := ssh.Dial("tcp", ftpUrl, sshConfig)
 	if err != nil {
 		logger.Log.Error("failed to dial", zap.Error(err))
 		return
@@ -39,3 +64,71 @@ func ConnectToFtp(ftpUrl string) (sftpClient *sftp.Client, err error) {
 
 	return
 }
+
+// sftpAuthMethods offers the private key first, then the password
+func sftpAuthMethods(settings config.SFTPConfig) (auth []ssh.AuthMethod, err error) {
+	if settings.PrivateKey != "" {
+		var keyPEM []byte
+		keyPEM, err = os.ReadFile(filepath.Clean(settings.PrivateKey))
+		if err != nil {
+			return
+		}
+
+		var signer ssh.Signer
+		if settings.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(keyPEM, []byte(settings.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(keyPEM)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("SFTP_PRIVATE_KEY: %w", err)
+		}
+
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if settings.Password != "" {
+		auth = append(auth, ssh.Password(settings.Password))
+	}
+
+	if len(auth) == 0 {
+		err = fmt.Errorf("neither SFTP_PRIVATE_KEY nor SFTP_PASSWORD is set")
+	}
+
+	return
+}
+
+// sftpHostKeyCallback accepts only the keys listed for the host in the known_hosts file
+func sftpHostKeyCallback(knownHostsPath string) (ssh.HostKeyCallback, error) {
+	check, err := knownhosts.New(filepath.Clean(knownHostsPath))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) > 0 {
+			logger.Log.Error("SFTP HOST KEY MISMATCH, refusing to connect",
+				zap.String("host", hostname),
+				zap.String("remote", remote.String()),
+				zap.String("fingerprint", ssh.FingerprintSHA256(key)),
+				zap.String("knownHosts", knownHostsPath),
+				zap.Int("knownHostsLine", keyErr.Want[0].Line),
+			)
+
+			return fmt.Errorf("%w for %s: got %s", ErrHostKeyMismatch, hostname, ssh.FingerprintSHA256(key))
+		}
+
+		if errors.As(err, &keyErr) {
+			logger.Log.Error("sftp host is not in known_hosts, refusing to connect",
+				zap.String("host", hostname),
+				zap.String("fingerprint", ssh.FingerprintSHA256(key)),
+				zap.String("knownHosts", knownHostsPath),
+			)
+		}
+
+		return err
+	}, nil
+}
@@ -1,18 +1,19 @@
 package sync
 
 import (
+	"archive/zip"
 	"bookbox-backend/internal/database"
 	"bookbox-backend/internal/model"
 	"bookbox-backend/pkg/logger"
+	"context"
 	_ "embed"
-	"encoding/xml"
 	"fmt"
-	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -3169,7 +3170,7 @@ type (
 
 		   According to the identifier type specified in <AgentIDType>
 		*/
-		IDValue string `xml"b244"`
+		IDValue string `xml:"b244"`
 	}
 
 	MarketDate struct {
@@ -3381,10 +3382,11 @@ const (
 	bzPartialDLFileName = "bzonixdl"
 )
 
-func loadOnixFiles(syncData model.Sync, zipLocation, saveLocation string, isDL bool) (newestOnix int64, err error) {
+// loadOnixFiles writes the full archive, unless the catalog was fully synced before, and the
+// partial archives newer than the last sync. The entries are parsed straight from the archives.
+func loadOnixFiles(ctx context.Context, syncData model.Sync, isDL bool, run *syncRun) (newestOnix int64, err error) {
 	log := logger.Log.WithOptions(zap.Fields(
-		zap.String("zipLocation", zipLocation),
-		zap.Any("saveLocation", saveLocation),
+		zap.Bool("isDL", isDL),
 	))
 	log.Info("started load onix files")
 
@@ -3397,38 +3399,72 @@ func loadOnixFiles(syncData model.Sync, zipLocation, saveLocation string, isDL b
 		bzPartialLoc = bzPartialDLFileName
 	}
 
+	source, err := OpenCatalogSource()
+	if err != nil {
+		log.Error("failed to connect to catalog source",
+			zap.Error(err),
+		)
+		return
+	}
+	defer source.Close()
+
+	archives := make([]string, 0)
 	if !syncData.IsFullSynced {
-		syncData.LastOnixSyncDate, err = FindFullOnixFiles(rootLoc, bzFileLoc, zipLocation, saveLocation)
+		var fullArchive string
+		syncData.LastOnixSyncDate, fullArchive, err = FindFullOnixFiles(source, rootLoc, bzFileLoc)
 		if err != nil {
 			log.Error("failed to find full onix files", zap.Error(err))
 			return 0, err
 		}
+
+		archives = append(archives, fullArchive)
 	}
 	log.Info("started load partial onix files")
 
-	syncData.LastOnixSyncDate, err = FindPartialOnixFiles(rootLoc, bzPartialLoc, zipLocation, saveLocation, syncData.LastOnixSyncDate)
+	partialArchives, newestOnix, err := FindPartialOnixFiles(source, rootLoc, bzPartialLoc, syncData.LastOnixSyncDate)
 	if err != nil {
 		log.Error("failed to find partial onix files", zap.Error(err))
 		return 0, err
 	}
+	archives = append(archives, partialArchives...)
+
+	for _, archivePath := range archives {
+		err = loadOnixArchive(ctx, source, archivePath, isDL, run)
+		if err != nil {
+			log.Error("failed to load onix archive",
+				zap.String("archive", archivePath),
+				zap.Error(err),
+			)
+			return 0, err
+		}
+	}
 
-	return syncData.LastOnixSyncDate, nil
+	return newestOnix, nil
 }
 
-func FindFullOnixFiles(ftpRootPath, bzFile, zipLocation, saveLocation string) (newestDate int64, err error) {
-	// FTP server URL
-	ftpClient, err := ConnectToFtp(ftpUrl)
-	if err != nil {
-		logger.Log.Error("failed to connect to ftp",
-			zap.Error(err),
-		)
+// onixArchiveDate returns the date in the name of an ONIX archive, e.g. bzonix20240102.zip
+func onixArchiveDate(name string, bzFile string) (date time.Time, err error) {
+	splits := strings.Split(name, bzFile)
+	if len(splits) < 2 {
+		err = fmt.Errorf("failed to split entry name")
 		return
 	}
-	defer ftpClient.Close()
 
-	entries, err := ftpClient.ReadDir(ftpRootPath)
+	splits = strings.Split(splits[1], ".")
+	if len(splits) == 0 {
+		err = fmt.Errorf("incorrect onix file name")
+		return
+	}
+
+	layout := "20060102"
+	return time.Parse(layout, splits[0])
+}
+
+// FindFullOnixFiles returns the newest full archive and its date
+func FindFullOnixFiles(source CatalogSource, ftpRootPath, bzFile string) (newestDate int64, archivePath string, err error) {
+	entries, err := source.List(ftpRootPath)
 	if err != nil {
-		logger.Log.Error("failed to list ftp server", zap.Error(err))
+		logger.Log.Error("failed to list catalog source", zap.Error(err))
 		return
 	}
 
@@ -3446,22 +3482,9 @@ func FindFullOnixFiles(ftpRootPath, bzFile, zipLocation, saveLocation string) (n
 			continue
 		}
 
-		splits := strings.Split(entry.Name(), bzFile)
-		if len(splits) < 2 {
-			err = fmt.Errorf("failed to split entry name")
-			return
-		}
-
-		splits = strings.Split(splits[1], ".")
-		if len(splits) == 0 {
-			err = fmt.Errorf("incorrect onix file name")
-			return
-		}
-
-		layout := "20060102"
-		t, err := time.Parse(layout, splits[0])
+		t, err := onixArchiveDate(entry.Name(), bzFile)
 		if err != nil {
-			return 0, err
+			return 0, "", err
 		}
 
 		if t.Unix() > newestDate {
@@ -3484,187 +3507,131 @@ func FindFullOnixFiles(ftpRootPath, bzFile, zipLocation, saveLocation string) (n
 		zap.String("entryName", entries[index].Name()),
 	)
 
-	filePath := filepath.Join(ftpRootPath, entries[index].Name())
-	err = DownloadAndDecompress(ftpClient, filePath, zipLocation, saveLocation)
-	if err != nil {
-		return
-	}
-
+	archivePath = path.Join(ftpRootPath, entries[index].Name())
 	return
 }
 
-func FindPartialOnixFiles(ftpRootPath, bzFile, zipLocation, saveLocation string, lastSyncDate int64) (newestDate int64, err error) {
-	// FTP server URL
-	ftpClient, err := ConnectToFtp(ftpUrl)
+// FindPartialOnixFiles returns the partial archives newer than lastSyncDate, oldest first so
+// later changes win, and the date of the newest archive
+func FindPartialOnixFiles(source CatalogSource, ftpRootPath, bzFile string, lastSyncDate int64) (archivePaths []string, newestDate int64, err error) {
+	entries, err := source.List(ftpRootPath)
 	if err != nil {
-		logger.Log.Error("failed to connect to ftp",
-			zap.Error(err),
-		)
+		logger.Log.Error("failed to list catalog source", zap.Error(err))
 		return
 	}
-	defer ftpClient.Close()
 
-	entries, err := ftpClient.ReadDir(rootPath)
-	if err != nil {
-		logger.Log.Error("failed to list ftp server", zap.Error(err))
+	if len(entries) == 0 {
+		err = fmt.Errorf("failed to list entries")
 		return
 	}
 
-	var (
-		index int
-	)
+	dates := make(map[string]int64)
 
 	logger.Log.Info("loading entries")
-	for i, entry := range entries {
+	for _, entry := range entries {
 		if !strings.Contains(entry.Name(), bzFile) {
 			continue
 		}
 
-		splits := strings.Split(entry.Name(), bzFile)
-		if len(splits) < 2 {
-			err = fmt.Errorf("failed to split entry name")
-			return
-		}
-
-		splits = strings.Split(splits[1], ".")
-		if len(splits) == 0 {
-			err = fmt.Errorf("incorrect onix file name")
-			return
-		}
-
-		layout := "20060102"
-		t, err := time.Parse(layout, splits[0])
+		t, err := onixArchiveDate(entry.Name(), bzFile)
 		if err != nil {
-			return 0, err
+			return nil, 0, err
 		}
 
 		if t.Unix() > newestDate {
 			newestDate = t.Unix()
-			index = i
 		}
 
 		if t.Unix() > lastSyncDate {
-			filePath := filepath.Join(ftpRootPath, entry.Name())
-
-			err = DownloadAndDecompress(ftpClient, filePath, zipLocation, saveLocation)
-			if err != nil {
-				return 0, err
-			}
+			archivePath := path.Join(ftpRootPath, entry.Name())
+			archivePaths = append(archivePaths, archivePath)
+			dates[archivePath] = t.Unix()
 		}
 	}
 
-	if len(entries) == 0 {
-		err = fmt.Errorf("failed to list entries")
-		return
-	}
+	sort.SliceStable(archivePaths, func(i, j int) bool {
+		return dates[archivePaths[i]] < dates[archivePaths[j]]
+	})
 
-	logger.Log.Info("found newest entry",
-		zap.String("entryName", entries[index].Name()),
+	logger.Log.Info("found partial entries",
+		zap.Strings("archives", archivePaths),
 	)
 
 	return
 }
 
-func loadOnixData(dataLocation string, isDL bool) (err error) {
-	defer os.RemoveAll(dataLocation)
-
-	logger.Log.Info("reading xml files from disk",
-		zap.String("directoryPath", dataLocation),
-	)
-
-	files, err := os.ReadDir(dataLocation)
+// loadOnixArchive writes the products of every ONIX file in the archive, files in the
+// checkpoint are skipped. Every written file is recorded in the sync run.
+func loadOnixArchive(ctx context.Context, source CatalogSource, archivePath string, isDL bool, run *syncRun) (err error) {
+	opened, err := openArchive(ctx, source, archivePath, filepath.Dir(onixZipLocation))
 	if err != nil {
-		logger.Log.Info("already up to date",
-			zap.Error(err),
-		)
-		err = nil
 		return
 	}
+	defer opened.Close()
 
-	for _, file := range files {
-		onix := Onix{}
-		filePath := filepath.Join(dataLocation, file.Name())
+	logger.Log.Info("reading xml files from archive",
+		zap.String("archive", archivePath),
+		zap.Int("entries", len(opened.File)),
+	)
 
-		log := logger.Log.WithOptions(zap.Fields(
-			zap.Any("filePath", filePath),
-		))
+	phase := onixPhase
+	if isDL {
+		phase = onixDLPhase
+	}
 
-		raw, err := os.ReadFile(filePath)
-		if err != nil {
-			return err
+	for _, file := range opened.File {
+		if err = ctx.Err(); err != nil {
+			return
 		}
 
-		log.Info("getting data from xml for products")
-
-		err = xml.Unmarshal(raw, &onix)
-		if err != nil {
-			return err
+		if !isRegularEntry(file) {
+			continue
 		}
 
-		productCreate := make([]model.Product, 0)
-		productUpdate := make([]model.Product, 0)
-		for _, product := range onix.Products {
-			p, err := parseProductData(product, isDL)
-			if err != nil {
-				log.Debug("skipping product",
-					zap.Error(err),
-				)
-				continue
-			}
-
-			// TODO:
-			if isDL {
-				p.IsDownloadTitle = true
-			}
-
-			var product model.Product
-			if err := database.DB.Where("id = ?", p.ID).First(&product).Error; err != nil {
-				p.SalesChannels = append(p.SalesChannels, model.SalesChannelProduct{
-					SalesChannelID: "1",
-				})
+		name := path.Base(file.Name)
+		log := logger.Log.WithOptions(zap.Fields(
+			zap.String("archive", archivePath),
+			zap.String("filePath", file.Name),
+		))
 
-				productCreate = append(productCreate, p)
-			} else {
-				productUpdate = append(productUpdate, p)
-			}
+		key := checkpointKey(phase, archiveVersion(archivePath, opened.info), name)
+		if run.progress.Done(key) {
+			log.Info("skipping file, written before the sync was interrupted")
+			continue
 		}
 
-		log.Info("uploading batch products",
-			zap.Int("updateCount", len(productUpdate)),
-			zap.Int("createCount", len(productCreate)),
-		)
-
-		err = batchCreate(productCreate, 100)
+		var counts model.SyncCounts
+		runFile := run.startFile(phase, archivePath, name)
+		counts, err = loadOnixEntry(ctx, file, isDL, log)
+		run.finishFile(runFile, counts, err)
 		if err != nil {
-			log.Error("failed batch create",
-				zap.Error(err),
-			)
-			return err
+			return
 		}
 
-		wg := sync.WaitGroup{}
-		wg.Add(2)
-		err = batchUpdate(productUpdate[0:len(productUpdate)/2], &wg)
+		err = run.progress.Save(key)
 		if err != nil {
-			log.Error("failed batch update",
+			log.Error("failed to save sync checkpoint",
 				zap.Error(err),
 			)
 
 			return err
 		}
+	}
 
-		err = batchUpdate(productUpdate[len(productUpdate)/2:], &wg)
-		if err != nil {
-			log.Error("failed batch update",
-				zap.Error(err),
-			)
+	return
+}
 
-			return err
-		}
-		wg.Wait()
+// loadOnixEntry streams one ONIX file of the archive into the database
+func loadOnixEntry(ctx context.Context, file *zip.File, isDL bool, log *zap.Logger) (counts model.SyncCounts, err error) {
+	content, err := openEntry(file)
+	if err != nil {
+		return
 	}
+	defer content.Close()
 
-	return
+	log.Info("getting data from xml for products")
+
+	return writeOnixRecords(ctx, content, isDL, log)
 }
 
 func formatISBN(isbn string) string {
@@ -3683,16 +3650,16 @@ func formatISBN(isbn string) string {
 	return formattedISBN
 }
 
-func parseProductData(input Product, isDL bool) (output model.Product, err error) {
+func parseProductData(input onixRecord, isDL bool) (output model.Product, err error) {
 	// author
 	hasAuthor := false
 	maximum := 5
-	for _, c := range input.Contributor {
-		if c.ContributorRole == "A01" {
+	for _, c := range input.Contributors {
+		if c.Role == "A01" {
 			if !hasAuthor {
-				output.Publisher = c.PersonNameInverted
+				output.Publisher = c.NameInverted
 			} else {
-				output.Publisher += ";" + c.PersonNameInverted
+				output.Publisher += ";" + c.NameInverted
 			}
 
 			maximum--
@@ -3706,15 +3673,15 @@ func parseProductData(input Product, isDL bool) (output model.Product, err error
 
 	// language
 	language := ""
-	for _, lang := range input.Language {
-		language = language + languageMap[lang.LanguageCode] + " / "
+	for _, lang := range input.Languages {
+		language = language + languageMap[lang] + " / "
 	}
 
 	language = strings.Trim(language, " / ")
 	output.Language = language
 
 	// edition
-	output.Edition = input.EditionStatement
+	output.Edition = input.Edition
 
 	// stock if unavailable then stock 0
 	output.Stock = 1000
@@ -3747,40 +3714,40 @@ func parseProductData(input Product, isDL bool) (output model.Product, err error
 	}
 
 	// measure
-	for _, m := range input.Measure {
-		if m.MeasureTypeCode == "01" {
+	for _, m := range input.Measures {
+		if m.Type == "01" {
 			output.Height = m.Measurement
 		}
 
-		if m.MeasureTypeCode == "02" {
+		if m.Type == "02" {
 			output.Width = m.Measurement
 		}
 
-		if m.MeasureTypeCode == "03" {
+		if m.Type == "03" {
 			output.Length = m.Measurement
 		}
 
-		if m.MeasureTypeCode == "08" {
+		if m.Type == "08" {
 			output.Weight = m.Measurement
 		}
 	}
 
 	// izbn, ean, bz number
-	for _, productIdentifier := range input.ProductIdentifier {
+	for _, productIdentifier := range input.Identifiers {
 		// PRODUCT NUMMER AND ISBN
-		switch productIdentifier.ProductIDType {
+		switch productIdentifier.Type {
 		case "01":
-			switch productIdentifier.IDTypeName {
+			switch productIdentifier.TypeName {
 			case "01":
-				output.ID = productIdentifier.IDValue
-				output.BZNR = productIdentifier.IDValue
+				output.ID = productIdentifier.Value
+				output.BZNR = productIdentifier.Value
 			case "03":
 				// PRODUCT NUMBER
 			}
 		case "03":
-			output.EAN = productIdentifier.IDValue
+			output.EAN = productIdentifier.Value
 		case "15":
-			output.ISBN = formatISBN(productIdentifier.IDValue)
+			output.ISBN = formatISBN(productIdentifier.Value)
 		}
 	}
 
@@ -3796,11 +3763,25 @@ func parseProductData(input Product, isDL bool) (output model.Product, err error
 		}
 	}
 
+	// deleted and permanently unavailable titles only withdraw an existing product, the rest
+	// of the record may be missing
+	output.Availability, output.UnavailableReason = productAvailability(input)
+	if output.IsWithdrawn() {
+		output.Stock = 0
+		return
+	}
+
+	if output.Availability == model.ProductOutOfStock {
+		output.Stock = 0
+	} else if output.Stock == 0 {
+		output.Availability = model.ProductOutOfStock
+	}
+
 	// price
-	for _, supply := range input.SupplyDetail {
-		for _, p := range supply.Price {
-			if p.PriceTypeCode == "02" {
-				output.SellingPrice, err = strconv.ParseFloat(p.PriceAmount, 64)
+	for _, supply := range input.Supplies {
+		for _, p := range supply.Prices {
+			if p.Type == "02" {
+				output.SellingPrice, err = strconv.ParseFloat(p.Amount, 64)
 				if err != nil {
 					return
 				}
@@ -3814,17 +3795,17 @@ func parseProductData(input Product, isDL bool) (output model.Product, err error
 	}
 
 	// title and subtitle
-	for _, t := range input.Title {
-		output.Title = t.TitleText
+	for _, t := range input.Titles {
+		output.Title = t.Text
 		output.Subtitle = t.Subtitle
-		if t.TitleType == "01" {
+		if t.Type == "01" {
 			break
 		}
 	}
 
 	// category
-	for _, subject := range input.MainSubject {
-		if subject.MainSubjectSchemeIdentifier != "26" {
+	for _, subject := range input.MainSubjects {
+		if subject.SchemeIdentifier != "26" || subject.Code == "" {
 			continue
 		}
 
@@ -3838,7 +3819,7 @@ func parseProductData(input Product, isDL bool) (output model.Product, err error
 			EbooksCategory         = "1007"
 			AudioDownloadsCategory = "1020"
 		)
-		categoryId := subject.SubjectCode[1:]
+		categoryId := subject.Code[1:]
 
 		if val, found := newCategoriesMap[categoryId]; found {
 			var category model.Category
@@ -3846,7 +3827,7 @@ func parseProductData(input Product, isDL bool) (output model.Product, err error
 			if res.RowsAffected != 0 {
 				output.Categories = make([]model.ProductCategory, 0)
 				output.Categories = append(output.Categories, model.ProductCategory{
-					CategoryID: subject.SubjectCode,
+					CategoryID: subject.Code,
 				})
 			}
 		}
@@ -3909,33 +3890,33 @@ func parseProductData(input Product, isDL bool) (output model.Product, err error
 			}
 
 		} else {
-			var firstChar byte = subject.SubjectCode[0]
+			var firstChar byte = subject.Code[0]
 			switch firstChar {
 			case '1':
-				subject.SubjectCode = subject.SubjectCode[1:]
+				subject.Code = subject.Code[1:]
 			case '2':
-				subject.SubjectCode = subject.SubjectCode[1:]
+				subject.Code = subject.Code[1:]
 			case '3':
-				subject.SubjectCode = subject.SubjectCode[1:]
+				subject.Code = subject.Code[1:]
 			case '4':
-				subject.SubjectCode = DVDVideoCategory
+				subject.Code = DVDVideoCategory
 			case '5':
-				subject.SubjectCode = AudioCDCategory
+				subject.Code = AudioCDCategory
 			case '6':
-				subject.SubjectCode = CDROMCategory
+				subject.Code = CDROMCategory
 			case '7':
-				subject.SubjectCode = CalendarCategory
+				subject.Code = CalendarCategory
 			case '8':
-				subject.SubjectCode = MapsCategory
+				subject.Code = MapsCategory
 			case '9':
-				subject.SubjectCode = NonBooksCategory
+				subject.Code = NonBooksCategory
 			default:
 				err = fmt.Errorf("encountered invalid first char %c", firstChar)
 				return
 			}
 
 			var category model.Category
-			res := database.DB.First(&category, subject.SubjectCode)
+			res := database.DB.First(&category, subject.Code)
 			if res.RowsAffected == 0 {
 				err = fmt.Errorf("skipping due to category not existing")
 				return
@@ -3943,7 +3924,7 @@ func parseProductData(input Product, isDL bool) (output model.Product, err error
 
 			output.Categories = make([]model.ProductCategory, 0)
 			output.Categories = append(output.Categories, model.ProductCategory{
-				CategoryID: subject.SubjectCode,
+				CategoryID: subject.Code,
 			})
 
 			// add to andere and bucher root category
@@ -3961,10 +3942,10 @@ func parseProductData(input Product, isDL bool) (output model.Product, err error
 		}
 	}
 
-	if len(input.RelatedProduct) != 0 {
-		for _, productIdentifier := range input.RelatedProduct[0].ProductIdentifier {
-			if productIdentifier.ProductIDType == "01" && productIdentifier.IDTypeName == "01" {
-				output.Replacement = productIdentifier.IDValue
+	if len(input.RelatedProducts) != 0 {
+		for _, productIdentifier := range input.RelatedProducts[0].Identifiers {
+			if productIdentifier.Type == "01" && productIdentifier.TypeName == "01" {
+				output.Replacement = productIdentifier.Value
 			}
 		}
 	}
@@ -0,0 +1,212 @@
+package sync
+
+// ONIX 3.0 groups the product record into blocks. Only the elements the importer uses are
+// declared, with their short tags; reference tags are translated by the onixReader.
+type (
+	Product3 struct {
+		RecordReference   string              `xml:"a001"`
+		NotificationType  string              `xml:"a002"`
+		DeletionText      string              `xml:"a199"`
+		ProductIdentifier []ProductIdentifier `xml:"productidentifier"`
+
+		// Block 1, product description
+		DescriptiveDetail DescriptiveDetail3 `xml:"descriptivedetail"`
+
+		// Block 4, publishing
+		PublishingDetail PublishingDetail3 `xml:"publishingdetail"`
+
+		// Block 5, related material
+		RelatedMaterial RelatedMaterial3 `xml:"relatedmaterial"`
+
+		// Block 6, product supply, repeated per market
+		ProductSupply []ProductSupply3 `xml:"productsupply"`
+	}
+
+	DescriptiveDetail3 struct {
+		ProductForm       string         `xml:"b012"`
+		ProductFormDetail []string       `xml:"b333"`
+		Measure           []Measure3     `xml:"measure"`
+		TitleDetail       []TitleDetail3 `xml:"titledetail"`
+		Contributor       []Contributor3 `xml:"contributor"`
+		EditionStatement  string         `xml:"b058"`
+		Language          []Language     `xml:"language"`
+		Subject           []Subject3     `xml:"subject"`
+	}
+
+	Measure3 struct {
+		MeasureType     string `xml:"x315"`
+		Measurement     string `xml:"c094"`
+		MeasureUnitCode string `xml:"c095"`
+	}
+
+	TitleDetail3 struct {
+		TitleType    string          `xml:"b202"`
+		TitleElement []TitleElement3 `xml:"titleelement"`
+	}
+
+	TitleElement3 struct {
+		// 01 product, 02 collection, 03 subcollection
+		TitleElementLevel  string `xml:"x409"`
+		TitleText          string `xml:"b203"`
+		TitlePrefix        string `xml:"b030"`
+		TitleWithoutPrefix string `xml:"b031"`
+		Subtitle           string `xml:"b029"`
+	}
+
+	Contributor3 struct {
+		SequenceNumber     string `xml:"b034"`
+		ContributorRole    string `xml:"b035"`
+		PersonName         string `xml:"b036"`
+		PersonNameInverted string `xml:"b037"`
+		CorporateName      string `xml:"b047"`
+	}
+
+	Subject3 struct {
+		// empty element, present on the main subject
+		MainSubject             *struct{} `xml:"x425"`
+		SubjectSchemeIdentifier string    `xml:"b067"`
+		SubjectCode             string    `xml:"b069"`
+		SubjectHeadingText      string    `xml:"b070"`
+	}
+
+	PublishingDetail3 struct {
+		PublishingStatus     string            `xml:"b394"`
+		PublishingStatusNote string            `xml:"b395"`
+		PublishingDate       []PublishingDate3 `xml:"publishingdate"`
+	}
+
+	PublishingDate3 struct {
+		// 01 publication date
+		PublishingDateRole string `xml:"x448"`
+		Date               string `xml:"b306"`
+	}
+
+	RelatedMaterial3 struct {
+		RelatedProduct []RelatedProduct3 `xml:"relatedproduct"`
+	}
+
+	RelatedProduct3 struct {
+		ProductRelationCode []string            `xml:"x455"`
+		ProductIdentifier   []ProductIdentifier `xml:"productidentifier"`
+	}
+
+	ProductSupply3 struct {
+		SupplyDetail []SupplyDetail3 `xml:"supplydetail"`
+	}
+
+	SupplyDetail3 struct {
+		ProductAvailability string   `xml:"j396"`
+		Stock               []Stock  `xml:"stock"`
+		Price               []Price3 `xml:"price"`
+	}
+
+	Price3 struct {
+		PriceType    string `xml:"x462"`
+		PriceAmount  string `xml:"j151"`
+		CurrencyCode string `xml:"j152"`
+	}
+)
+
+const (
+	onixPublicationDateRole = "01"
+	onixProductTitleLevel   = "01"
+)
+
+// record maps an ONIX 3.0 product
+func (p *Product3) record() (record onixRecord) {
+	detail := p.DescriptiveDetail
+	record = onixRecord{
+		RecordReference:      p.RecordReference,
+		NotificationType:     p.NotificationType,
+		DeletionText:         p.DeletionText,
+		Identifiers:          identifiers(p.ProductIdentifier),
+		ProductForm:          detail.ProductForm,
+		Edition:              detail.EditionStatement,
+		PublishingStatus:     p.PublishingDetail.PublishingStatus,
+		PublishingStatusNote: p.PublishingDetail.PublishingStatusNote,
+	}
+
+	for _, date := range p.PublishingDetail.PublishingDate {
+		if date.PublishingDateRole == onixPublicationDateRole {
+			record.PublicationDate = date.Date
+		}
+	}
+
+	for _, t := range detail.TitleDetail {
+		for _, element := range t.TitleElement {
+			if element.TitleElementLevel != onixProductTitleLevel {
+				continue
+			}
+
+			record.Titles = append(record.Titles, onixTitle{
+				Type:     t.TitleType,
+				Text:     titleText(element.TitleText, element.TitlePrefix, element.TitleWithoutPrefix),
+				Subtitle: element.Subtitle,
+			})
+		}
+	}
+
+	for _, c := range detail.Contributor {
+		record.Contributors = append(record.Contributors, onixContributor{
+			Role:         c.ContributorRole,
+			NameInverted: c.PersonNameInverted,
+		})
+	}
+
+	for _, lang := range detail.Language {
+		record.Languages = append(record.Languages, lang.LanguageCode)
+	}
+
+	for _, subject := range detail.Subject {
+		if subject.MainSubject == nil {
+			continue
+		}
+
+		record.MainSubjects = append(record.MainSubjects, onixSubject{
+			SchemeIdentifier: subject.SubjectSchemeIdentifier,
+			Code:             subject.SubjectCode,
+		})
+	}
+
+	for _, m := range detail.Measure {
+		record.Measures = append(record.Measures, onixMeasure{
+			Type:        m.MeasureType,
+			Measurement: m.Measurement,
+			Unit:        m.MeasureUnitCode,
+		})
+	}
+
+	for _, related := range p.RelatedMaterial.RelatedProduct {
+		relationCode := ""
+		if len(related.ProductRelationCode) != 0 {
+			relationCode = related.ProductRelationCode[0]
+		}
+
+		record.RelatedProducts = append(record.RelatedProducts, onixRelatedProduct{
+			RelationCode: relationCode,
+			Identifiers:  identifiers(related.ProductIdentifier),
+		})
+	}
+
+	for _, supply := range p.ProductSupply {
+		for _, detail := range supply.SupplyDetail {
+			s := onixSupply{
+				ProductAvailability: detail.ProductAvailability,
+			}
+			if len(detail.Stock) != 0 {
+				s.OnHand = detail.Stock[0].OnHand
+			}
+			for _, price := range detail.Price {
+				s.Prices = append(s.Prices, onixPrice{
+					Type:     price.PriceType,
+					Amount:   price.PriceAmount,
+					Currency: price.CurrencyCode,
+				})
+			}
+
+			record.Supplies = append(record.Supplies, s)
+		}
+	}
+
+	return
+}
@@ -2,18 +2,23 @@ package sync
 
 import (
 	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/metrics"
 	"bookbox-backend/internal/model"
 	"bookbox-backend/pkg/logger"
-	"sync"
+	"context"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-var failed = 0
-
-func batchUpdate(products []model.Product, wg *sync.WaitGroup) (err error) {
+// batchUpdate writes each product in its own transaction, on cancellation it stops before the next product.
+// Products which fail are logged and counted, they don't stop the batch.
+func batchUpdate(ctx context.Context, products []model.Product) (failed int, err error) {
 	for i := 0; i < len(products); i++ {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
 		// Start a transaction
 		tx := database.DB.Begin()
 
@@ -23,11 +28,22 @@ func batchUpdate(products []model.Product, wg *sync.WaitGroup) (err error) {
 				logger.Log.Error("failed to update",
 					zap.Error(err),
 				)
+				metrics.SyncProducts.WithLabelValues("failed").Inc()
 				failed++
 				continue
 			}
 		}
 
+		if err := restoreAvailability(tx, products[i]); err != nil {
+			tx.Rollback()
+			logger.Log.Error("failed to update",
+				zap.Error(err),
+			)
+			metrics.SyncProducts.WithLabelValues("failed").Inc()
+			failed++
+			continue
+		}
+
 		if err := tx.Updates(&products[i]).Error; err != nil {
 			// Handle the error
 			tx.Rollback()
@@ -35,6 +51,7 @@ func batchUpdate(products []model.Product, wg *sync.WaitGroup) (err error) {
 			logger.Log.Error("failed to update",
 				zap.Error(err),
 			)
+			metrics.SyncProducts.WithLabelValues("failed").Inc()
 			failed++
 			continue
 		}
@@ -46,26 +63,30 @@ func batchUpdate(products []model.Product, wg *sync.WaitGroup) (err error) {
 			logger.Log.Error("failed to update",
 				zap.Error(err),
 			)
+			metrics.SyncProducts.WithLabelValues("failed").Inc()
 			failed++
 			continue
 		}
 
+		metrics.SyncProducts.WithLabelValues("updated").Inc()
+
 		if i%1000 == 0 {
 			time.Sleep(time.Millisecond * 50)
 		}
 	}
 
-	wg.Done()
-
-	return nil
+	return failed, nil
 }
 
 func batchCreate(products []model.Product, batchSize int) (err error) {
 	err = database.DB.CreateInBatches(products, batchSize).Error
 	if err != nil {
+		metrics.SyncProducts.WithLabelValues("failed").Add(float64(len(products)))
 		return err
 	}
 
+	metrics.SyncProducts.WithLabelValues("created").Add(float64(len(products)))
+
 	return
 }
 
@@ -0,0 +1,172 @@
+package sync
+
+import "strings"
+
+// onixRecord is the part of a product record the importer uses. Every ONIX version and tag
+// style is mapped to it, parseProductData only knows this representation.
+type onixRecord struct {
+	RecordReference  string
+	NotificationType string
+	DeletionCode     string
+	DeletionText     string
+
+	Identifiers  []onixIdentifier
+	ProductForm  string
+	Titles       []onixTitle
+	Contributors []onixContributor
+	Edition      string
+	Languages    []string
+	MainSubjects []onixSubject
+	Measures     []onixMeasure
+
+	PublishingStatus     string
+	PublishingStatusNote string
+	PublicationDate      string
+
+	RelatedProducts []onixRelatedProduct
+	Supplies        []onixSupply
+}
+
+type onixIdentifier struct {
+	Type     string
+	TypeName string
+	Value    string
+}
+
+type onixTitle struct {
+	Type     string
+	Text     string
+	Subtitle string
+}
+
+type onixContributor struct {
+	Role         string
+	NameInverted string
+}
+
+type onixSubject struct {
+	SchemeIdentifier string
+	Code             string
+}
+
+type onixMeasure struct {
+	Type        string
+	Measurement string
+	Unit        string
+}
+
+type onixRelatedProduct struct {
+	RelationCode string
+	Identifiers  []onixIdentifier
+}
+
+type onixSupply struct {
+	AvailabilityCode    string
+	ProductAvailability string
+	OnHand              string
+	Prices              []onixPrice
+}
+
+type onixPrice struct {
+	Type     string
+	Amount   string
+	Currency string
+}
+
+// titleText is the full title, which is split into prefix and rest by some senders
+func titleText(text, prefix, withoutPrefix string) string {
+	if text != "" || withoutPrefix == "" {
+		return text
+	}
+
+	return strings.TrimSpace(prefix + " " + withoutPrefix)
+}
+
+func identifiers(productIdentifiers []ProductIdentifier) []onixIdentifier {
+	result := make([]onixIdentifier, 0, len(productIdentifiers))
+	for _, identifier := range productIdentifiers {
+		result = append(result, onixIdentifier{
+			Type:     identifier.ProductIDType,
+			TypeName: identifier.IDTypeName,
+			Value:    identifier.IDValue,
+		})
+	}
+
+	return result
+}
+
+// record maps an ONIX 2.1 product
+func (p *Product) record() (record onixRecord) {
+	record = onixRecord{
+		RecordReference:      p.RecordReference,
+		NotificationType:     p.NotificationType,
+		DeletionCode:         p.DeletionCode,
+		DeletionText:         p.DeletionText,
+		Identifiers:          identifiers(p.ProductIdentifier),
+		ProductForm:          p.ProductForm,
+		Edition:              p.EditionStatement,
+		PublishingStatus:     p.PublishingStatus,
+		PublishingStatusNote: p.PublishingStatusNote,
+		PublicationDate:      p.PublicationDate,
+	}
+
+	for _, t := range p.Title {
+		record.Titles = append(record.Titles, onixTitle{
+			Type:     t.TitleType,
+			Text:     titleText(t.TitleText, t.TitlePrefix, t.TitleWithoutPrefix),
+			Subtitle: t.Subtitle,
+		})
+	}
+
+	for _, c := range p.Contributor {
+		record.Contributors = append(record.Contributors, onixContributor{
+			Role:         c.ContributorRole,
+			NameInverted: c.PersonNameInverted,
+		})
+	}
+
+	for _, lang := range p.Language {
+		record.Languages = append(record.Languages, lang.LanguageCode)
+	}
+
+	for _, subject := range p.MainSubject {
+		record.MainSubjects = append(record.MainSubjects, onixSubject{
+			SchemeIdentifier: subject.MainSubjectSchemeIdentifier,
+			Code:             subject.SubjectCode,
+		})
+	}
+
+	for _, m := range p.Measure {
+		record.Measures = append(record.Measures, onixMeasure{
+			Type:        m.MeasureTypeCode,
+			Measurement: m.Measurement,
+			Unit:        m.MeasureUnitCode,
+		})
+	}
+
+	for _, related := range p.RelatedProduct {
+		record.RelatedProducts = append(record.RelatedProducts, onixRelatedProduct{
+			RelationCode: related.RelationCode,
+			Identifiers:  identifiers(related.ProductIdentifier),
+		})
+	}
+
+	for _, supply := range p.SupplyDetail {
+		s := onixSupply{
+			AvailabilityCode:    supply.AvailabilityCode,
+			ProductAvailability: supply.ProductAvailability,
+			OnHand:              supply.Stock.OnHand,
+		}
+		for _, price := range supply.Price {
+			s.Prices = append(s.Prices, onixPrice{
+				Type:     price.PriceTypeCode,
+				Amount:   price.PriceAmount,
+				Currency: price.CurrencyCode,
+			})
+		}
+
+		record.Supplies = append(record.Supplies, s)
+	}
+
+	return
+}
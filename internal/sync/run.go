@@ -0,0 +1,160 @@
+package sync
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bookbox-backend/pkg/logger"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrSyncRequested means an admin already requested a run which has not started yet
+var ErrSyncRequested = errors.New("a sync run is already requested")
+
+// syncRun is the state of the running sync: the checkpoint of the files written so far and
+// the history row. The history is best effort, a failed write is logged but doesn't stop the sync.
+type syncRun struct {
+	progress *checkpoint
+	record   *model.SyncRun
+}
+
+func startSyncRun(syncData model.Sync, job, trigger, mode string) *syncRun {
+	run := &syncRun{
+		progress: loadCheckpoint(syncData),
+		record: &model.SyncRun{
+			Job:         job,
+			TriggeredBy: trigger,
+			Mode:        mode,
+			Status:      model.SyncRunRunning,
+			StartedAt:   time.Now(),
+		},
+	}
+
+	err := database.DB.Create(run.record).Error
+	if err != nil {
+		logger.Log.Warn("failed to record sync run", zap.Error(err))
+	}
+
+	updateWorkerStatus(func(status *WorkerStatus) {
+		status.CurrentRun = run.record.ID
+	})
+
+	return run
+}
+
+// startFile records a file before it is written
+func (r *syncRun) startFile(phase, archive, name string) *model.SyncRunFile {
+	file := &model.SyncRunFile{
+		SyncRunID: r.record.ID,
+		Phase:     phase,
+		Archive:   archive,
+		Name:      name,
+		StartedAt: time.Now(),
+	}
+
+	err := database.DB.Create(file).Error
+	if err != nil {
+		logger.Log.Warn("failed to record sync file",
+			zap.String("name", name),
+			zap.Error(err),
+		)
+	}
+
+	return file
+}
+
+// finishFile records the counts of the file and adds them to the run
+func (r *syncRun) finishFile(file *model.SyncRunFile, counts model.SyncCounts, fileErr error) {
+	now := time.Now()
+	file.FinishedAt = &now
+	file.SyncCounts = counts
+	if fileErr != nil {
+		file.Error = fileErr.Error()
+	}
+
+	r.record.SyncCounts.Add(counts)
+
+	err := database.DB.Save(file).Error
+	if err == nil {
+		err = database.DB.Save(r.record).Error
+	}
+	if err != nil {
+		logger.Log.Warn("failed to record sync file",
+			zap.String("name", file.Name),
+			zap.Error(err),
+		)
+	}
+}
+
+// finish records the result, a run stopped by the shutdown is interrupted and resumes from
+// the checkpoint in the next run
+func (r *syncRun) finish(syncErr error, interrupted bool) {
+	now := time.Now()
+	r.record.FinishedAt = &now
+
+	switch {
+	case interrupted:
+		r.record.Status = model.SyncRunInterrupted
+	case syncErr != nil:
+		r.record.Status = model.SyncRunFailed
+	default:
+		r.record.Status = model.SyncRunSucceeded
+	}
+
+	if syncErr != nil {
+		r.record.Error = syncErr.Error()
+	}
+
+	err := database.DB.Save(r.record).Error
+	if err != nil {
+		logger.Log.Warn("failed to record sync run", zap.Error(err))
+	}
+
+	updateWorkerStatus(func(status *WorkerStatus) {
+		status.CurrentRun = ""
+	})
+}
+
+// RequestSync runs the ONIX and the annotation sync now, on whichever instance gets to them
+// first. A full run imports the full archives again, a partial one only the archives since the
+// last run.
+func RequestSync(mode string) error {
+	res := database.DB.Model(&model.Sync{}).
+		Where("id = ? AND (requested_mode = '' OR requested_mode IS NULL)", "1").
+		Update("requested_mode", mode)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return ErrSyncRequested
+	}
+
+	// the mode stays requested when this fails, the next scheduled run takes it
+	return scheduler.Trigger(onixJob, annotJob)
+}
+
+// SetPaused skips or resumes the scheduled runs, requested runs start either way
+func SetPaused(paused bool) error {
+	return database.DB.Model(&model.Sync{}).
+		Where("id = ?", "1").
+		Update("paused", paused).
+		Error
+}
+
+// takeRequest clears the requested mode when the first of the syncs starts. A full run forgets
+// the sync dates and the checkpoint, so both syncs import the full archives from the start.
+func takeRequest(syncData model.Sync) error {
+	columns := []string{"requested_mode"}
+	if syncData.RequestedMode == model.SyncModeFull {
+		columns = append(columns, "is_full_synced", "last_onix_sync_date", "last_annot_sync_date", "checkpoint")
+	}
+
+	return database.DB.
+		Model(&model.Sync{Root: model.Root{ID: syncData.ID}}).
+		Select(columns).
+		UpdateColumns(model.Sync{}).
+		Error
+}
@@ -0,0 +1,216 @@
+package sync
+
+import (
+	"bookbox-backend/internal/config"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/pkg/sftp"
+)
+
+const (
+	CatalogSourceSFTP  = "sftp"
+	CatalogSourceFTP   = "ftp"
+	CatalogSourceLocal = "local"
+)
+
+// CatalogSource is where the ONIX and annotation archives are fetched from. Paths are slash
+// separated and absolute, like /Onix/BzTransferFull20240101.zip on the Buchzentrum server.
+type CatalogSource interface {
+	// List returns the entries of the directory
+	List(path string) ([]fs.FileInfo, error)
+
+	// Stat returns the entry of the path
+	Stat(path string) (fs.FileInfo, error)
+
+	// Open returns the content of the file, it has to be closed before the next call
+	Open(path string) (io.ReadCloser, error)
+
+	Close() error
+}
+
+// OpenCatalogSource connects to the source set in CATALOG_SOURCE
+func OpenCatalogSource() (CatalogSource, error) {
+	settings := liveConfig.Get()
+
+	switch settings.Catalog.Source {
+	case CatalogSourceFTP:
+		source, err := openFTPSource(settings.FTP)
+		if err != nil {
+			return nil, err
+		}
+
+		return source, nil
+	case CatalogSourceLocal:
+		return &localSource{root: settings.Catalog.Dir}, nil
+	}
+
+	client, err := ConnectToFtp(settings.SFTP.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sftpSource{client: client}, nil
+}
+
+// sftpSource is the Buchzentrum server
+type sftpSource struct {
+	client *sftp.Client
+}
+
+func (s *sftpSource) List(path string) ([]fs.FileInfo, error) {
+	return s.client.ReadDir(path)
+}
+
+func (s *sftpSource) Stat(path string) (fs.FileInfo, error) {
+	return s.client.Stat(path)
+}
+
+func (s *sftpSource) Open(path string) (io.ReadCloser, error) {
+	return s.client.Open(path)
+}
+
+func (s *sftpSource) Close() error {
+	return s.client.Close()
+}
+
+// ftpSource is a plain FTP server, with FTP_TLS the connection uses explicit TLS
+type ftpSource struct {
+	conn *ftp.ServerConn
+}
+
+func openFTPSource(settings config.FTPConfig) (source *ftpSource, err error) {
+	options := []ftp.DialOption{
+		ftp.DialWithTimeout(settings.Timeout),
+	}
+	if settings.TLS {
+		host, _, _ := net.SplitHostPort(settings.Address)
+		options = append(options, ftp.DialWithExplicitTLS(&tls.Config{
+			ServerName: host,
+			MinVersion: tls.VersionTLS12,
+		}))
+	}
+
+	conn, err := ftp.Dial(settings.Address, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.Login(settings.User, settings.Password)
+	if err != nil {
+		conn.Quit()
+		return nil, err
+	}
+
+	return &ftpSource{conn: conn}, nil
+}
+
+func (s *ftpSource) List(dir string) (entries []fs.FileInfo, err error) {
+	list, err := s.conn.List(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range list {
+		if entry.Name == "." || entry.Name == ".." {
+			continue
+		}
+
+		entries = append(entries, ftpEntryInfo{entry: entry})
+	}
+
+	return
+}
+
+// Stat lists the parent directory, MLST is not supported by every server
+func (s *ftpSource) Stat(file string) (fs.FileInfo, error) {
+	entries, err := s.List(path.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == path.Base(file) {
+			return entry, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s: %w", file, fs.ErrNotExist)
+}
+
+func (s *ftpSource) Open(file string) (io.ReadCloser, error) {
+	return s.conn.Retr(file)
+}
+
+func (s *ftpSource) Close() error {
+	return s.conn.Quit()
+}
+
+// ftpEntryInfo adapts a listing entry to fs.FileInfo
+type ftpEntryInfo struct {
+	entry *ftp.Entry
+}
+
+func (i ftpEntryInfo) Name() string       { return i.entry.Name }
+func (i ftpEntryInfo) Size() int64        { return int64(i.entry.Size) }
+func (i ftpEntryInfo) ModTime() time.Time { return i.entry.Time }
+func (i ftpEntryInfo) IsDir() bool        { return i.entry.Type == ftp.EntryTypeFolder }
+func (i ftpEntryInfo) Sys() any           { return i.entry }
+
+func (i ftpEntryInfo) Mode() fs.FileMode {
+	if i.IsDir() {
+		return fs.ModeDir | 0755
+	}
+
+	return 0644
+}
+
+// localSource reads a directory laid out like the server, e.g. CATALOG_DIR/Onix and
+// CATALOG_DIR/Annot with fixture archives for development and tests
+type localSource struct {
+	root string
+}
+
+// resolve keeps the path inside the root
+func (s *localSource) resolve(file string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+file)))
+}
+
+func (s *localSource) List(dir string) (entries []fs.FileInfo, err error) {
+	dirEntries, err := os.ReadDir(s.resolve(dir))
+	if err != nil {
+		return
+	}
+
+	for _, dirEntry := range dirEntries {
+		var info fs.FileInfo
+		info, err = dirEntry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, info)
+	}
+
+	return
+}
+
+func (s *localSource) Stat(file string) (fs.FileInfo, error) {
+	return os.Stat(s.resolve(file))
+}
+
+func (s *localSource) Open(file string) (io.ReadCloser, error) {
+	return os.Open(s.resolve(file))
+}
+
+func (s *localSource) Close() error {
+	return nil
+}
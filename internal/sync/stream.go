@@ -0,0 +1,424 @@
+package sync
+
+import (
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"bufio"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// onixPipelineBuffer bounds every stage of the pipeline, so a slow database stops the reader
+	// instead of filling the memory
+	onixPipelineBuffer = 100
+	onixBatchSize      = 100
+)
+
+// onixRecordNames are the element names of a product record, with short and reference tags
+var onixRecordNames = []string{"product", "Product"}
+
+// onixReader yields the product records of an ONIX file one at a time
+type onixReader struct {
+	reader  *bufio.Reader
+	decoder *xml.Decoder
+	log     *zap.Logger
+
+	// format is detected from the first element of the file
+	format   onixFormat
+	detected bool
+
+	// resynced is set once the decoder was restarted in the middle of the file, the end tags of
+	// the enclosing elements are unmatched for it
+	resynced bool
+
+	// Malformed counts the records which were skipped because they are not well-formed
+	Malformed int
+}
+
+func newOnixReader(r io.Reader, log *zap.Logger) *onixReader {
+	// the decoder reads byte by byte from a bufio.Reader, so after a syntax error the
+	// position of the reader is where the decoder stopped
+	reader := bufio.NewReader(r)
+
+	return &onixReader{
+		reader:  reader,
+		decoder: xml.NewDecoder(reader),
+		log:     log,
+	}
+}
+
+// Next returns the next record, io.EOF after the last one. A record which is not well-formed is
+// logged and skipped, reading continues at the next record.
+func (o *onixReader) Next() (record onixRecord, err error) {
+	for {
+		var token xml.Token
+		token, err = o.decoder.Token()
+		if err != nil {
+			err = o.recover(err, !o.resynced)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if !o.detected {
+			o.format = detectOnixFormat(start)
+			o.detected = true
+			o.log.Info("detected onix format",
+				zap.String("version", o.format.Version),
+				zap.Bool("referenceTags", o.format.Reference),
+			)
+		}
+
+		if !isOnixRecord(start.Name.Local) {
+			continue
+		}
+
+		record, err = decodeRecord(o.decoder, start, o.format)
+		if err != nil {
+			err = o.recover(err, true)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		return
+	}
+}
+
+// recover skips to the next record after a syntax error, other errors are returned. Only errors
+// of a record are counted as malformed.
+func (o *onixReader) recover(err error, malformed bool) error {
+	var syntaxErr *xml.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err
+	}
+
+	if malformed {
+		o.Malformed++
+		o.log.Warn("skipping malformed onix record",
+			zap.Int64("offset", o.decoder.InputOffset()),
+			zap.Error(err),
+		)
+	}
+
+	err = skipToOnixRecord(o.reader)
+	if err != nil {
+		return err
+	}
+
+	// the new decoder starts at the record, the "<" was consumed by the scan
+	o.reader = bufio.NewReader(io.MultiReader(strings.NewReader("<"), o.reader))
+	o.decoder = xml.NewDecoder(o.reader)
+	o.resynced = true
+	return nil
+}
+
+// skipToOnixRecord reads up to and including the "<" of the next record start tag
+func skipToOnixRecord(reader *bufio.Reader) error {
+	for {
+		_, err := reader.ReadBytes('<')
+		if err != nil {
+			return err
+		}
+
+		for _, name := range onixRecordNames {
+			peek, _ := reader.Peek(len(name) + 1)
+			if len(peek) == len(name)+1 && string(peek[:len(name)]) == name && strings.ContainsRune("> \t\r\n/", rune(peek[len(name)])) {
+				return nil
+			}
+		}
+	}
+}
+
+func isOnixRecord(name string) bool {
+	for _, recordName := range onixRecordNames {
+		if name == recordName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// productBatch are parsed products split by whether they exist already. Withdrawn titles
+// which do not exist and records superseded by a later one of the same product are skipped.
+type productBatch struct {
+	create   []model.Product
+	update   []model.Product
+	withdraw []model.Product
+	skipped  int
+}
+
+// onixStats are the counts of one ONIX file
+type onixStats struct {
+	mutex sync.Mutex
+
+	invalid   int
+	skipped   int
+	created   int
+	updated   int
+	withdrawn int
+	failed    int
+}
+
+func (s *onixStats) add(update func(stats *onixStats)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	update(s)
+}
+
+// writeOnixRecords runs the records of the file through the read, parse, lookup and upsert
+// stages. Every stage is connected with a bounded channel, the first failing stage stops all.
+func writeOnixRecords(parent context.Context, r io.Reader, isDL bool, log *zap.Logger) (counts model.SyncCounts, err error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		stageErr error
+		stats    = &onixStats{}
+		reader   = newOnixReader(r, log)
+
+		records = make(chan onixRecord, onixPipelineBuffer)
+		parsed  = make(chan model.Product, onixPipelineBuffer)
+		batches = make(chan productBatch, 1)
+	)
+
+	fail := func(err error) {
+		errOnce.Do(func() {
+			stageErr = err
+			cancel()
+		})
+	}
+
+	// read
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(records)
+
+		for {
+			record, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			select {
+			case records <- record:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// parse, in one goroutine so the records keep the order of the file; a later notification
+	// of the same record, like a delete after an update, must be applied last
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(parsed)
+
+		for record := range records {
+			p, err := parseProductData(record, isDL)
+			if err != nil {
+				log.Debug("skipping product",
+					zap.Error(err),
+				)
+				stats.add(func(stats *onixStats) { stats.invalid++ })
+				continue
+			}
+
+			if isDL {
+				p.IsDownloadTitle = true
+			}
+
+			select {
+			case parsed <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// lookup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(batches)
+
+		pending := make([]model.Product, 0, onixBatchSize)
+		flush := func() bool {
+			batch, err := splitExisting(pending)
+			if err != nil {
+				fail(err)
+				return false
+			}
+			pending = make([]model.Product, 0, onixBatchSize)
+
+			select {
+			case batches <- batch:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for p := range parsed {
+			pending = append(pending, p)
+			if len(pending) == onixBatchSize && !flush() {
+				return
+			}
+		}
+
+		if len(pending) > 0 {
+			flush()
+		}
+	}()
+
+	// upsert
+	for batch := range batches {
+		if len(batch.create) > 0 {
+			err = batchCreate(batch.create, onixBatchSize)
+		}
+		if err != nil {
+			log.Error("failed batch create",
+				zap.Error(err),
+			)
+			fail(err)
+			break
+		}
+
+		var updateFailed, withdrawFailed int
+		updateFailed, err = batchUpdate(ctx, batch.update)
+		if err != nil {
+			log.Error("failed batch update",
+				zap.Error(err),
+			)
+			fail(err)
+			break
+		}
+
+		withdrawFailed, err = withdrawProducts(ctx, batch.withdraw, log)
+		if err != nil {
+			fail(err)
+			break
+		}
+
+		stats.add(func(stats *onixStats) {
+			stats.skipped += batch.skipped
+			stats.created += len(batch.create)
+			stats.updated += len(batch.update) - updateFailed
+			stats.withdrawn += len(batch.withdraw) - withdrawFailed
+			stats.failed += updateFailed + withdrawFailed
+		})
+	}
+
+	// unblocks the stages when upsert stopped early
+	cancel()
+	wg.Wait()
+
+	log.Info("uploaded products",
+		zap.Int("createCount", stats.created),
+		zap.Int("updateCount", stats.updated),
+		zap.Int("withdrawCount", stats.withdrawn),
+		zap.Int("skipCount", stats.skipped),
+		zap.Int("invalidCount", stats.invalid),
+		zap.Int("failedCount", stats.failed),
+		zap.Int("malformedCount", reader.Malformed),
+	)
+
+	counts = model.SyncCounts{
+		Created:   stats.created,
+		Updated:   stats.updated,
+		Withdrawn: stats.withdrawn,
+		Skipped:   stats.skipped + stats.invalid + reader.Malformed,
+		Failed:    stats.failed,
+	}
+
+	// a shutdown stops the stages without an error, the file must not be checkpointed
+	if stageErr == nil {
+		stageErr = parent.Err()
+	}
+
+	return counts, stageErr
+}
+
+// splitExisting looks the products up with one query
+func splitExisting(products []model.Product) (batch productBatch, err error) {
+	products, batch.skipped = latestProducts(products)
+
+	ids := make([]string, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+
+	existing := make([]string, 0)
+	err = database.DB.Model(&model.Product{}).Where("id IN ?", ids).Pluck("id", &existing).Error
+	if err != nil {
+		return
+	}
+
+	found := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		found[id] = true
+	}
+
+	for _, p := range products {
+		switch {
+		case p.IsWithdrawn() && found[p.ID]:
+			batch.withdraw = append(batch.withdraw, p)
+		case p.IsWithdrawn():
+			batch.skipped++
+		case found[p.ID]:
+			batch.update = append(batch.update, p)
+		default:
+			p.SalesChannels = append(p.SalesChannels, model.SalesChannelProduct{
+				SalesChannelID: "1",
+			})
+			batch.create = append(batch.create, p)
+		}
+	}
+
+	return
+}
+
+// latestProducts keeps the last record of every product in the batch, the earlier ones are
+// superseded and counted. A product can't be created twice in one batch.
+func latestProducts(products []model.Product) (latest []model.Product, superseded int) {
+	last := make(map[string]int, len(products))
+	for i, p := range products {
+		last[p.ID] = i
+	}
+
+	latest = make([]model.Product, 0, len(last))
+	for i, p := range products {
+		if last[p.ID] != i {
+			superseded++
+			continue
+		}
+
+		latest = append(latest, p)
+	}
+
+	return
+}
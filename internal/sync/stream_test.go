@@ -0,0 +1,132 @@
+package sync
+
+import (
+	"bookbox-backend/internal/model"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestOnixReaderMalformed(t *testing.T) {
+	const (
+		valid1   = "<product><a001>1000001</a001><a002>03</a002></product>"
+		valid2   = "<product><a001>1000002</a001><a002>03</a002></product>"
+		mismatch = "<product><a001>1000003</a001><title><b203>Kaputt</b202></title></product>"
+		unclosed = "<product><a001>1000004</a001><title><b203>Offen</title></product>"
+		badChars = "<product><a001>1000005</a001><b012>B&C</b012></product>"
+	)
+
+	tests := []struct {
+		name          string
+		content       string
+		wantRecords   []string
+		wantMalformed int
+	}{
+		{
+			name:          "mismatched end tag between valid records",
+			content:       "<ONIXmessage>" + valid1 + mismatch + valid2 + "</ONIXmessage>",
+			wantRecords:   []string{"1000001", "1000002"},
+			wantMalformed: 1,
+		},
+		{
+			name:          "unclosed element in the first record",
+			content:       "<ONIXmessage>" + unclosed + valid1 + valid2 + "</ONIXmessage>",
+			wantRecords:   []string{"1000001", "1000002"},
+			wantMalformed: 1,
+		},
+		{
+			name:          "invalid entity in the last record",
+			content:       "<ONIXmessage>" + valid1 + valid2 + badChars + "</ONIXmessage>",
+			wantRecords:   []string{"1000001", "1000002"},
+			wantMalformed: 1,
+		},
+		{
+			name:          "two malformed records in a row",
+			content:       "<ONIXmessage>" + valid1 + mismatch + unclosed + valid2 + "</ONIXmessage>",
+			wantRecords:   []string{"1000001", "1000002"},
+			wantMalformed: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := newOnixReader(strings.NewReader(test.content), zap.NewNop())
+
+			records := make([]string, 0)
+			for {
+				record, err := reader.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("after %v: %v", records, err)
+				}
+
+				records = append(records, record.RecordReference)
+			}
+
+			if !reflect.DeepEqual(records, test.wantRecords) {
+				t.Errorf("records are %v, want %v", records, test.wantRecords)
+			}
+
+			if reader.Malformed != test.wantMalformed {
+				t.Errorf("%d records are malformed, want %d", reader.Malformed, test.wantMalformed)
+			}
+		})
+	}
+}
+
+func TestLatestProducts(t *testing.T) {
+	product := func(id string, availability string) model.Product {
+		return model.Product{Root: model.Root{ID: id}, Availability: availability}
+	}
+
+	tests := []struct {
+		name           string
+		products       []model.Product
+		want           []model.Product
+		wantSuperseded int
+	}{
+		{
+			name:     "distinct products",
+			products: []model.Product{product("1", model.ProductAvailable), product("2", model.ProductAvailable)},
+			want:     []model.Product{product("1", model.ProductAvailable), product("2", model.ProductAvailable)},
+		},
+		{
+			name:           "delete after update",
+			products:       []model.Product{product("1", model.ProductAvailable), product("2", model.ProductAvailable), product("1", model.ProductDeleted)},
+			want:           []model.Product{product("2", model.ProductAvailable), product("1", model.ProductDeleted)},
+			wantSuperseded: 1,
+		},
+		{
+			name:           "update after delete",
+			products:       []model.Product{product("1", model.ProductDeleted), product("1", model.ProductAvailable)},
+			want:           []model.Product{product("1", model.ProductAvailable)},
+			wantSuperseded: 1,
+		},
+		{
+			name:           "three notifications",
+			products:       []model.Product{product("1", model.ProductAvailable), product("1", model.ProductOutOfStock), product("1", model.ProductUnavailable)},
+			want:           []model.Product{product("1", model.ProductUnavailable)},
+			wantSuperseded: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, superseded := latestProducts(test.products)
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("products are %+v, want %+v", got, test.want)
+			}
+
+			if superseded != test.wantSuperseded {
+				t.Errorf("%d products are superseded, want %d", superseded, test.wantSuperseded)
+			}
+		})
+	}
+}
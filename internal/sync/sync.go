@@ -1,100 +1,237 @@
 package sync
 
 import (
+	"bookbox-backend/internal/config"
 	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/metrics"
 	"bookbox-backend/internal/model"
+	"bookbox-backend/internal/schedule"
 	"bookbox-backend/pkg/logger"
+	"context"
 	"fmt"
-	"io"
-	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
-	"github.com/pkg/sftp"
 	"go.uber.org/zap"
 )
 
 const (
+	// archives of sources without random access are downloaded next to the zip locations
 	onixZipLocation   = "/tmp/onix/bz/zip/data.zip"
-	onixDataLocation  = "/tmp/onix/bz/data"
 	annotZipLocation  = "/tmp/onix/annot/zip/data.zip"
 	annotDataLocation = "/tmp/onix/annot/data"
 
 	annotLocation = "onix/annot"
+)
+
+const (
+	onixJob  = "onix_sync"
+	annotJob = "annot_sync"
 
-	ftpUrl = "sftp.buchzentrum.ch:22"
+	// both syncs write the products and the sync row, they never run at the same time
+	catalogLock = "catalog"
 )
 
 var (
-	dayToSync = time.Now()
+	scheduler = schedule.New(logger.Log)
+
+	// liveConfig holds the catalog settings and the latest source credentials
+	liveConfig *config.Live
+
+	workerStatus = WorkerStatus{}
+	workerMutex  sync.Mutex
 )
 
-func Worker() {
+// WorkerStatus is the state of the sync worker, reported by the status route
+type WorkerStatus struct {
+	Running     bool      `json:"running"`
+	LastRun     time.Time `json:"last_run"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+	Paused      bool      `json:"paused"`
+	CurrentRun  string    `json:"current_run,omitempty"`
+}
+
+// Setup hands the configuration to the syncs, the source credentials are read on every connect
+func Setup(live *config.Live) {
+	liveConfig = live
+}
+
+// GetWorkerStatus returns a copy of the sync worker state
+func GetWorkerStatus() WorkerStatus {
+	workerMutex.Lock()
+	defer workerMutex.Unlock()
+
+	return workerStatus
+}
+
+func updateWorkerStatus(update func(status *WorkerStatus)) {
+	workerMutex.Lock()
+	defer workerMutex.Unlock()
+
+	update(&workerStatus)
+}
+
+// Worker runs the ONIX and annotation syncs on CATALOG_ONIX_SCHEDULE and CATALOG_ANNOT_SCHEDULE
+// until ctx is cancelled. A cancelled sync keeps its checkpoint and resumes on the next start.
+func Worker(ctx context.Context) {
+	updateWorkerStatus(func(status *WorkerStatus) {
+		status.Running = true
+	})
+	defer updateWorkerStatus(func(status *WorkerStatus) {
+		status.Running = false
+	})
+
 	err := SeedCategories(logger.Log)
+	if err == nil {
+		err = addJobs()
+	}
 	if err != nil {
 		logger.Log.Error("failed to sync",
 			zap.Error(err),
 		)
+
+		updateWorkerStatus(func(status *WorkerStatus) {
+			status.LastError = err.Error()
+		})
 		return
 	}
 
-	for {
-		start := time.Now()
+	scheduler.Run(ctx)
+}
 
-		if start.After(dayToSync) {
-			logger.Log.Info("starting sync",
-				zap.String("date", dayToSync.String()),
-			)
+func addJobs() error {
+	catalog := liveConfig.Get().Catalog
 
-			err = SyncData()
-			if err != nil {
-				logger.Log.Error("failed to sync, will try again",
-					zap.Error(err),
-				)
+	onixSpec, err := schedule.Parse(catalog.OnixSchedule)
+	if err != nil {
+		return fmt.Errorf("CATALOG_ONIX_SCHEDULE: %w", err)
+	}
 
-				time.Sleep(time.Second * 60)
-				continue
-			}
+	annotSpec, err := schedule.Parse(catalog.AnnotSchedule)
+	if err != nil {
+		return fmt.Errorf("CATALOG_ANNOT_SCHEDULE: %w", err)
+	}
 
-			dayToSync = dayToSync.AddDate(0, 0, 1)
-			fmt.Println(start)
-			fmt.Println(time.Now())
+	scheduler.Add(schedule.Job{
+		Name: onixJob,
+		Spec: onixSpec,
+		Lock: catalogLock,
+		Run: func(ctx context.Context, run schedule.Run) error {
+			return runSync(ctx, run, SyncOnix)
+		},
+	})
+	scheduler.Add(schedule.Job{
+		Name: annotJob,
+		Spec: annotSpec,
+		Lock: catalogLock,
+		Run: func(ctx context.Context, run schedule.Run) error {
+			return runSync(ctx, run, SyncAnnots)
+		},
+	})
+
+	return nil
+}
+
+// runSync runs one sync for the scheduler and reports it. Scheduled runs are skipped while the
+// sync is paused, requested ones still run.
+func runSync(ctx context.Context, run schedule.Run, syncFunc func(ctx context.Context, trigger string) error) error {
+	var syncData model.Sync
+	err := database.DB.Where("id = ?", "1").First(&syncData).Error
+	if err != nil {
+		return err
+	}
+
+	updateWorkerStatus(func(status *WorkerStatus) {
+		status.Paused = syncData.Paused
+	})
+
+	trigger := model.SyncTriggerSchedule
+	if run.Requested {
+		trigger = model.SyncTriggerManual
+	} else if syncData.Paused {
+		logger.Log.Info("skipping scheduled sync, the sync is paused",
+			zap.Time("window", run.Window),
+		)
+		return nil
+	}
+
+	start := time.Now()
+	err = syncFunc(ctx, trigger)
+	if ctx.Err() != nil {
+		logger.Log.Warn("sync interrupted by shutdown, it resumes from the checkpoint",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	metrics.SyncDuration.Observe(time.Since(start).Seconds())
+	updateWorkerStatus(func(status *WorkerStatus) {
+		status.LastRun = start
+		status.LastError = ""
+		if err != nil {
+			status.LastError = err.Error()
+			return
 		}
 
-		time.Sleep(time.Second * 60)
+		status.LastSuccess = time.Now()
+	})
+	if err != nil {
+		metrics.SyncRuns.WithLabelValues("error").Inc()
+		return err
 	}
 
+	metrics.SyncRuns.WithLabelValues("success").Inc()
+	return nil
 }
 
 var count int
 
-func SyncData() (err error) {
-	logger.Log.Info("started sync")
-
-	var syncData model.Sync
-	res := database.DB.Where("id = ?", "1").First(&syncData)
-	if res.RowsAffected == 0 {
-		logger.Log.Warn("failed to load sync data from the database", zap.Error(err))
+// loadSyncData loads the sync row and applies a run requested by an admin
+func loadSyncData() (syncData model.Sync, err error) {
+	err = database.DB.Where("id = ?", "1").First(&syncData).Error
+	if err != nil || syncData.RequestedMode == "" {
 		return
 	}
 
-	date2, err := loadOnixFiles(syncData, onixZipLocation, onixDataLocation, false)
+	err = takeRequest(syncData)
 	if err != nil {
-		logger.Log.Error("failed to load onix files",
-			zap.Error(err),
-		)
 		return
 	}
 
-	err = loadOnixData(onixDataLocation, false)
+	err = database.DB.Where("id = ?", "1").First(&syncData).Error
+	return
+}
+
+// SyncOnix writes the ONIX archives since the last sync, the full ones unless the catalog was
+// fully synced before, and records the run
+func SyncOnix(ctx context.Context, trigger string) (err error) {
+	logger.Log.Info("started onix sync")
+
+	syncData, err := loadSyncData()
 	if err != nil {
-		logger.Log.Error("failed to sync data",
-			zap.Error(err),
-		)
+		logger.Log.Warn("failed to load sync data from the database", zap.Error(err))
 		return
 	}
 
-	date1, err := loadOnixFiles(syncData, onixZipLocation, onixDataLocation, true)
+	mode := model.SyncModePartial
+	if !syncData.IsFullSynced {
+		mode = model.SyncModeFull
+	}
+
+	run := startSyncRun(syncData, onixJob, trigger, mode)
+	defer func() {
+		run.finish(err, ctx.Err() != nil)
+	}()
+
+	if len(syncData.Checkpoint) != 0 {
+		logger.Log.Info("resuming interrupted sync",
+			zap.Int("checkpointedFiles", len(syncData.Checkpoint)),
+		)
+	}
+
+	date2, err := loadOnixFiles(ctx, syncData, false, run)
 	if err != nil {
 		logger.Log.Error("failed to load onix files",
 			zap.Error(err),
@@ -102,19 +239,18 @@ func SyncData() (err error) {
 		return
 	}
 
-	err = loadOnixData(onixDataLocation, true)
+	date1, err := loadOnixFiles(ctx, syncData, true, run)
 	if err != nil {
-		logger.Log.Error("failed to sync data",
+		logger.Log.Error("failed to load onix files",
 			zap.Error(err),
 		)
 		return
 	}
 
-	_, err = loadAnnotFiles(syncData, annotZipLocation, annotDataLocation)
+	// every file is written, the next run starts from scratch
+	err = run.progress.Clear(onixPhase, onixDLPhase)
 	if err != nil {
-		logger.Log.Error("failed to load annot files",
-			zap.Error(err),
-		)
+		logger.Log.Error("failed to clear sync checkpoint", zap.Error(err))
 		return
 	}
 
@@ -130,54 +266,82 @@ func SyncData() (err error) {
 		syncData.LastOnixSyncDate = date2
 	}
 
-	syncData.LastAnnotSyncDate = syncData.LastOnixSyncDate
 	syncData.IsFullSynced = true
-	err = database.DB.Updates(&syncData).Error
+
+	// the checkpoint of the annotation sync, the pause and the requested run are changed by
+	// others while the sync runs
+	err = database.DB.Model(&syncData).
+		Select("is_full_synced", "last_onix_sync_date", "updated_at").
+		Updates(&syncData).Error
 	if err != nil {
-		logger.Log.Error("failed to update annotations", zap.Error(err))
+		logger.Log.Error("failed to update sync data", zap.Error(err))
 		return
 	}
 
 	return
 }
 
-func DownloadAndDecompress(ftpClient *sftp.Client, ftpLocation, zipLocation, dataLocation string) (err error) {
-	res, err := ftpClient.Open(ftpLocation)
+// SyncAnnots writes the annotation archives since the last sync, the full ones when the
+// annotations were never synced, and records the run
+func SyncAnnots(ctx context.Context, trigger string) (err error) {
+	logger.Log.Info("started annot sync")
+
+	syncData, err := loadSyncData()
 	if err != nil {
-		return err
+		logger.Log.Warn("failed to load sync data from the database", zap.Error(err))
+		return
+	}
+
+	mode := model.SyncModePartial
+	if syncData.LastAnnotSyncDate == 0 {
+		mode = model.SyncModeFull
 	}
-	defer res.Close()
 
-	directoryPath := filepath.Dir(zipLocation)
-	os.MkdirAll(directoryPath, 0744)
+	run := startSyncRun(syncData, annotJob, trigger, mode)
+	defer func() {
+		run.finish(err, ctx.Err() != nil)
+	}()
 
-	file, err := os.Create(zipLocation)
+	newest, err := loadAnnotFiles(ctx, syncData, annotZipLocation, annotDataLocation, run)
 	if err != nil {
-		return err
+		logger.Log.Error("failed to load annot files",
+			zap.Error(err),
+		)
+		return
 	}
-	defer file.Close()
 
-	logger.Log.Info("copying zip file to disk",
-		zap.String("ftpLocation", ftpLocation),
-		zap.String("zipLocation", zipLocation),
-		zap.String("dataLocation", dataLocation),
-	)
+	err = run.progress.Clear(annotPhase)
+	if err != nil {
+		logger.Log.Error("failed to clear sync checkpoint", zap.Error(err))
+		return
+	}
 
-	_, err = io.Copy(file, res)
+	syncData.LastAnnotSyncDate = newest
+	err = database.DB.Model(&syncData).
+		Select("last_annot_sync_date", "updated_at").
+		Updates(&syncData).Error
+	if err != nil {
+		logger.Log.Error("failed to update sync data", zap.Error(err))
+		return
+	}
+
+	return
+}
+
+// DownloadAndDecompress extracts the archive of the source into dataLocation, it is only
+// downloaded next to zipLocation when the source can't be read in place
+func DownloadAndDecompress(ctx context.Context, source CatalogSource, ftpLocation, zipLocation, dataLocation string) (err error) {
+	opened, err := openArchive(ctx, source, ftpLocation, filepath.Dir(zipLocation))
 	if err != nil {
 		return err
 	}
+	defer opened.Close()
 
 	logger.Log.Info("decompressing zip file to disk",
 		zap.String("ftpLocation", ftpLocation),
-		zap.String("zipLocation", zipLocation),
+		zap.Int("entries", len(opened.File)),
 		zap.String("dataLocation", dataLocation),
 	)
 
-	err = DecompressToDisk(zipLocation, dataLocation)
-	if err != nil {
-		return err
-	}
-
-	return
+	return extractArchive(ctx, opened.Reader, dataLocation)
 }
@@ -0,0 +1,178 @@
+package sync
+
+import (
+	"bookbox-backend/internal/config"
+	"bookbox-backend/internal/database"
+	"bookbox-backend/internal/model"
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testCatalogDir is laid out like the Buchzentrum server: full and partial archives in Onix and
+// OnixDL, and the annotations by month in Annot with the partial archives next to them
+const testCatalogDir = "testdata/catalog"
+
+var (
+	fullDate    = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	partialDate = time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC).Unix()
+)
+
+func TestFindOnixArchives(t *testing.T) {
+	source := &localSource{root: testCatalogDir}
+
+	tests := []struct {
+		name        string
+		root        string
+		fullFile    string
+		partialFile string
+		wantFull    string
+		wantPartial []string
+	}{
+		{
+			name:        "onix",
+			root:        rootPath,
+			fullFile:    bzFileName,
+			partialFile: bzPartialFileName,
+			wantFull:    "/Onix/BzTransferFull20240101.zip",
+			wantPartial: []string{"/Onix/bzonix20240102.zip"},
+		},
+		{
+			// the partial archives of the download titles are below their own root
+			name:        "download titles",
+			root:        rootPathDL,
+			fullFile:    bzDLFileName,
+			partialFile: bzPartialDLFileName,
+			wantFull:    "/OnixDL/BzTransferFullDL20240101.zip",
+			wantPartial: []string{"/OnixDL/bzonixdl20240102.zip"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			date, full, err := FindFullOnixFiles(source, test.root, test.fullFile)
+			if err != nil {
+				t.Fatalf("full archive: %v", err)
+			}
+
+			if full != test.wantFull || date != fullDate {
+				t.Errorf("full archive is %s of %d, want %s of %d", full, date, test.wantFull, fullDate)
+			}
+
+			partial, newest, err := FindPartialOnixFiles(source, test.root, test.partialFile, date)
+			if err != nil {
+				t.Fatalf("partial archives: %v", err)
+			}
+
+			if !reflect.DeepEqual(partial, test.wantPartial) || newest != partialDate {
+				t.Errorf("partial archives are %v up to %d, want %v up to %d", partial, newest, test.wantPartial, partialDate)
+			}
+		})
+	}
+}
+
+// TestSyncLocalCatalog runs the ONIX and the annotation sync from the fixture archives. It
+// writes products and resets the sync row, so it only runs against the database named in
+// TEST_DB_NAME, on the server of DB_HOST, DB_PORT, DB_USER and DB_PASS.
+func TestSyncLocalCatalog(t *testing.T) {
+	name := os.Getenv("TEST_DB_NAME")
+	if name == "" {
+		t.Skip("TEST_DB_NAME is not set")
+	}
+
+	port := os.Getenv("DB_PORT")
+	if port == "" {
+		port = "5432"
+	}
+
+	live := config.NewLive(&config.Config{
+		Database: config.DatabaseConfig{
+			Host:     os.Getenv("DB_HOST"),
+			Port:     port,
+			User:     os.Getenv("DB_USER"),
+			Password: os.Getenv("DB_PASS"),
+			Name:     name,
+		},
+		Catalog: config.CatalogConfig{
+			Source:    CatalogSourceLocal,
+			Dir:       testCatalogDir,
+			Withdrawn: withdrawnDeactivate,
+		},
+	})
+
+	err := database.Setup(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Setup(live)
+
+	ids := []string{"1000001", "1000002", "2000001", "2000002"}
+	reset := func() {
+		database.DB.Where("product_id IN ?", ids).Delete(&model.SalesChannelProduct{})
+		database.DB.Where("product_id IN ?", ids).Delete(&model.ProductCategory{})
+		database.DB.Where("id IN ?", ids).Delete(&model.Product{})
+		database.DB.
+			Model(&model.Sync{Root: model.Root{ID: "1"}}).
+			Select("is_full_synced", "last_onix_sync_date", "last_annot_sync_date", "checkpoint", "paused", "requested_mode").
+			UpdateColumns(model.Sync{})
+	}
+	reset()
+	t.Cleanup(reset)
+
+	err = SyncOnix(context.Background(), model.SyncTriggerManual)
+	if err != nil {
+		t.Fatalf("onix sync: %v", err)
+	}
+
+	err = SyncAnnots(context.Background(), model.SyncTriggerManual)
+	if err != nil {
+		t.Fatalf("annot sync: %v", err)
+	}
+
+	stored := make([]model.Product, 0)
+	err = database.DB.Where("id IN ?", ids).Find(&stored).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	products := make(map[string]model.Product, len(stored))
+	for _, p := range stored {
+		products[p.ID] = p
+	}
+
+	// deleted by the partial archive
+	if p := products["1000001"]; p.Availability != model.ProductDeleted || p.Active == nil || *p.Active {
+		t.Errorf("1000001: availability %q and active %v, want a deactivated deleted title", p.Availability, p.Active)
+	}
+
+	// price updated by the partial archive, description and cover from the full annotations
+	if p := products["1000002"]; p.SellingPrice != 19.90 || p.Description != "Eine Beschreibung des zweiten Titels." || p.CoverPicture == "" {
+		t.Errorf("1000002: price %v, description %q, cover set %v", p.SellingPrice, p.Description, p.CoverPicture != "")
+	}
+
+	// ONIX 2.1 with reference tags, description from the partial annotations
+	if p := products["2000001"]; !p.IsDownloadTitle || p.Title != "Das E-Book" || p.Description != "Eine Beschreibung des E-Books." {
+		t.Errorf("2000001: download title %v, title %q, description %q", p.IsDownloadTitle, p.Title, p.Description)
+	}
+
+	// ONIX 3.0 from the partial archive of the download titles
+	if p := products["2000002"]; !p.IsDownloadTitle || p.Title != "Das Hörbuch" || p.SellingPrice != 9.90 {
+		t.Errorf("2000002: download title %v, title %q, price %v", p.IsDownloadTitle, p.Title, p.SellingPrice)
+	}
+
+	var syncData model.Sync
+	err = database.DB.Where("id = ?", "1").First(&syncData).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !syncData.IsFullSynced || syncData.LastOnixSyncDate != partialDate || syncData.LastAnnotSyncDate != partialDate {
+		t.Errorf("sync is full synced %v, last onix %d and annot %d, want %d", syncData.IsFullSynced, syncData.LastOnixSyncDate, syncData.LastAnnotSyncDate, partialDate)
+	}
+
+	if len(syncData.Checkpoint) != 0 {
+		t.Errorf("checkpoint %v was not cleared", syncData.Checkpoint)
+	}
+}
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"bookbox-backend/internal/config"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// ServiceName is reported on every span, OTEL_SERVICE_NAME overrides it
+	ServiceName = "bookbox-backend"
+
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+var (
+	// exporter is one of otlp, stdout or file, tracing is disabled when it is empty
+	exporter string
+
+	// file receives the spans with the file exporter
+	file string
+
+	// sampleRatio of root spans which are recorded, child spans follow their parent
+	sampleRatio = 1.0
+
+	// HTTPClient creates a client span for every outbound request and propagates the trace
+	HTTPClient = &http.Client{
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+
+	provider *sdktrace.TracerProvider
+	tracer   = otel.Tracer(ServiceName)
+)
+
+func init() {
+	// incoming and outgoing requests use W3C trace context, even when tracing is disabled,
+	// so traces of other services are not cut off here
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+// Setup installs the tracer provider with the configured exporter, the OTLP exporter reads
+// OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_EXPORTER_OTLP_HEADERS
+func Setup(settings config.TracingConfig, log *zap.Logger) (err error) {
+	exporter = settings.Exporter
+	file = settings.File
+	sampleRatio = settings.SampleRatio
+
+	if exporter == "" {
+		log.Info("tracing disabled")
+		return
+	}
+
+	ctx := context.Background()
+
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterFile:
+		var out *os.File
+		out, err = os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(out))
+	default:
+		err = fmt.Errorf("unknown tracing exporter: %s", exporter)
+	}
+	if err != nil {
+		return
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Info("tracing enabled",
+		zap.String("exporter", exporter),
+		zap.Float64("sampleRatio", sampleRatio),
+	)
+
+	return
+}
+
+// Shutdown flushes the spans which are not exported yet
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+
+	return provider.Shutdown(ctx)
+}
+
+// Start opens a span as child of the span in ctx
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End marks the span as failed if err is set and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}